@@ -0,0 +1,170 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_AgeHeaderIncreasesOnHits(t *testing.T) {
+	middleware := NewDefault()
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=60, s-maxage=120")
+		w.Write([]byte(`{}`))
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api", nil))
+		return rec
+	}
+
+	serve() // populate the cache
+	first := serve()
+	if first.Header().Get("Age") != "0" {
+		t.Errorf("Expected Age 0 on an immediate hit, got %q", first.Header().Get("Age"))
+	}
+
+	// Age the cached entry instead of sleeping
+	key := middleware.createCacheKey(httptest.NewRequest("GET", "/api", nil))
+	cached, found := middleware.GetCacheForTesting().Get(key)
+	if !found {
+		t.Fatal("Expected response to be cached")
+	}
+	cached.StoreTime = time.Now().Add(-30 * time.Second)
+
+	second := serve()
+	age, err := strconv.Atoi(second.Header().Get("Age"))
+	if err != nil || age < 30 {
+		t.Fatalf("Expected Age to grow to at least 30, got %q", second.Header().Get("Age"))
+	}
+	if got, want := second.Header().Get("Cache-Control"), "public, max-age="+strconv.Itoa(60-age)+", s-maxage="+strconv.Itoa(120-age); got != want {
+		t.Errorf("Cache-Control = %q, want %q", got, want)
+	}
+}
+
+func TestReduceMaxAge(t *testing.T) {
+	tests := []struct {
+		value string
+		age   time.Duration
+		want  string
+	}{
+		{"max-age=60", 10 * time.Second, "max-age=50"},
+		{"public,  MAX-AGE=60 , must-revalidate", 90 * time.Second, "public, max-age=0, must-revalidate"},
+		{"no-cache", time.Minute, "no-cache"},
+		{"max-age=abc", time.Minute, "max-age=abc"},
+	}
+
+	for _, tt := range tests {
+		if got := reduceMaxAge(tt.value, tt.age); got != tt.want {
+			t.Errorf("reduceMaxAge(%q, %v) = %q, want %q", tt.value, tt.age, got, tt.want)
+		}
+	}
+}
+
+// TestMiddleware_UpstreamAgeReducesTTL verifies that a response arriving
+// with an Age header is only kept for what remains of its max-age, and isn't
+// cached once its age exceeds max-age
+func TestMiddleware_UpstreamAgeReducesTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     string
+		wantTTL time.Duration
+		cached  bool
+	}{
+		{"partly aged", "40", 60 * time.Second, true},
+		{"no Age", "", 100 * time.Second, true},
+		{"older than max-age", "150", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := NewDefault()
+			defer middleware.Close()
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Cache-Control", "max-age=100")
+				if tt.age != "" {
+					w.Header().Set("Age", tt.age)
+				}
+				w.Write([]byte(`{}`))
+			}))
+
+			req := httptest.NewRequest("GET", "/aged", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entry, found := middleware.Cache().Get(middleware.createCacheKey(req))
+			if found != tt.cached {
+				t.Fatalf("Expected cached=%v, got %v", tt.cached, found)
+			}
+			if !found {
+				return
+			}
+			if ttl := entry.ExpiresAt.Sub(entry.StoreTime); ttl != tt.wantTTL {
+				t.Errorf("Expected TTL %v, got %v", tt.wantTTL, ttl)
+			}
+		})
+	}
+}
+
+// TestContentDetector_UpstreamAgeReducesTTL verifies the transport layer's
+// recommended TTL accounts for an incoming Age header
+func TestContentDetector_UpstreamAgeReducesTTL(t *testing.T) {
+	config := DefaultCacheConfig()
+	detector := NewContentDetector(config)
+	headers := http.Header{
+		"Content-Type":  {"application/json"},
+		"Cache-Control": {"max-age=100"},
+		"Age":           {"40"},
+	}
+
+	analysis := detector.AnalyzeResponse([]byte(`{}`), headers, http.StatusOK)
+	if !analysis.IsCacheable || analysis.RecommendedTTL != 60*time.Second {
+		t.Errorf("Expected a cacheable response with TTL 60s, got cacheable=%v TTL %v", analysis.IsCacheable, analysis.RecommendedTTL)
+	}
+
+	headers.Set("Age", "150")
+	if detector.AnalyzeResponse([]byte(`{}`), headers, http.StatusOK).IsCacheable {
+		t.Error("Expected a response older than its max-age not to be cached")
+	}
+}
+
+// TestMiddleware_AgeAndStalenessFollowClock verifies that Age and stale-if-error
+// freshness are measured on the cache's clock, not the wall clock
+func TestMiddleware_AgeAndStalenessFollowClock(t *testing.T) {
+	clock := newFakeClock()
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+	middleware.cache.clock = clock
+
+	failing := false
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60, stale-if-error=300")
+		w.Write([]byte(`{}`))
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api", nil))
+		return rec
+	}
+
+	serve() // populate the cache
+	clock.Advance(30 * time.Second)
+	if hit := serve(); hit.Header().Get("Age") != "30" || hit.Header().Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected a fresh hit with Age 30, got %q (Age %q)", hit.Header().Get("X-Cache-Status"), hit.Header().Get("Age"))
+	}
+
+	failing = true
+	clock.Advance(time.Minute)
+	if stale := serve(); stale.Header().Get("X-Cache-Status") != "STALE-ERROR" {
+		t.Errorf("Expected the entry to go stale on the clock, got %q", stale.Header().Get("X-Cache-Status"))
+	}
+}
@@ -0,0 +1,143 @@
+package selectcache
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPooledConnection returns a connection whose analysis runs on pool
+func newPooledConnection(config *CacheConfig, cache *TTLCache, metrics *CacheMetrics, pool *analysisPool) (*CachingConnection, *mockConn) {
+	mockConn := newMockConn()
+	conn := NewCachingConnection(mockConn, cache, config, metrics, NewContentDetector(config))
+	conn.analysisPool = pool
+	return conn, mockConn
+}
+
+// serveJSON passes one request and a complete JSON response through conn
+func serveJSON(conn *CachingConnection, mockConn *mockConn, path string, body []byte) {
+	mockConn.writeToReadBuffer([]byte("GET " + path + " HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	conn.Read(make([]byte, 1024))
+	conn.Write([]byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)))
+}
+
+// TestCachingConnection_AnalysisWorkers verifies that offloaded analysis
+// still caches responses
+func TestCachingConnection_AnalysisWorkers(t *testing.T) {
+	config := DefaultCacheConfig()
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+	pool := newAnalysisPool(2)
+	defer pool.stop()
+
+	conn, mockConn := newPooledConnection(config, cache, nil, pool)
+	defer conn.Close()
+	serveJSON(conn, mockConn, "/pooled.json", []byte(`{"pooled": true}`))
+
+	key := GenerateCacheKey("GET", "/pooled.json", "", map[string]string{"Host": "example.com"})
+	if _, found := waitForEntry(cache, key, time.Second); !found {
+		t.Fatal("Expected the response to be cached by a worker")
+	}
+	if len(conn.responseBuffer) != 0 {
+		t.Errorf("Expected the response buffer to be cleared on the write path, got %d bytes", len(conn.responseBuffer))
+	}
+}
+
+// TestCachingConnection_AnalysisPoolFull verifies that a response is left
+// uncached, without blocking the write, when the pool is saturated
+func TestCachingConnection_AnalysisPoolFull(t *testing.T) {
+	config := DefaultCacheConfig()
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+	pool := newAnalysisPool(1)
+	defer pool.stop()
+
+	// Occupy the worker and fill the queue
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(1)
+	pool.submit(func() { started.Done(); <-release })
+	started.Wait()
+	pool.submit(func() {})
+
+	conn, mockConn := newPooledConnection(config, cache, metrics, pool)
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		serveJSON(conn, mockConn, "/skipped.json", []byte(`{"skipped": true}`))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Write blocked on a full analysis pool")
+	}
+	close(release)
+
+	if errs := metrics.GetStats().Errors; errs["analysis_pool_full"] != 1 {
+		t.Errorf("Expected analysis_pool_full to be recorded once, got %d", errs["analysis_pool_full"])
+	}
+	if cache.Size() != 0 {
+		t.Errorf("Expected the response not to be cached, got %d entries", cache.Size())
+	}
+}
+
+// TestAnalysisPool_Stop verifies that a stopped pool refuses work
+func TestAnalysisPool_Stop(t *testing.T) {
+	pool := newAnalysisPool(1)
+	pool.stop()
+	pool.stop()
+	if pool.submit(func() {}) {
+		t.Error("Expected a stopped pool to refuse work")
+	}
+}
+
+// benchmarkAnalysisWrite measures the write of a complete 64KB JSON response,
+// which triggers its analysis, with the given pool (nil analyzes inline)
+func benchmarkAnalysisWrite(b *testing.B, workers int) {
+	config := DefaultCacheConfig()
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+	var pool *analysisPool
+	if workers > 0 {
+		pool = newAnalysisPool(workers)
+		defer pool.stop()
+	}
+	conn, mockConn := newPooledConnection(config, cache, nil, pool)
+	defer conn.Close()
+
+	body := bytes.Repeat([]byte(`{"field": "value"},`), 64*1024/19)
+	response := []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body))
+	request := []byte("GET /bench.json HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	readBuf := make([]byte, 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		mockConn.writeToReadBuffer(request)
+		conn.Read(readBuf)
+		mockConn.mu.Lock()
+		mockConn.writeBuffer.Reset()
+		mockConn.mu.Unlock()
+		b.StartTimer()
+
+		conn.Write(response)
+	}
+}
+
+// BenchmarkCachingConnection_WriteInlineAnalysis measures write latency with
+// analysis on the write path
+func BenchmarkCachingConnection_WriteInlineAnalysis(b *testing.B) {
+	benchmarkAnalysisWrite(b, 0)
+}
+
+// BenchmarkCachingConnection_WritePooledAnalysis measures write latency with
+// analysis offloaded to workers
+func BenchmarkCachingConnection_WritePooledAnalysis(b *testing.B) {
+	benchmarkAnalysisWrite(b, 4)
+}
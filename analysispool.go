@@ -0,0 +1,65 @@
+package selectcache
+
+import "sync"
+
+// analysisPool runs transport-layer response analysis on a fixed number of
+// workers, so connection writes don't wait for it. Its queue holds one job
+// per worker; work submitted while the queue is full is refused rather than
+// waited for.
+type analysisPool struct {
+	jobs chan func()
+
+	stopOnce sync.Once
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newAnalysisPool starts a pool with the given number of workers
+func newAnalysisPool(workers int) *analysisPool {
+	p := &analysisPool{
+		jobs: make(chan func(), workers),
+		done: make(chan struct{}),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// work runs queued jobs until the pool stops
+func (p *analysisPool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case job := <-p.jobs:
+			job()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// submit queues a job, reporting false without queuing it when the queue is
+// full or the pool has stopped
+func (p *analysisPool) submit(job func()) bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop stops the workers once their current jobs finish. Jobs still queued
+// are dropped.
+func (p *analysisPool) stop() {
+	p.stopOnce.Do(func() { close(p.done) })
+	p.wg.Wait()
+}
@@ -0,0 +1,49 @@
+package selectcache
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestMiddleware_AuthorizationPolicy verifies when a response to a request
+// with credentials may be cached and replayed to the same credentials
+func TestMiddleware_AuthorizationPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		cachePrivate bool
+		token        string
+		cached       bool
+	}{
+		{"authenticated private by default", "", false, "Bearer alice", false},
+		{"anonymous unaffected", "", false, "", true},
+		{"Cache-Control public", "public, max-age=60", false, "Bearer alice", true},
+		{"CachePrivateResponses", "", true, "Bearer alice", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.CachePrivateResponses = tt.cachePrivate
+			headers := http.Header{"Content-Type": {"application/json"}}
+			if tt.cacheControl != "" {
+				headers.Set("Cache-Control", tt.cacheControl)
+			}
+			handler := newHeaderTestHandler(t, config, headers, `{}`)
+
+			serveWithHeader(handler, "/api/profile", "Authorization", tt.token)
+			rec := serveWithHeader(handler, "/api/profile", "Authorization", tt.token)
+			if hit := rec.Header().Get("X-Cache-Status") == "HIT"; hit != tt.cached {
+				t.Errorf("Expected cached=%v for a repeated request, got %v", tt.cached, hit)
+			}
+
+			// Entries remain keyed per credential
+			if tt.token != "" {
+				rec := serveWithHeader(handler, "/api/profile", "Authorization", "Bearer bob")
+				if rec.Header().Get("X-Cache-Status") == "HIT" {
+					t.Error("Expected a different credential to miss the cache")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package selectcache
+
+import (
+	"net/http"
+	"time"
+)
+
+// SetItem is one entry of a SetMulti batch
+type SetItem struct {
+	Data    []byte
+	Headers http.Header
+	TTL     time.Duration
+}
+
+// GetMulti retrieves the unexpired entries for keys, taking each shard lock
+// once for the whole batch rather than once per key. Missing and expired keys
+// are absent from the result.
+func (c *TTLCache) GetMulti(keys []string) map[string]*CacheEntry {
+	start := time.Now()
+	defer c.recordLookupMetrics(start)
+
+	found := make(map[string]*CacheEntry, len(keys))
+	var expired []*CacheEntry
+	var misses []string
+	for shard, shardKeys := range c.groupKeysByShard(keys) {
+		shard.mu.Lock()
+		for _, key := range shardKeys {
+			entry, gone := c.getUnsafe(shard, key)
+			if gone != nil {
+				expired = append(expired, gone)
+			}
+			if entry == nil {
+				misses = append(misses, key)
+				continue
+			}
+			found[key] = entry
+		}
+		shard.mu.Unlock()
+	}
+
+	for _, entry := range expired {
+		c.notifyEvict(entry)
+	}
+	if c.config.Logger != nil {
+		for _, key := range misses {
+			c.config.Logger.OnMiss(key, "")
+		}
+		for key := range found {
+			c.config.Logger.OnHit(key, "")
+		}
+	}
+
+	return found
+}
+
+// SetMulti stores a batch of entries, taking each shard lock once to drop the
+// entries being replaced and once to store the new ones. Entries larger than
+// MaxEntrySizeBytes are skipped and reported with ErrEntryTooLarge once the
+// rest are stored. While the store circuit is open nothing is stored and
+// ErrCircuitOpen is returned; likewise ErrEvictionBatchExceeded when making
+// room for the batch would take more than MaxEvictionBatch evictions.
+func (c *TTLCache) SetMulti(items map[string]SetItem) error {
+	start := time.Now()
+	defer func() {
+		if c.metrics != nil {
+			c.metrics.RecordStoreTime(time.Since(start))
+		}
+	}()
+
+	var err error
+	batches := make(map[*cacheShard][]*CacheEntry)
+	var batchSize uint64
+	batchCount := 0
+	for key, item := range items {
+		entry := c.createCacheEntry(key, item.Data, item.Headers, item.TTL)
+		if limit := c.config.MaxEntrySizeBytes; limit > 0 && int64(entry.Size) > limit {
+			c.recordStoreError(ErrEntryTooLarge)
+			err = ErrEntryTooLarge
+			continue
+		}
+		shard := c.shardFor(key)
+		batches[shard] = append(batches[shard], entry)
+		batchSize += uint64(entry.Size)
+		batchCount++
+	}
+	if batchCount == 0 {
+		return err
+	}
+
+	// Past this point the store circuit breaker hears how the store went
+	if !c.storeBreaker.Allow() {
+		c.recordStoreError(ErrCircuitOpen)
+		return ErrCircuitOpen
+	}
+
+	// Drop the entries being replaced so they don't count against the limits
+	var replaced []*CacheEntry
+	for shard, batch := range batches {
+		shard.mu.Lock()
+		for _, entry := range batch {
+			if existing := c.removeExistingEntry(shard, entry.key); existing != nil {
+				replaced = append(replaced, existing)
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	// Make room for the whole batch before storing any of it, first within
+	// segment quotas and then within the overall limits
+	var entries []*CacheEntry
+	for _, batch := range batches {
+		entries = append(entries, batch...)
+	}
+	evicted := c.makeSegmentRoom(entries)
+	limitEvicted, fits := c.checkMemoryLimits(batchSize, batchCount)
+	evicted = append(evicted, limitEvicted...)
+	if !fits {
+		// Running out of room says nothing about the backing store's health
+		c.abandonStore(evicted, replaced, ErrEvictionBatchExceeded)
+		c.storeBreaker.release()
+		return ErrEvictionBatchExceeded
+	}
+	for _, entry := range entries {
+		if err := c.backendError(entry.key); err != nil {
+			c.abandonStore(evicted, replaced, err)
+			c.storeBreaker.RecordFailure()
+			return err
+		}
+	}
+
+	for shard, batch := range batches {
+		shard.mu.Lock()
+		for _, entry := range batch {
+			c.storeCacheEntry(shard, entry)
+		}
+		shard.mu.Unlock()
+	}
+	c.storeBreaker.RecordSuccess()
+
+	for _, evictedEntry := range evicted {
+		c.notifyEvict(evictedEntry)
+	}
+	if c.config.Logger != nil {
+		for _, batch := range batches {
+			for _, entry := range batch {
+				c.config.Logger.OnStore(entry.key, entry.Size)
+			}
+		}
+	}
+
+	return err
+}
+
+// groupKeysByShard partitions keys by the shard responsible for each
+func (c *TTLCache) groupKeysByShard(keys []string) map[*cacheShard][]string {
+	groups := make(map[*cacheShard][]string)
+	for _, key := range keys {
+		shard := c.shardFor(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	return groups
+}
@@ -0,0 +1,197 @@
+package selectcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_GetMulti(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	cache.Set("a", []byte("1"), headers, time.Hour)
+	cache.Set("b", []byte("2"), headers, time.Hour)
+	cache.Set("stale", []byte("3"), headers, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	found := cache.GetMulti([]string{"a", "b", "stale", "missing"})
+	if len(found) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(found))
+	}
+	if string(found["a"].Data) != "1" || string(found["b"].Data) != "2" {
+		t.Errorf("Unexpected data: %q, %q", found["a"].Data, found["b"].Data)
+	}
+	if _, ok := found["stale"]; ok {
+		t.Error("Expired entries should not be returned")
+	}
+	if cache.Size() != 2 {
+		t.Errorf("Expired entry should have been removed, size is %d", cache.Size())
+	}
+
+	stats := cache.metrics.GetStats()
+	if stats.Hits != 2 {
+		t.Errorf("Expected 2 hits, got %d", stats.Hits)
+	}
+}
+
+func TestTTLCache_SetMulti(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	err := cache.SetMulti(map[string]SetItem{
+		"a": {Data: []byte("1"), TTL: time.Hour},
+		"b": {Data: []byte("2"), Headers: http.Header{"Content-Type": {"text/plain"}}, TTL: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("SetMulti failed: %v", err)
+	}
+
+	entry, found := cache.Get("b")
+	if !found || string(entry.Data) != "2" || entry.ContentType != "text/plain" {
+		t.Errorf("Unexpected entry for b: %+v", entry)
+	}
+	if got, want := int(cache.totalEntries.Load()), cache.Size(); got != 2 || got != want {
+		t.Errorf("Expected 2 tracked entries, got %d (shards report %d)", got, want)
+	}
+
+	// Replacing an entry must not leak its memory
+	cache.SetMulti(map[string]SetItem{"a": {Data: []byte("11"), TTL: time.Hour}})
+	if got, want := uint64(cache.totalMemoryBytes.Load()), cache.MemoryUsage(); got != want {
+		t.Errorf("Global memory counter %d out of sync with shard total %d", got, want)
+	}
+}
+
+func TestTTLCache_SetMultiEvictsForWholeBatch(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntries = 3
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	headers := make(http.Header)
+	cache.Set("old-1", []byte("x"), headers, time.Hour)
+	cache.Set("old-2", []byte("x"), headers, time.Hour)
+
+	cache.SetMulti(map[string]SetItem{
+		"new-1": {Data: []byte("y"), TTL: time.Hour},
+		"new-2": {Data: []byte("y"), TTL: time.Hour},
+	})
+
+	if cache.Size() != 3 {
+		t.Errorf("Expected 3 entries, got %d", cache.Size())
+	}
+	for _, key := range []string{"new-1", "new-2"} {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("Expected %s to be stored", key)
+		}
+	}
+}
+
+func TestTTLCache_SetMultiRejectsOversizedEntries(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntrySizeBytes = 4
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	err := cache.SetMulti(map[string]SetItem{
+		"small": {Data: []byte("ok"), TTL: time.Hour},
+		"large": {Data: []byte("too large"), TTL: time.Hour},
+	})
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+	}
+	if _, found := cache.Get("small"); !found {
+		t.Error("Entries within the limit should still be stored")
+	}
+	if _, found := cache.Get("large"); found {
+		t.Error("Oversized entry should not be stored")
+	}
+}
+
+func TestTTLCache_SetMultiCircuitOpen(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.StoreFailureThreshold = 1
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.storeBreaker.RecordFailure()
+
+	err := cache.SetMulti(map[string]SetItem{"a": {Data: []byte("1"), TTL: time.Hour}})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if cache.Size() != 0 {
+		t.Error("Nothing should be stored while the circuit is open")
+	}
+}
+
+// benchmarkBatchSize is the number of keys fetched per batch, roughly the
+// fragments needed to assemble one page
+const benchmarkBatchSize = 32
+
+func newBatchBenchmarkCache(b *testing.B) (*TTLCache, []string) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	headers := make(http.Header)
+	keys := make([]string, benchmarkBatchSize)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("fragment-%d", i)
+		cache.Set(keys[i], []byte("data"), headers, time.Hour)
+	}
+	return cache, keys
+}
+
+// BenchmarkTTLCache_GetLoop measures fetching a batch with one Get per key
+func BenchmarkTTLCache_GetLoop(b *testing.B) {
+	cache, keys := newBatchBenchmarkCache(b)
+	defer cache.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			cache.Get(key)
+		}
+	}
+}
+
+// BenchmarkTTLCache_GetMulti measures fetching the same batch with GetMulti
+func BenchmarkTTLCache_GetMulti(b *testing.B) {
+	cache, keys := newBatchBenchmarkCache(b)
+	defer cache.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.GetMulti(keys)
+	}
+}
+
+// BenchmarkTTLCache_SetLoop measures storing a batch with one Set per key
+func BenchmarkTTLCache_SetLoop(b *testing.B) {
+	cache, keys := newBatchBenchmarkCache(b)
+	defer cache.Close()
+	headers := make(http.Header)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			cache.Set(key, []byte("data"), headers, time.Hour)
+		}
+	}
+}
+
+// BenchmarkTTLCache_SetMulti measures storing the same batch with SetMulti
+func BenchmarkTTLCache_SetMulti(b *testing.B) {
+	cache, keys := newBatchBenchmarkCache(b)
+	defer cache.Close()
+	items := make(map[string]SetItem, len(keys))
+	for _, key := range keys {
+		items[key] = SetItem{Data: []byte("data"), TTL: time.Hour}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.SetMulti(items)
+	}
+}
@@ -0,0 +1,75 @@
+package selectcache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestMiddleware_BeforeStoreRewritesCachedCopy(t *testing.T) {
+	generatedAt := regexp.MustCompile(`,"generated_at":"[^"]*"`)
+
+	config := DefaultConfig()
+	config.BeforeStore = func(key string, resp *CachedResponse) bool {
+		resp.Body = generatedAt.ReplaceAll(resp.Body, nil)
+		resp.Headers.Set("X-Normalized", "true")
+		return true
+	}
+	middleware := New(config)
+	defer middleware.Close()
+
+	live := []byte(`{"items":[1,2,3],"generated_at":"2026-10-16T00:00:00Z"}`)
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(live)
+	}))
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest("GET", "/items", nil))
+	if !bytes.Equal(first.Body.Bytes(), live) {
+		t.Errorf("Live response should be unaffected, got %s", first.Body.String())
+	}
+	if first.Header().Get("X-Normalized") != "" {
+		t.Error("Live response headers should be unaffected")
+	}
+
+	hit := httptest.NewRecorder()
+	handler.ServeHTTP(hit, httptest.NewRequest("GET", "/items", nil))
+	if hit.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatal("Expected response from cache")
+	}
+	if got, want := hit.Body.String(), `{"items":[1,2,3]}`; got != want {
+		t.Errorf("Cached body = %s, want %s", got, want)
+	}
+	if hit.Header().Get("X-Normalized") != "true" {
+		t.Error("Expected header changes to be persisted")
+	}
+}
+
+func TestMiddleware_BeforeStoreVeto(t *testing.T) {
+	var seenKey string
+	config := DefaultConfig()
+	config.BeforeStore = func(key string, resp *CachedResponse) bool {
+		seenKey = key
+		return !bytes.Contains(resp.Body, []byte("partial"))
+	}
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"partial"}`))
+	}))
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seenKey != middleware.createCacheKey(req) {
+		t.Errorf("Expected the hook to receive the cache key, got %q", seenKey)
+	}
+	if middleware.cache.Size() != 0 {
+		t.Error("Vetoed response should not be cached")
+	}
+}
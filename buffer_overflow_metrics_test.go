@@ -0,0 +1,39 @@
+package selectcache
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestCachingConnection_BufferOverflowMetrics verifies that buffers cleared
+// for exceeding their size limits are counted in the error stats
+func TestCachingConnection_BufferOverflowMetrics(t *testing.T) {
+	config := DefaultCacheConfig()
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	mockConn := newMockConn()
+	cachingConn := NewCachingConnection(mockConn, cache, config, metrics, NewContentDetector(config))
+	defer cachingConn.Close()
+
+	// Non-HTTP traffic past the parse window clears the request buffer
+	garbage := bytes.Repeat([]byte{0xff}, 9000)
+	mockConn.writeToReadBuffer(garbage)
+	if _, err := cachingConn.Read(make([]byte, len(garbage))); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	// A write that would push the response buffer past its limit clears it
+	if _, err := cachingConn.Write(bytes.Repeat([]byte("x"), maxBufferSize+1)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	errors := metrics.GetStats().Errors
+	if errors["request_buffer_overflow"] != 1 {
+		t.Errorf("Expected 1 request_buffer_overflow, got %d", errors["request_buffer_overflow"])
+	}
+	if errors["response_buffer_overflow"] != 1 {
+		t.Errorf("Expected 1 response_buffer_overflow, got %d", errors["response_buffer_overflow"])
+	}
+}
@@ -0,0 +1,58 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_BypassFunc(t *testing.T) {
+	config := DefaultConfig()
+	config.BypassFunc = func(r *http.Request) bool {
+		cookie, err := r.Cookie("debug")
+		return err == nil && cookie.Value == "1"
+	}
+	middleware := New(config)
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	serve := func(debug bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api", nil)
+		if debug {
+			req.AddCookie(&http.Cookie{Name: "debug", Value: "1"})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Bypassed requests are not stored
+	if rec := serve(true); rec.Header().Get("X-Cache-Status") != "BYPASS" {
+		t.Errorf("Expected X-Cache-Status BYPASS, got %q", rec.Header().Get("X-Cache-Status"))
+	}
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected bypassed response not to be cached, got %d entries", items)
+	}
+
+	// Nor served from the cache once it is populated
+	serve(false)
+	if rec := serve(false); rec.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatal("Expected regular request to be served from cache")
+	}
+	before := calls
+	if rec := serve(true); rec.Header().Get("X-Cache-Status") != "BYPASS" {
+		t.Errorf("Expected X-Cache-Status BYPASS, got %q", rec.Header().Get("X-Cache-Status"))
+	}
+	if calls != before+1 {
+		t.Error("Expected bypassed request to reach the origin")
+	}
+
+	if _, hits, misses := middleware.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Expected bypassed requests not to count as hits or misses, got %d hits, %d misses", hits, misses)
+	}
+}
@@ -0,0 +1,152 @@
+package selectcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// byteRange is a resolved, inclusive byte range within a body
+type byteRange struct {
+	start, end int64
+}
+
+// errRangeNotSatisfiable reports a well-formed range that lies outside the body
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// parseByteRange resolves a single-range Range header against a body of the
+// given size. ok is false when the header is absent, malformed, or asks for
+// multiple ranges, in which case the whole body should be served.
+func parseByteRange(header string, size int64) (br byteRange, ok bool, err error) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return byteRange{}, false, nil
+	}
+
+	if first == "" {
+		// Suffix range: the final N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return byteRange{}, false, nil
+		}
+		if n == 0 || size == 0 {
+			return byteRange{}, true, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return byteRange{start: size - n, end: size - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return byteRange{}, true, errRangeNotSatisfiable
+	}
+	return byteRange{start: start, end: end}, true, nil
+}
+
+// ifRangeMatches reports whether an If-Range validator still identifies the
+// cached representation. Entity tags use strong comparison, so weak tags never
+// match; dates must equal Last-Modified exactly. Malformed values don't match.
+func ifRangeMatches(ifRange string, headers http.Header) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		etag := headers.Get("ETag")
+		return !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+
+	date, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(headers.Get("Last-Modified"))
+	return err == nil && date.Equal(lastModified)
+}
+
+// rangeEnabled reports whether Range requests may be served from a cached response
+func (m *Middleware) rangeEnabled(cached *CachedResponse) bool {
+	if cached.StatusCode != http.StatusOK {
+		return false
+	}
+	return m.rangeRequests || strings.EqualFold(cached.Headers.Get("Accept-Ranges"), "bytes")
+}
+
+// writeRangeResponse answers a single-range request from a cached body with
+// 206 Partial Content or 416 Range Not Satisfiable. It returns false when the
+// request should be served whole instead, including when If-Range no longer
+// matches the cached entry. Headers must already be populated.
+func (m *Middleware) writeRangeResponse(w http.ResponseWriter, r *http.Request, cached *CachedResponse) bool {
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" || !m.rangeEnabled(cached) {
+		return false
+	}
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && !ifRangeMatches(ifRange, cached.Headers) {
+		return false
+	}
+
+	size := int64(len(cached.Body))
+	br, ok, err := parseByteRange(rangeHeader, size)
+	if !ok {
+		return false
+	}
+
+	if err != nil {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return true
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", br.start, br.end, size))
+	w.Header().Set("Content-Length", strconv.FormatInt(br.end-br.start+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+
+	if r.Method != http.MethodHead {
+		w.Write(cached.Body[br.start : br.end+1])
+	}
+	return true
+}
+
+// coversWholeRepresentation reports whether a 206 Partial Content response's
+// Content-Range spans its complete representation, which makes its body the
+// full 200 body. Partial ranges, and multipart/byteranges responses without a
+// Content-Range, are never stored: ranges are not assembled across responses.
+func coversWholeRepresentation(headers http.Header, body []byte) bool {
+	spec, found := strings.CutPrefix(strings.TrimSpace(headers.Get("Content-Range")), "bytes ")
+	if !found {
+		return false
+	}
+	span, total, found := strings.Cut(spec, "/")
+	if !found {
+		return false
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil || size <= 0 || size != int64(len(body)) {
+		return false
+	}
+	return strings.TrimSpace(span) == fmt.Sprintf("0-%d", size-1)
+}
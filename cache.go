@@ -1,50 +1,201 @@
 package selectcache
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"math/rand/v2"
 	"net/http"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrEntryTooLarge is returned by TTLCache.Set when an entry exceeds
+// CacheConfig.MaxEntrySizeBytes
+var ErrEntryTooLarge = errors.New("cache entry exceeds maximum entry size")
+
+// ErrEvictionBatchExceeded is returned by TTLCache.Set when making room for an
+// entry would take more than CacheConfig.MaxEvictionBatch evictions
+var ErrEvictionBatchExceeded = errors.New("cache store needs more evictions than the eviction batch allows")
+
+// ErrStoreUnavailable is returned by TTLCache.Set when the backing store
+// can't be reached. The in-memory store never returns it.
+var ErrStoreUnavailable = errors.New("cache store unavailable")
+
+// ErrSerialization is returned by TTLCache.Set when an entry can't be encoded
+// for the backing store. The in-memory store never returns it.
+var ErrSerialization = errors.New("cache entry serialization failed")
+
+// storeErrorMetrics names the error counter recorded for each store error;
+// other errors are recorded as cache_store_failed
+var storeErrorMetrics = []struct {
+	err  error
+	name string
+}{
+	{ErrEntryTooLarge, "entry_too_large"},
+	{ErrCircuitOpen, "cache_store_circuit_open"},
+	{ErrEvictionBatchExceeded, "eviction_batch_exceeded"},
+	{ErrStoreUnavailable, "cache_store_unavailable"},
+	{ErrSerialization, "cache_store_serialization"},
+}
+
+// storeErrorMetric returns the error counter name for a store error
+func storeErrorMetric(err error) string {
+	for _, known := range storeErrorMetrics {
+		if errors.Is(err, known.err) {
+			return known.name
+		}
+	}
+	return "cache_store_failed"
+}
+
+// defaultShardCount is the number of partitions used when CacheConfig.ShardCount is unset
+const defaultShardCount = 16
+
 // CacheEntry represents a single cached response with metadata
 type CacheEntry struct {
 	// Response data
-	Data    []byte      `json:"data"`
-	Headers http.Header `json:"headers"`
+	StatusCode int         `json:"status_code,omitempty"`
+	StatusText string      `json:"status_text,omitempty"`
+	Data       []byte      `json:"data"`
+	Headers    http.Header `json:"headers"`
+
+	// Encoding is the compression algorithm Data is stored with (see
+	// Config.CompressionAlgorithm); empty when stored uncompressed
+	Encoding string `json:"encoding,omitempty"`
+
+	// Trailers are the HTTP trailers sent after Data, if any
+	Trailers http.Header `json:"trailers,omitempty"`
 
 	// Timing information
 	ExpiresAt  time.Time `json:"expires_at"`
 	AccessTime time.Time `json:"access_time"`
 	StoreTime  time.Time `json:"store_time"`
 
+	// FreshUntil, when set, ends the entry's freshness lifetime before it
+	// expires, so it is only kept as a stale fallback (see CachedResponse)
+	FreshUntil time.Time `json:"fresh_until,omitempty"`
+
+	// Immutable marks an entry stored from a Cache-Control: immutable
+	// response; it is never stale, whatever FreshUntil says
+	Immutable bool `json:"immutable,omitempty"`
+
 	// Metadata
 	ContentType string `json:"content_type"`
+	ETag        string `json:"etag,omitempty"`
 	Size        int    `json:"size"`
+	AccessCount uint64 `json:"access_count"`
+
+	// Eviction bookkeeping; segment is nil outside any SegmentQuotas class
+	key       string
+	heapIndex int
+	segment   *cacheSegment
+
+	// ttl is the lifetime applied when the entry was stored or last touched;
+	// baseTTL is the one asked for before TTLJitter, which refresh-ahead
+	// stores the new response with so jitter isn't compounded
+	ttl     time.Duration
+	baseTTL time.Duration
+
+	// clock is the owning cache's time source; nil uses time.Now
+	clock Clock
+}
+
+// now returns the current time from the entry's clock
+func (e *CacheEntry) now() time.Time {
+	if e.clock == nil {
+		return time.Now()
+	}
+	return e.clock.Now()
 }
 
 // IsExpired checks if the cache entry has expired
 func (e *CacheEntry) IsExpired() bool {
-	return time.Now().After(e.ExpiresAt)
+	return e.now().After(e.ExpiresAt)
+}
+
+// RemainingTTL returns how long until the entry expires, or zero once it has
+func (e *CacheEntry) RemainingTTL() time.Duration {
+	if remaining := e.ExpiresAt.Sub(e.now()); remaining > 0 {
+		return remaining
+	}
+	return 0
 }
 
-// UpdateAccessTime updates the last access time for LRU tracking
+// IsStale reports whether the entry has outlived its freshness lifetime and
+// is only being kept as a fallback. Entries without one, and immutable
+// entries, are never stale before they expire.
+func (e *CacheEntry) IsStale() bool {
+	return !e.Immutable && !e.FreshUntil.IsZero() && e.now().After(e.FreshUntil)
+}
+
+// UpdateAccessTime updates the last access time and access count for LRU/LFU tracking
 func (e *CacheEntry) UpdateAccessTime() {
-	e.AccessTime = time.Now()
+	e.AccessTime = e.now()
+	e.AccessCount++
+}
+
+// cacheShard is one partition of a TTLCache with its own lock, entries,
+// eviction order, and memory counter. Entries in a segment are ordered in
+// that segment's heap rather than the shard's main one.
+type cacheShard struct {
+	mu           sync.RWMutex
+	entries      map[string]*CacheEntry
+	eviction     *evictionHeap
+	segmentHeaps []*evictionHeap
+	memoryBytes  uint64
 }
 
-// TTLCache provides thread-safe cache storage with TTL and LRU eviction
+// heapFor returns the eviction heap ordering an entry
+func (s *cacheShard) heapFor(entry *CacheEntry) *evictionHeap {
+	if entry.segment == nil {
+		return s.eviction
+	}
+	return s.segmentHeaps[entry.segment.index]
+}
+
+// heaps returns all of the shard's eviction heaps
+func (s *cacheShard) heaps() []*evictionHeap {
+	return append([]*evictionHeap{s.eviction}, s.segmentHeaps...)
+}
+
+// TTLCache provides thread-safe cache storage with TTL and LRU or LFU eviction.
+// Entries are partitioned into shards by key hash to reduce lock contention;
+// memory and entry limits are enforced approximately across all shards.
 type TTLCache struct {
-	mu      sync.RWMutex
-	entries map[string]*CacheEntry
+	shards  []*cacheShard
 	config  *CacheConfig
 	metrics *CacheMetrics
 
-	// Memory tracking
-	currentMemoryBytes uint64
+	// Global memory and entry tracking across shards
+	totalMemoryBytes atomic.Int64
+	totalEntries     atomic.Int64
+
+	// Memory and entry limits, which SetLimits can change at runtime
+	maxMemoryBytes atomic.Int64
+	maxEntries     atomic.Int64
+
+	// Content-type segments with their own memory quotas, by class
+	segments map[string]*cacheSegment
+
+	// In-flight GetOrSet computations, keyed by cache key
+	inflightMu sync.Mutex
+	inflight   map[string]*inflightCall
+
+	// Skips stores while the backing store is failing
+	storeBreaker *CircuitBreaker
+
+	// storeFault, when set, fails writes to the backing store. The in-memory
+	// store never fails; tests use it to stand in for a failing backend.
+	storeFault func(key string) error
+
+	// Time source for expiry and access times
+	clock Clock
 
 	// Cleanup timer
 	cleanupTimer *time.Timer
@@ -58,11 +209,36 @@ func NewTTLCache(config *CacheConfig, metrics *CacheMetrics) *TTLCache {
 		config = DefaultCacheConfig()
 	}
 
+	shardCount := config.ShardCount
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+
 	cache := &TTLCache{
-		entries:     make(map[string]*CacheEntry),
+		shards:      make([]*cacheShard, shardCount),
 		config:      config,
 		metrics:     metrics,
+		inflight:    make(map[string]*inflightCall),
 		stopCleanup: make(chan struct{}),
+
+		storeBreaker: NewCircuitBreaker(config.StoreFailureThreshold, config.StoreCircuitCooldown),
+		clock:        config.Clock,
+		segments:     newCacheSegments(config.SegmentQuotas),
+	}
+	if cache.clock == nil {
+		cache.clock = systemClock{}
+	}
+	cache.maxMemoryBytes.Store(config.MaxMemoryMB * 1024 * 1024)
+	cache.maxEntries.Store(int64(config.MaxEntries))
+	for i := range cache.shards {
+		cache.shards[i] = &cacheShard{
+			entries:      make(map[string]*CacheEntry),
+			eviction:     newEvictionHeap(config.EvictionPolicy),
+			segmentHeaps: make([]*evictionHeap, len(cache.segments)),
+		}
+		for j := range cache.shards[i].segmentHeaps {
+			cache.shards[i].segmentHeaps[j] = newEvictionHeap(config.EvictionPolicy)
+		}
 	}
 
 	// Start cleanup routine
@@ -71,31 +247,124 @@ func NewTTLCache(config *CacheConfig, metrics *CacheMetrics) *TTLCache {
 	return cache
 }
 
+// shardFor returns the shard responsible for a key
+func (c *TTLCache) shardFor(key string) *cacheShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
 // Get retrieves a cached entry by key
 func (c *TTLCache) Get(key string) (*CacheEntry, bool) {
 	start := time.Now()
 	defer c.recordLookupMetrics(start)
 
+	shard := c.shardFor(key)
+
 	// Use write lock since we need to update access time
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	shard.mu.Lock()
+	entry, expired := c.getUnsafe(shard, key)
+	shard.mu.Unlock()
 
-	entry, exists := c.entries[key]
+	if expired != nil {
+		c.notifyEvict(expired)
+	}
+	if entry == nil {
+		c.logMiss(key)
+		return nil, false
+	}
+
+	if c.config.Logger != nil {
+		c.config.Logger.OnHit(key, "")
+	}
+
+	return entry, true
+}
+
+// Has reports whether an unexpired entry exists for key. Unlike Get it takes
+// only the read lock and leaves access time, eviction order and metrics alone.
+func (c *TTLCache) Has(key string) bool {
+	_, found := c.peek(key)
+	return found
+}
+
+// getUnsafe returns the unexpired entry for key, updating its access time and
+// recording the hit or miss. An expired entry is removed and returned as
+// expired so the caller can report it once the lock is released.
+// Caller must hold the shard write lock.
+func (c *TTLCache) getUnsafe(shard *cacheShard, key string) (entry, expired *CacheEntry) {
+	entry, exists := shard.entries[key]
 	if !exists {
 		c.recordCacheMiss()
-		return nil, false
+		return nil, nil
 	}
 
 	if entry.IsExpired() {
-		c.removeExpiredEntryUnsafe(key, entry)
-		return nil, false
+		c.removeExpiredEntryUnsafe(shard, entry)
+		return nil, entry
 	}
 
-	// Update access time for LRU (now safe under write lock)
+	// Update access time for LRU/LFU and reposition in the eviction heap
 	entry.UpdateAccessTime()
+	shard.heapFor(entry).update(entry)
+	if c.config.SlidingExpiration {
+		entry.ExpiresAt = entry.AccessTime.Add(entry.ttl)
+	}
 	c.recordCacheHit()
+	return entry, nil
+}
 
-	return entry, true
+// Touch extends the lifetime of an unexpired entry to ttl from now without
+// copying its data; a ttl of zero reuses the entry's current TTL. Access time,
+// eviction order and memory accounting are unchanged. It reports whether the
+// entry was found.
+func (c *TTLCache) Touch(key string, ttl time.Duration) bool {
+	shard := c.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := shard.entries[key]
+	if !exists || entry.IsExpired() {
+		return false
+	}
+
+	if ttl > 0 {
+		entry.ttl = ttl
+		entry.baseTTL = ttl
+	}
+	entry.ExpiresAt = c.clock.Now().Add(entry.ttl)
+	return true
+}
+
+// GetContext retrieves a cached entry by key, giving up if ctx is cancelled or
+// past its deadline. Lookups in the in-memory cache never block, so ctx is only
+// checked up front; the signature lets remote stores abort slow lookups.
+func (c *TTLCache) GetContext(ctx context.Context, key string) (*CacheEntry, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	return c.Get(key)
+}
+
+// logMiss reports a cache miss to the configured logger, if any.
+func (c *TTLCache) logMiss(key string) {
+	if c.config.Logger != nil {
+		c.config.Logger.OnMiss(key, "")
+	}
+}
+
+// notifyEvict reports an entry that left the cache to the configured logger and
+// OnEvict callback, if any. Must be called without holding any shard lock.
+func (c *TTLCache) notifyEvict(entry *CacheEntry) {
+	if c.config.Logger != nil {
+		c.config.Logger.OnEvict(entry.key)
+	}
+	if c.config.OnEvict != nil {
+		c.config.OnEvict(entry.key, entry)
+	}
 }
 
 // recordLookupMetrics records the time taken for cache lookup operations.
@@ -105,14 +374,6 @@ func (c *TTLCache) recordLookupMetrics(start time.Time) {
 	}
 }
 
-// retrieveEntry safely retrieves a cache entry by key using read lock.
-func (c *TTLCache) retrieveEntry(key string) (*CacheEntry, bool) {
-	c.mu.RLock()
-	entry, exists := c.entries[key]
-	c.mu.RUnlock()
-	return entry, exists
-}
-
 // recordCacheMiss records a cache miss event in metrics if available.
 func (c *TTLCache) recordCacheMiss() {
 	if c.metrics != nil {
@@ -127,90 +388,237 @@ func (c *TTLCache) recordCacheHit() {
 	}
 }
 
-// removeExpiredEntry removes an expired cache entry and updates memory tracking.
-func (c *TTLCache) removeExpiredEntry(key string, entry *CacheEntry) {
-	c.mu.Lock()
-	delete(c.entries, key)
-	c.currentMemoryBytes -= uint64(entry.Size)
-	c.mu.Unlock()
-
+// updateMemoryMetrics reports the current global memory usage and entry count.
+func (c *TTLCache) updateMemoryMetrics() {
 	if c.metrics != nil {
-		c.metrics.RecordMiss()
-		c.metrics.UpdateMemoryUsage(c.currentMemoryBytes, len(c.entries))
+		c.metrics.UpdateMemoryUsage(uint64(c.totalMemoryBytes.Load()), int(c.totalEntries.Load()))
 	}
 }
 
 // removeExpiredEntryUnsafe removes an expired cache entry without acquiring locks.
-// Caller must already hold the write lock.
-func (c *TTLCache) removeExpiredEntryUnsafe(key string, entry *CacheEntry) {
-	delete(c.entries, key)
-	c.currentMemoryBytes -= uint64(entry.Size)
+// Caller must already hold the shard write lock.
+func (c *TTLCache) removeExpiredEntryUnsafe(shard *cacheShard, entry *CacheEntry) {
+	c.removeEntryUnsafe(shard, entry)
 
 	if c.metrics != nil {
 		c.metrics.RecordMiss()
-		c.metrics.UpdateMemoryUsage(c.currentMemoryBytes, len(c.entries))
+		c.updateMemoryMetrics()
+	}
+}
+
+// removeEntryUnsafe removes an entry from its shard's map and eviction heap and
+// updates memory tracking. Caller must hold the shard write lock.
+func (c *TTLCache) removeEntryUnsafe(shard *cacheShard, entry *CacheEntry) {
+	delete(shard.entries, entry.key)
+	shard.heapFor(entry).remove(entry)
+	shard.memoryBytes -= uint64(entry.Size)
+	if entry.segment != nil {
+		entry.segment.memoryBytes.Add(-int64(entry.Size))
 	}
+	c.totalMemoryBytes.Add(-int64(entry.Size))
+	c.totalEntries.Add(-1)
+}
+
+// jitterTTL spreads a TTL uniformly within ±TTLJitter of its value
+func (c *TTLCache) jitterTTL(ttl time.Duration) time.Duration {
+	if c.config.TTLJitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	offset := (rand.Float64()*2 - 1) * c.config.TTLJitter * float64(ttl)
+	return ttl + time.Duration(offset)
 }
 
 // createCacheEntry creates a new cache entry with copied data and headers.
-func (c *TTLCache) createCacheEntry(data []byte, headers http.Header, ttl time.Duration) *CacheEntry {
+// Headers that must not be replayed from cache are filtered out.
+func (c *TTLCache) createCacheEntry(key string, data []byte, headers http.Header, ttl time.Duration) *CacheEntry {
+	headers = filterHeaders(headers, c.config.StripHeaders, c.config.AllowHeaders)
+	baseTTL := ttl
+	ttl = c.jitterTTL(ttl)
+	now := c.clock.Now()
+
 	entry := &CacheEntry{
+		key:        key,
+		heapIndex:  -1,
+		ttl:        ttl,
+		baseTTL:    baseTTL,
+		clock:      c.clock,
 		Data:       make([]byte, len(data)),
-		Headers:    make(http.Header),
-		ExpiresAt:  time.Now().Add(ttl),
-		AccessTime: time.Now(),
-		StoreTime:  time.Now(),
-		Size:       len(data) + c.calculateHeaderSize(headers),
+		Headers:    headers,
+		ExpiresAt:  now.Add(ttl),
+		AccessTime: now,
+		StoreTime:  now,
+		Size:       len(data) + headerSize(headers),
 	}
 
-	// Copy data and headers
+	// Copy data
 	copy(entry.Data, data)
-	for k, v := range headers {
-		entry.Headers[k] = make([]string, len(v))
-		copy(entry.Headers[k], v)
-	}
 
-	// Extract content type
+	// Extract content type and validator
 	entry.ContentType = headers.Get("Content-Type")
+	entry.ETag = headers.Get("ETag")
+	entry.segment = c.segmentFor(entry.ContentType)
 	return entry
 }
 
-// checkMemoryLimits verifies cache limits and evicts entries if necessary.
-func (c *TTLCache) checkMemoryLimits(entrySize uint64) {
-	newMemoryUsage := c.currentMemoryBytes + entrySize
-	maxMemoryBytes := uint64(c.config.MaxMemoryMB) * 1024 * 1024
+// checkMemoryLimits evicts entries across shards until entryCount entries
+// totalling entrySize bytes fit within the memory and entry limits, returning
+// the evicted entries. fits is false when MaxEvictionBatch evictions were not
+// enough. Must be called without holding any shard lock.
+func (c *TTLCache) checkMemoryLimits(entrySize uint64, entryCount int) (evicted []*CacheEntry, fits bool) {
+	for {
+		newMemoryUsage := uint64(c.totalMemoryBytes.Load()) + entrySize
+		if newMemoryUsage <= uint64(c.maxMemoryBytes.Load()) && c.totalEntries.Load()+int64(entryCount) <= c.maxEntries.Load() {
+			break
+		}
+		if limit := c.config.MaxEvictionBatch; limit > 0 && len(evicted) >= limit {
+			return evicted, false
+		}
 
-	if newMemoryUsage > maxMemoryBytes || len(c.entries) >= c.config.MaxEntries {
-		// Need to evict entries
-		evicted := c.evictLRU(newMemoryUsage - maxMemoryBytes + entrySize)
+		entry, ok := c.evictNext(nil)
+		if !ok {
+			break
+		}
+		evicted = append(evicted, entry)
 		if c.metrics != nil {
-			for i := 0; i < evicted; i++ {
-				c.metrics.RecordEviction()
-			}
+			c.metrics.RecordEviction()
 		}
 	}
+	return evicted, true
 }
 
-// removeExistingEntry removes any existing cache entry for the given key.
-func (c *TTLCache) removeExistingEntry(key string) {
-	if existingEntry, exists := c.entries[key]; exists {
-		c.currentMemoryBytes -= uint64(existingEntry.Size)
+// removeExistingEntry removes any existing cache entry for the given key,
+// returning it. Caller must hold the shard write lock.
+func (c *TTLCache) removeExistingEntry(shard *cacheShard, key string) *CacheEntry {
+	existingEntry, exists := shard.entries[key]
+	if !exists {
+		return nil
+	}
+	c.removeEntryUnsafe(shard, existingEntry)
+	return existingEntry
+}
+
+// dropReplaced reports entries removed to make way for a store that then
+// failed: they are gone without a replacement, so they count as deletions
+// and are passed to OnEvict like any other entry leaving the cache
+func (c *TTLCache) dropReplaced(replaced []*CacheEntry) {
+	if c.metrics != nil {
+		for range replaced {
+			c.metrics.RecordDeletion()
+		}
+		c.updateMemoryMetrics()
+	}
+	for _, entry := range replaced {
+		c.notifyEvict(entry)
 	}
 }
 
 // storeCacheEntry stores the entry and updates metrics.
-func (c *TTLCache) storeCacheEntry(key string, entry *CacheEntry) {
-	c.entries[key] = entry
-	c.currentMemoryBytes += uint64(entry.Size)
+// Caller must hold the shard write lock.
+func (c *TTLCache) storeCacheEntry(shard *cacheShard, entry *CacheEntry) {
+	// A concurrent Set may have stored the same key since it was removed
+	c.removeExistingEntry(shard, entry.key)
+
+	shard.entries[entry.key] = entry
+	shard.heapFor(entry).add(entry)
+	shard.memoryBytes += uint64(entry.Size)
+	if entry.segment != nil {
+		entry.segment.memoryBytes.Add(int64(entry.Size))
+	}
+	c.totalMemoryBytes.Add(int64(entry.Size))
+	c.totalEntries.Add(1)
 
 	if c.metrics != nil {
 		c.metrics.RecordStore()
-		c.metrics.UpdateMemoryUsage(c.currentMemoryBytes, len(c.entries))
+		c.updateMemoryMetrics()
 	}
 }
 
 // Set stores a cache entry with the specified TTL
 func (c *TTLCache) Set(key string, data []byte, headers http.Header, ttl time.Duration) error {
+	_, err := c.store(key, data, headers, ttl)
+	return err
+}
+
+// setResponse stores a complete HTTP response, keeping its status code and
+// freshness lifetime alongside the body and headers
+func (c *TTLCache) setResponse(key string, resp *CachedResponse, ttl time.Duration) error {
+	entry := c.createCacheEntry(key, resp.Body, resp.Headers, ttl)
+	entry.StatusCode = resp.StatusCode
+	entry.FreshUntil = resp.FreshUntil
+	entry.Immutable = resp.Immutable
+	entry.Encoding = resp.Encoding
+	if len(resp.Trailers) > 0 {
+		entry.Trailers = resp.Trailers.Clone()
+		entry.Size += headerSize(entry.Trailers)
+	}
+	_, err := c.insert(entry)
+	return err
+}
+
+// getResponse retrieves a cached entry as an HTTP response. Entries stored
+// without a status code are reported as 200 OK.
+func (c *TTLCache) getResponse(key string) (*CachedResponse, bool) {
+	return c.getResponseContext(context.Background(), key)
+}
+
+// getResponseContext is getResponse through GetContext, giving up once ctx
+// is done
+func (c *TTLCache) getResponseContext(ctx context.Context, key string) (*CachedResponse, bool) {
+	entry, found := c.GetContext(ctx, key)
+	if !found {
+		return nil, false
+	}
+
+	statusCode := entry.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return &CachedResponse{
+		StatusCode: statusCode,
+		Headers:    entry.Headers,
+		Body:       entry.Data,
+		FreshUntil: entry.FreshUntil,
+		Immutable:  entry.Immutable,
+		Encoding:   entry.Encoding,
+		Trailers:   entry.Trailers,
+		StoreTime:  entry.StoreTime,
+		ExpiresAt:  entry.ExpiresAt,
+	}, true
+}
+
+// store creates and stores a cache entry, returning the stored entry
+func (c *TTLCache) store(key string, data []byte, headers http.Header, ttl time.Duration) (*CacheEntry, error) {
+	return c.insert(c.createCacheEntry(key, data, headers, ttl))
+}
+
+// recordStoreError counts a failed store under its error's category
+func (c *TTLCache) recordStoreError(err error) {
+	if c.metrics != nil {
+		c.metrics.RecordError(storeErrorMetric(err))
+	}
+}
+
+// backendError reports whether the backing store failed to take key
+func (c *TTLCache) backendError(key string) error {
+	if c.storeFault != nil {
+		return c.storeFault(key)
+	}
+	return nil
+}
+
+// abandonStore reports the entries that left the cache on the way to a store
+// that then failed with err
+func (c *TTLCache) abandonStore(evicted, replaced []*CacheEntry, err error) {
+	for _, evictedEntry := range evicted {
+		c.notifyEvict(evictedEntry)
+	}
+	c.dropReplaced(replaced)
+	c.recordStoreError(err)
+}
+
+// insert stores a prepared cache entry, returning it. While the store circuit
+// is open the entry is not stored, and is returned along with ErrCircuitOpen.
+func (c *TTLCache) insert(entry *CacheEntry) (*CacheEntry, error) {
 	start := time.Now()
 	defer func() {
 		if c.metrics != nil {
@@ -218,66 +626,235 @@ func (c *TTLCache) Set(key string, data []byte, headers http.Header, ttl time.Du
 		}
 	}()
 
-	entry := c.createCacheEntry(data, headers, ttl)
+	key := entry.key
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	// Reject oversized entries outright rather than evicting others for them
+	if limit := c.config.MaxEntrySizeBytes; limit > 0 && int64(entry.Size) > limit {
+		c.recordStoreError(ErrEntryTooLarge)
+		return nil, ErrEntryTooLarge
+	}
 
-	c.checkMemoryLimits(uint64(entry.Size))
-	c.removeExistingEntry(key)
-	c.storeCacheEntry(key, entry)
+	// Past this point the store circuit breaker hears how the store went
+	if !c.storeBreaker.Allow() {
+		c.recordStoreError(ErrCircuitOpen)
+		return entry, ErrCircuitOpen
+	}
 
-	return nil
+	shard := c.shardFor(key)
+
+	// Drop the entry being replaced so it doesn't count against the limits
+	shard.mu.Lock()
+	replaced := c.removeExistingEntry(shard, key)
+	shard.mu.Unlock()
+
+	// Make room before storing so the new entry is never an eviction candidate,
+	// first within its segment's quota and then within the overall limits
+	evicted := c.makeSegmentRoom([]*CacheEntry{entry})
+	limitEvicted, fits := c.checkMemoryLimits(uint64(entry.Size), 1)
+	evicted = append(evicted, limitEvicted...)
+	var dropped []*CacheEntry
+	if replaced != nil {
+		dropped = []*CacheEntry{replaced}
+	}
+	if !fits {
+		// Running out of room says nothing about the backing store's health
+		c.abandonStore(evicted, dropped, ErrEvictionBatchExceeded)
+		c.storeBreaker.release()
+		return nil, ErrEvictionBatchExceeded
+	}
+	if err := c.backendError(key); err != nil {
+		c.abandonStore(evicted, dropped, err)
+		c.storeBreaker.RecordFailure()
+		return nil, err
+	}
+
+	shard.mu.Lock()
+	c.storeCacheEntry(shard, entry)
+	shard.mu.Unlock()
+	c.storeBreaker.RecordSuccess()
+
+	for _, evictedEntry := range evicted {
+		c.notifyEvict(evictedEntry)
+	}
+	if c.config.Logger != nil {
+		c.config.Logger.OnStore(key, entry.Size)
+	}
+
+	return entry, nil
 }
 
 // Delete removes a cache entry by key
 func (c *TTLCache) Delete(key string) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	shard := c.shardFor(key)
 
-	if entry, exists := c.entries[key]; exists {
-		delete(c.entries, key)
-		c.currentMemoryBytes -= uint64(entry.Size)
+	shard.mu.Lock()
+	entry, exists := shard.entries[key]
+	if exists {
+		c.removeEntryUnsafe(shard, entry)
 
 		if c.metrics != nil {
 			c.metrics.RecordDeletion()
-			c.metrics.UpdateMemoryUsage(c.currentMemoryBytes, len(c.entries))
+			c.updateMemoryMetrics()
+		}
+	}
+	shard.mu.Unlock()
+
+	if exists {
+		c.notifyEvict(entry)
+	}
+	return exists
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix within the
+// cache's KeyPrefix namespace, returning how many were removed. prefix is
+// relative to KeyPrefix, so DeleteByPrefix("") empties just the namespace and
+// entries of other services sharing the store are never touched.
+func (c *TTLCache) DeleteByPrefix(prefix string) int {
+	prefix = c.config.KeyPrefix + prefix
+	var deleted []*CacheEntry
+
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if strings.HasPrefix(key, prefix) {
+				c.removeEntryUnsafe(shard, entry)
+				deleted = append(deleted, entry)
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	if c.metrics != nil && len(deleted) > 0 {
+		for range deleted {
+			c.metrics.RecordDeletion()
 		}
-		return true
+		c.updateMemoryMetrics()
 	}
 
-	return false
+	for _, entry := range deleted {
+		c.notifyEvict(entry)
+	}
+	return len(deleted)
 }
 
 // Clear removes all cache entries
 func (c *TTLCache) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	entryCount := len(c.entries)
-	c.entries = make(map[string]*CacheEntry)
-	c.currentMemoryBytes = 0
+	entryCount := 0
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		entryCount += len(shard.entries)
+		c.totalMemoryBytes.Add(-int64(shard.memoryBytes))
+		c.totalEntries.Add(-int64(len(shard.entries)))
+		shard.entries = make(map[string]*CacheEntry)
+		for _, h := range shard.heaps() {
+			h.reset()
+		}
+		shard.memoryBytes = 0
+		shard.mu.Unlock()
+	}
+	for _, segment := range c.segments {
+		segment.memoryBytes.Store(0)
+	}
 
 	if c.metrics != nil {
 		for i := 0; i < entryCount; i++ {
 			c.metrics.RecordDeletion()
 		}
-		c.metrics.UpdateMemoryUsage(0, 0)
+		c.updateMemoryMetrics()
 	}
 }
 
+// SetLimits changes the cache's memory and entry limits, immediately evicting
+// entries until the cache is within them, and returns how many were evicted
+func (c *TTLCache) SetLimits(maxMemoryMB int64, maxEntries int) int {
+	c.maxMemoryBytes.Store(maxMemoryMB * 1024 * 1024)
+	c.maxEntries.Store(int64(maxEntries))
+
+	// Evict in as many batches as MaxEvictionBatch requires
+	var evicted []*CacheEntry
+	for {
+		batch, fits := c.checkMemoryLimits(0, 0)
+		evicted = append(evicted, batch...)
+		if fits {
+			break
+		}
+	}
+	for _, segment := range c.segments {
+		evicted = append(evicted, c.trimSegment(segment, 0)...)
+	}
+
+	if c.metrics != nil && len(evicted) > 0 {
+		c.updateMemoryMetrics()
+	}
+	for _, entry := range evicted {
+		c.notifyEvict(entry)
+	}
+	return len(evicted)
+}
+
+// StoreCircuitState returns the state of the store circuit breaker
+func (c *TTLCache) StoreCircuitState() CircuitState {
+	return c.storeBreaker.State()
+}
+
 // Size returns the current number of entries in the cache
 func (c *TTLCache) Size() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return len(c.entries)
+	size := 0
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		size += len(shard.entries)
+		shard.mu.RUnlock()
+	}
+	return size
 }
 
 // MemoryUsage returns the current memory usage in bytes
 func (c *TTLCache) MemoryUsage() uint64 {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.currentMemoryBytes
+	var usage uint64
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		usage += shard.memoryBytes
+		shard.mu.RUnlock()
+	}
+	return usage
+}
+
+// Recompute recalculates memory usage and entry counts from the entries
+// actually stored, correcting any drift in the per-shard and global counters.
+// Every shard is locked for the duration, so the counters are set from a
+// consistent snapshot. It returns the correction applied to the global memory
+// counter in bytes.
+func (c *TTLCache) Recompute() int64 {
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+	}
+
+	var totalBytes, totalEntries int64
+	segmentBytes := make(map[*cacheSegment]int64, len(c.segments))
+	for _, shard := range c.shards {
+		var shardBytes uint64
+		for _, entry := range shard.entries {
+			shardBytes += uint64(entry.Size)
+			if entry.segment != nil {
+				segmentBytes[entry.segment] += int64(entry.Size)
+			}
+		}
+		shard.memoryBytes = shardBytes
+		totalBytes += int64(shardBytes)
+		totalEntries += int64(len(shard.entries))
+	}
+	drift := totalBytes - c.totalMemoryBytes.Swap(totalBytes)
+	c.totalEntries.Store(totalEntries)
+	for _, segment := range c.segments {
+		segment.memoryBytes.Store(segmentBytes[segment])
+	}
+
+	for _, shard := range c.shards {
+		shard.mu.Unlock()
+	}
+
+	c.updateMemoryMetrics()
+	return drift
 }
 
 // Close stops the cleanup routine and releases resources
@@ -287,71 +864,80 @@ func (c *TTLCache) Close() {
 		if c.cleanupTimer != nil {
 			c.cleanupTimer.Stop()
 		}
+		if c.config.CleanupScheduler != nil {
+			c.config.CleanupScheduler.deregister(c)
+		}
 	})
 }
 
-// entryWithKey pairs a cache key with its entry for sorting operations
-type entryWithKey struct {
-	key   string
-	entry *CacheEntry
-}
-
-// evictLRU removes least recently used entries to free up the specified amount of memory
-// Must be called with write lock held
-func (c *TTLCache) evictLRU(bytesToFree uint64) int {
-	if len(c.entries) == 0 {
-		return 0
+// evictNext evicts the entry that the eviction policy ranks first across all
+// shards, returning the removed entry. With a segment it only considers that
+// segment's entries; without one it prefers a segment over its quota, if any.
+// Shards are locked one at a time, so under concurrent writes the choice is
+// approximate.
+func (c *TTLCache) evictNext(segment *cacheSegment) (*CacheEntry, bool) {
+	if segment == nil {
+		segment = c.overQuotaSegment()
 	}
 
-	sortedEntries := c.buildSortableEntries()
-	c.sortEntriesByAccessTime(sortedEntries)
-	return c.performEviction(sortedEntries, bytesToFree)
-}
+	var victimShard *cacheShard
+	var victimHeap *evictionHeap
+	var victimAccess time.Time
+	var victimCount uint64
 
-// buildSortableEntries creates a slice of entries with their keys for LRU processing
-func (c *TTLCache) buildSortableEntries() []entryWithKey {
-	entries := make([]entryWithKey, 0, len(c.entries))
-	for key, entry := range c.entries {
-		entries = append(entries, entryWithKey{key: key, entry: entry})
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		heaps := shard.heaps()
+		if segment != nil {
+			heaps = shard.segmentHeaps[segment.index : segment.index+1]
+		}
+		for _, h := range heaps {
+			root := h.peek()
+			if root == nil {
+				continue
+			}
+			if victimShard == nil || h.ranksBefore(root.AccessCount, root.AccessTime, victimCount, victimAccess) {
+				victimShard = shard
+				victimHeap = h
+				victimAccess = root.AccessTime
+				victimCount = root.AccessCount
+			}
+		}
+		shard.mu.RUnlock()
 	}
-	return entries
-}
 
-// sortEntriesByAccessTime sorts entries by access time with oldest entries first
-func (c *TTLCache) sortEntriesByAccessTime(entries []entryWithKey) {
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].entry.AccessTime.Before(entries[j].entry.AccessTime)
-	})
-}
-
-// performEviction removes entries from cache until the specified bytes are freed
-func (c *TTLCache) performEviction(entries []entryWithKey, bytesToFree uint64) int {
-	var freedBytes uint64
-	evicted := 0
+	if victimShard == nil {
+		return nil, false
+	}
 
-	for _, e := range entries {
-		delete(c.entries, e.key)
-		freedBytes += uint64(e.entry.Size)
-		evicted++
+	victimShard.mu.Lock()
+	defer victimShard.mu.Unlock()
 
-		if freedBytes >= bytesToFree {
-			break
-		}
+	victim := victimHeap.peek()
+	if victim == nil {
+		return nil, false
 	}
-
-	c.currentMemoryBytes -= freedBytes
-	return evicted
+	c.removeEntryUnsafe(victimShard, victim)
+	return victim, true
 }
 
 // startCleanupRoutine starts the background cleanup routine
 func (c *TTLCache) startCleanupRoutine() {
+	if c.config.DisableAutoCleanup {
+		return
+	}
+	if c.config.CleanupScheduler != nil {
+		c.config.CleanupScheduler.register(c)
+		return
+	}
+
 	c.cleanupTimer = time.NewTimer(c.config.CleanupInterval)
 
 	go func() {
 		for {
 			select {
 			case <-c.cleanupTimer.C:
-				c.cleanupExpired()
+				c.runCleanup()
 				c.cleanupTimer.Reset(c.config.CleanupInterval)
 			case <-c.stopCleanup:
 				return
@@ -360,35 +946,140 @@ func (c *TTLCache) startCleanupRoutine() {
 	}()
 }
 
-// cleanupExpired removes all expired entries
-func (c *TTLCache) cleanupExpired() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// CleanupNow runs one cleanup pass immediately, as the cleanup routine would,
+// and returns the number of entries removed, expired or trimmed. It's meant
+// for caches created with DisableAutoCleanup but is safe to call on any cache.
+func (c *TTLCache) CleanupNow() int {
+	return c.runCleanup()
+}
 
-	now := time.Now()
-	var freedBytes uint64
-	deleted := 0
+// runCleanup performs one periodic cleanup pass: expired entries are removed,
+// memory is trimmed to the soft threshold, and expiring entries are refreshed.
+// It returns the number of entries removed.
+func (c *TTLCache) runCleanup() int {
+	removed := c.cleanupExpired()
+	removed += c.trimToSoftThreshold()
+	c.refreshExpiring()
+	return removed
+}
 
-	for key, entry := range c.entries {
-		if now.After(entry.ExpiresAt) {
-			delete(c.entries, key)
-			freedBytes += uint64(entry.Size)
-			deleted++
+// cleanupExpired removes all expired entries, returning how many
+func (c *TTLCache) cleanupExpired() int {
+	now := c.clock.Now()
+	var deleted []*CacheEntry
+
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		for _, entry := range shard.entries {
+			if now.After(entry.ExpiresAt) {
+				c.removeEntryUnsafe(shard, entry)
+				deleted = append(deleted, entry)
+			}
 		}
+		shard.mu.Unlock()
 	}
 
-	c.currentMemoryBytes -= freedBytes
-
-	if c.metrics != nil && deleted > 0 {
-		for i := 0; i < deleted; i++ {
+	if c.metrics != nil && len(deleted) > 0 {
+		for range deleted {
 			c.metrics.RecordDeletion()
 		}
-		c.metrics.UpdateMemoryUsage(c.currentMemoryBytes, len(c.entries))
+		c.updateMemoryMetrics()
 	}
+
+	for _, entry := range deleted {
+		c.notifyEvict(entry)
+	}
+	return len(deleted)
 }
 
-// calculateHeaderSize estimates the memory size of HTTP headers
-func (c *TTLCache) calculateHeaderSize(headers http.Header) int {
+// trimToSoftThreshold evicts entries until memory usage is at or below
+// SoftMemoryThresholdPct of MaxMemoryMB, keeping headroom so that Set seldom
+// has to evict on the request path. It returns the number evicted.
+func (c *TTLCache) trimToSoftThreshold() int {
+	if c.config.SoftMemoryThresholdPct <= 0 {
+		return 0
+	}
+	softLimit := c.maxMemoryBytes.Load() * int64(c.config.SoftMemoryThresholdPct) / 100
+
+	var evicted []*CacheEntry
+	for c.totalMemoryBytes.Load() > softLimit {
+		entry, ok := c.evictNext(nil)
+		if !ok {
+			break
+		}
+		evicted = append(evicted, entry)
+		if c.metrics != nil {
+			c.metrics.RecordEviction()
+		}
+	}
+
+	if c.metrics != nil && len(evicted) > 0 {
+		c.updateMemoryMetrics()
+	}
+
+	for _, entry := range evicted {
+		c.notifyEvict(entry)
+	}
+	return len(evicted)
+}
+
+// refreshCandidate is an entry due for refresh-ahead along with its original TTL
+type refreshCandidate struct {
+	key string
+	ttl time.Duration
+}
+
+// refreshExpiring re-fetches recently accessed entries that are about to expire
+// using the configured RefreshFunc
+func (c *TTLCache) refreshExpiring() {
+	if c.config.RefreshAhead <= 0 || c.config.RefreshFunc == nil {
+		return
+	}
+
+	candidates := c.collectRefreshCandidates()
+
+	// Invoke the refresh callback without holding the lock
+	for _, candidate := range candidates {
+		resp, err := c.config.RefreshFunc(candidate.key)
+		if err != nil || resp == nil {
+			if c.metrics != nil {
+				c.metrics.RecordError("refresh_failed")
+			}
+			if err != nil && c.config.Logger != nil {
+				c.config.Logger.OnError("refresh", err)
+			}
+			continue
+		}
+		c.setResponse(candidate.key, resp, candidate.ttl)
+	}
+}
+
+// collectRefreshCandidates finds unexpired entries within RefreshAhead of expiry
+// that have been accessed since they were stored
+func (c *TTLCache) collectRefreshCandidates() []refreshCandidate {
+	now := c.clock.Now()
+	var candidates []refreshCandidate
+
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.entries {
+			if now.After(entry.ExpiresAt) || entry.AccessCount == 0 {
+				continue
+			}
+			if entry.ExpiresAt.Sub(now) <= c.config.RefreshAhead {
+				candidates = append(candidates, refreshCandidate{
+					key: key,
+					ttl: entry.baseTTL,
+				})
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return candidates
+}
+
+// headerSize estimates the memory size of HTTP headers
+func headerSize(headers http.Header) int {
 	size := 0
 	for k, v := range headers {
 		size += len(k)
@@ -399,8 +1090,16 @@ func (c *TTLCache) calculateHeaderSize(headers http.Header) int {
 	return size
 }
 
-// GenerateCacheKey creates a consistent cache key from request characteristics
+// GenerateCacheKey creates a consistent cache key from request
+// characteristics: the first 64 bits of a SHA-256 hash, as 16 hex characters
 func GenerateCacheKey(method, path, query string, headers map[string]string) string {
+	return GenerateCacheKeyBits(method, path, query, headers, DefaultKeyHashBits)
+}
+
+// GenerateCacheKeyBits creates a cache key like GenerateCacheKey, keeping
+// hashBits bits of the SHA-256 hash (64, 128 or 256 for the full hash). Any
+// other value is treated as 64.
+func GenerateCacheKeyBits(method, path, query string, headers map[string]string, hashBits int) string {
 	var keyParts []string
 
 	// Add request method
@@ -410,7 +1109,7 @@ func GenerateCacheKey(method, path, query string, headers map[string]string) str
 	keyParts = append(keyParts, sanitizeKeyPart(path))
 
 	// Add sorted query parameters
-	if query != "" {
+	if query = NormalizeQuery(query, nil); query != "" {
 		keyParts = append(keyParts, "query="+sanitizeKeyPart(query))
 	}
 
@@ -431,7 +1130,30 @@ func GenerateCacheKey(method, path, query string, headers map[string]string) str
 	// Create hash of the key components
 	keyString := strings.Join(keyParts, "|")
 	hash := sha256.Sum256([]byte(keyString))
-	return hex.EncodeToString(hash[:])[:16] // 16 chars for cache key
+	if validateKeyHashBits(hashBits) != nil || hashBits == 0 {
+		hashBits = DefaultKeyHashBits
+	}
+	return hex.EncodeToString(hash[:hashBits/8])
+}
+
+// addHostKeyPart adds the request host to the headers a cache key is built
+// from. Hosts are case-insensitive, so the host is lowercased.
+func addHostKeyPart(headers map[string]string, host string) {
+	if host != "" {
+		headers["Host"] = strings.ToLower(host)
+	}
+}
+
+// keyPath returns the request path to build a cache key from, with trailing
+// slashes stripped when trimSlash is set. The root path is kept.
+func keyPath(path string, trimSlash bool) string {
+	if !trimSlash {
+		return path
+	}
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return "/"
 }
 
 // sanitizeKeyPart escapes pipe characters and other separators to prevent cache key collisions
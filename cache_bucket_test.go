@@ -0,0 +1,83 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCacheBucketsSelectTTL verifies that the X-Cache-Bucket response header
+// selects the TTL used for the cached entry in the middleware
+func TestCacheBucketsSelectTTL(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultTTL = 10 * time.Minute
+	config.CacheBuckets = map[string]time.Duration{
+		"hot":  30 * time.Second,
+		"warm": 5 * time.Minute,
+		"cold": 1 * time.Hour,
+	}
+	middleware := New(config)
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if bucket := r.URL.Query().Get("bucket"); bucket != "" {
+			w.Header().Set(CacheBucketHeader, bucket)
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+
+	tests := []struct {
+		name        string
+		url         string
+		expectedTTL time.Duration
+	}{
+		{"hot bucket", "/data?bucket=hot", 30 * time.Second},
+		{"warm bucket", "/data?bucket=warm", 5 * time.Minute},
+		{"cold bucket", "/data?bucket=cold", 1 * time.Hour},
+		{"unknown bucket", "/data?bucket=frozen", 10 * time.Minute},
+		{"no bucket", "/data", 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entry, found := middleware.GetCacheForTesting().Get(middleware.createCacheKey(req))
+			if !found {
+				t.Fatalf("Expected response to be cached")
+			}
+
+			remaining := time.Until(entry.ExpiresAt)
+			if remaining > tt.expectedTTL || remaining < tt.expectedTTL-5*time.Second {
+				t.Errorf("Expected TTL of ~%v, got %v", tt.expectedTTL, remaining)
+			}
+		})
+	}
+}
+
+// TestCacheBucketsOverrideContentTypeTTL verifies that a configured bucket
+// wins over per-content-type TTLs in the transport layer
+func TestCacheBucketsOverrideContentTypeTTL(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ContentTypeTTLs["application/json"] = 10 * time.Minute
+	config.CacheBuckets = map[string]time.Duration{"hot": 30 * time.Second}
+	detector := NewContentDetector(config)
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	if ttl := detector.AnalyzeResponse([]byte(`{}`), headers, 200).RecommendedTTL; ttl != 10*time.Minute {
+		t.Errorf("Expected content type TTL without bucket, got %v", ttl)
+	}
+
+	headers.Set(CacheBucketHeader, "hot")
+	if ttl := detector.AnalyzeResponse([]byte(`{}`), headers, 200).RecommendedTTL; ttl != 30*time.Second {
+		t.Errorf("Expected bucket TTL to win, got %v", ttl)
+	}
+
+	headers.Set(CacheBucketHeader, "unknown")
+	if ttl := detector.AnalyzeResponse([]byte(`{}`), headers, 200).RecommendedTTL; ttl != 10*time.Minute {
+		t.Errorf("Expected unknown bucket to fall back, got %v", ttl)
+	}
+}
@@ -0,0 +1,58 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestUpstreamCacheHitNotRecached verifies that a response already marked as
+// a cache hit by an upstream cache is passed through but not re-cached
+func TestUpstreamCacheHitNotRecached(t *testing.T) {
+	inner := NewDefault()
+	outer := NewDefault()
+
+	calls := 0
+	handler := outer.Handler(inner.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"layer": "origin"}`))
+	})))
+
+	// First request populates the inner cache only; the outer cache sees a miss
+	// from the origin and caches it too
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+	outer.Clear()
+
+	// Second request is served by the inner cache with a HIT marker
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/data", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("Expected inner cache hit, got %q", resp.Header().Get("X-Cache-Status"))
+	}
+
+	itemCount, _, _ := outer.Stats()
+	if itemCount != 0 {
+		t.Errorf("Outer cache should not re-cache an upstream cache hit, got %d items", itemCount)
+	}
+	if calls != 1 {
+		t.Errorf("Expected origin to be called once, got %d", calls)
+	}
+}
+
+// TestDetectorSkipsMarkedCacheHit verifies the transport layer detector
+// refuses to cache responses carrying the configured hit marker
+func TestDetectorSkipsMarkedCacheHit(t *testing.T) {
+	detector := NewContentDetector(DefaultCacheConfig())
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	if !detector.ShouldCache([]byte(`{}`), headers, 200) {
+		t.Fatalf("Unmarked response should be cacheable")
+	}
+
+	headers.Set("X-Cache-Status", "HIT")
+	if detector.ShouldCache([]byte(`{}`), headers, 200) {
+		t.Errorf("Response marked as cache hit should not be cacheable")
+	}
+}
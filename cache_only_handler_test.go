@@ -0,0 +1,70 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheOnlyHandler(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	origin := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"cached": true}`))
+	}))
+	cacheOnly := middleware.CacheOnlyHandler()
+
+	// A miss is answered with the default status
+	resp := httptest.NewRecorder()
+	cacheOnly.ServeHTTP(resp, httptest.NewRequest("GET", "/item", nil))
+	if resp.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 on miss, got %d", resp.Code)
+	}
+	if resp.Header().Get("X-Cache-Status") != "MISS" {
+		t.Errorf("Expected X-Cache-Status: MISS, got %q", resp.Header().Get("X-Cache-Status"))
+	}
+
+	// Once stored by the origin-fetch layer, the entry is served
+	origin.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/item", nil))
+	resp = httptest.NewRecorder()
+	cacheOnly.ServeHTTP(resp, httptest.NewRequest("GET", "/item", nil))
+	if resp.Code != http.StatusOK || resp.Body.String() != `{"cached": true}` {
+		t.Errorf("Expected cached body, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected X-Cache-Status: HIT, got %q", resp.Header().Get("X-Cache-Status"))
+	}
+
+	if _, hits, misses := middleware.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d and %d", hits, misses)
+	}
+}
+
+func TestCacheOnlyHandler_ConfiguredMissStatus(t *testing.T) {
+	config := DefaultConfig()
+	config.CacheOnlyMissStatus = http.StatusNotFound
+	middleware := New(config)
+	defer middleware.Close()
+
+	resp := httptest.NewRecorder()
+	middleware.CacheOnlyHandler().ServeHTTP(resp, httptest.NewRequest("GET", "/missing", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected configured 404 on miss, got %d", resp.Code)
+	}
+}
+
+func TestCacheOnlyHandler_MethodNotAllowed(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	resp := httptest.NewRecorder()
+	middleware.CacheOnlyHandler().ServeHTTP(resp, httptest.NewRequest("POST", "/item", nil))
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", resp.Code)
+	}
+	if resp.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("Expected Allow: GET, HEAD, got %q", resp.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,125 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestShardedCacheAggregates verifies that Size, MemoryUsage, and Clear
+// operate across all shards and limits hold globally
+func TestShardedCacheAggregates(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ShardCount = 8
+	config.MaxEntries = 50
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	for i := 0; i < 40; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte("0123456789"), headers, time.Hour)
+	}
+
+	if size := cache.Size(); size != 40 {
+		t.Errorf("Expected 40 entries across shards, got %d", size)
+	}
+	if usage := cache.MemoryUsage(); usage != 400 {
+		t.Errorf("Expected 400 bytes across shards, got %d", usage)
+	}
+
+	populated := 0
+	for _, shard := range cache.shards {
+		if len(shard.entries) > 0 {
+			populated++
+		}
+	}
+	if populated < 2 {
+		t.Errorf("Expected keys to spread over multiple shards, got %d", populated)
+	}
+
+	for i := 40; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte("0123456789"), headers, time.Hour)
+	}
+	if size := cache.Size(); size > config.MaxEntries {
+		t.Errorf("Expected global entry limit of %d, got %d", config.MaxEntries, size)
+	}
+
+	cache.Clear()
+	if cache.Size() != 0 || cache.MemoryUsage() != 0 {
+		t.Errorf("Expected empty cache after Clear, got %d entries and %d bytes", cache.Size(), cache.MemoryUsage())
+	}
+}
+
+// TestShardedCacheConcurrentAccess exercises concurrent reads and writes
+// across shards while keeping global accounting consistent
+func TestShardedCacheConcurrentAccess(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntries = 100
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("key-%d-%d", g, i%150)
+				cache.Set(key, []byte("data"), headers, time.Hour)
+				cache.Get(key)
+				if i%7 == 0 {
+					cache.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got, want := uint64(cache.totalMemoryBytes.Load()), cache.MemoryUsage(); got != want {
+		t.Errorf("Global memory counter %d out of sync with shard total %d", got, want)
+	}
+	if got, want := int(cache.totalEntries.Load()), cache.Size(); got != want {
+		t.Errorf("Global entry counter %d out of sync with shard total %d", got, want)
+	}
+}
+
+// benchmarkParallelGetSet runs a mixed read/write workload against a cache
+// with the given number of shards
+func benchmarkParallelGetSet(b *testing.B, shards int) {
+	config := DefaultCacheConfig()
+	config.ShardCount = shards
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		cache.Set(keys[i], []byte("data"), headers, time.Hour)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				cache.Set(key, []byte("data"), headers, time.Hour)
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkTTLCache_Parallel_1Shard(b *testing.B) {
+	benchmarkParallelGetSet(b, 1)
+}
+
+func BenchmarkTTLCache_Parallel_16Shards(b *testing.B) {
+	benchmarkParallelGetSet(b, 16)
+}
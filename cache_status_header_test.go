@@ -0,0 +1,82 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// cacheStatusHandler serves cacheable JSON through a middleware with config
+func cacheStatusHandler(t *testing.T, config Config) (*Middleware, http.Handler) {
+	t.Helper()
+	middleware := New(config)
+	t.Cleanup(middleware.Close)
+
+	return middleware, middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+}
+
+// TestCacheStatusHeader_MissAndHit verifies that misses are reported as MISS
+// and that the MISS value is not stored with the cached response
+func TestCacheStatusHeader_MissAndHit(t *testing.T) {
+	middleware, handler := cacheStatusHandler(t, DefaultConfig())
+
+	for _, expected := range []string{"MISS", "HIT"} {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/status", nil))
+		if got := resp.Header().Get("X-Cache-Status"); got != expected {
+			t.Errorf("Expected X-Cache-Status %q, got %q", expected, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	entry, found := middleware.Cache().Get(middleware.createCacheKey(req))
+	if !found {
+		t.Fatal("Expected response to be cached")
+	}
+	if got := entry.Headers.Get("X-Cache-Status"); got != "" {
+		t.Errorf("Expected no cache status in the stored headers, got %q", got)
+	}
+}
+
+// TestCacheStatusHeader_Renamed verifies that CacheStatusHeader replaces the
+// default header name for every status
+func TestCacheStatusHeader_Renamed(t *testing.T) {
+	config := DefaultConfig()
+	config.CacheStatusHeader = "X-Cache"
+	config.BypassFunc = func(r *http.Request) bool { return r.URL.Query().Has("nocache") }
+	_, handler := cacheStatusHandler(t, config)
+
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"/renamed", "MISS"},
+		{"/renamed", "HIT"},
+		{"/renamed?nocache", "BYPASS"},
+	}
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", tt.target, nil))
+		if got := resp.Header().Get("X-Cache"); got != tt.expected {
+			t.Errorf("%s: expected X-Cache %q, got %q", tt.target, tt.expected, got)
+		}
+		if got := resp.Header().Get("X-Cache-Status"); got != "" {
+			t.Errorf("%s: expected no X-Cache-Status, got %q", tt.target, got)
+		}
+	}
+}
+
+// TestCacheStatusHeader_UncacheableMethod verifies that requests with
+// uncacheable methods get no cache status
+func TestCacheStatusHeader_UncacheableMethod(t *testing.T) {
+	_, handler := cacheStatusHandler(t, DefaultConfig())
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("POST", "/status", nil))
+	if got := resp.Header().Get("X-Cache-Status"); got != "" {
+		t.Errorf("Expected no X-Cache-Status on POST, got %q", got)
+	}
+}
@@ -50,7 +50,9 @@ func TestTTLCache_SetAndGet(t *testing.T) {
 }
 
 func TestTTLCache_Expiration(t *testing.T) {
+	clock := newFakeClock()
 	config := DefaultCacheConfig()
+	config.Clock = clock
 	metrics := NewCacheMetrics(true)
 	cache := NewTTLCache(config, metrics)
 	defer cache.Close()
@@ -72,8 +74,8 @@ func TestTTLCache_Expiration(t *testing.T) {
 		t.Fatalf("Entry should be available immediately")
 	}
 
-	// Wait for expiration
-	time.Sleep(150 * time.Millisecond)
+	// Advance past expiration
+	clock.Advance(150 * time.Millisecond)
 
 	// Should be expired now
 	_, found = cache.Get(key)
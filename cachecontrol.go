@@ -0,0 +1,159 @@
+package selectcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl holds the parsed directives of a Cache-Control header
+type cacheControl map[string]string
+
+// parseCacheControl parses the Cache-Control header into its directives.
+// Directive names are lowercased; quoted values are unquoted.
+func parseCacheControl(headers http.Header) cacheControl {
+	return parseDirectives(headers.Values("Cache-Control"))
+}
+
+// parseDirectives parses the values of a Cache-Control style header, such as
+// Surrogate-Control, into their directives
+func parseDirectives(values []string) cacheControl {
+	cc := make(cacheControl)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return cc
+}
+
+// has reports whether the directive is present
+func (cc cacheControl) has(directive string) bool {
+	_, exists := cc[directive]
+	return exists
+}
+
+// seconds returns the value of a delta-seconds directive
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	arg, exists := cc[directive]
+	if !exists {
+		return 0, false
+	}
+	secs, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// sharedMaxAge returns the freshness lifetime for a shared cache,
+// preferring s-maxage over max-age
+func (cc cacheControl) sharedMaxAge() (time.Duration, bool) {
+	if ttl, ok := cc.seconds("s-maxage"); ok {
+		return ttl, true
+	}
+	return cc.seconds("max-age")
+}
+
+// remainingSharedMaxAge returns the shared max-age of a response less the
+// Age it arrived with, floored at zero, so a response that already spent time
+// in an upstream cache isn't kept fresh for longer than its origin allowed
+func remainingSharedMaxAge(headers http.Header) (time.Duration, bool) {
+	ttl, ok := parseCacheControl(headers).sharedMaxAge()
+	if !ok {
+		return 0, false
+	}
+	if ttl -= upstreamAge(headers); ttl < 0 {
+		ttl = 0
+	}
+	return ttl, true
+}
+
+// upstreamAge returns the Age header of a response, or zero when it is
+// missing or invalid
+func upstreamAge(headers http.Header) time.Duration {
+	secs, err := strconv.ParseInt(headers.Get("Age"), 10, 64)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// explicitlyCacheable reports whether the origin marked the response
+// cacheable with public or a positive s-maxage or max-age
+func (cc cacheControl) explicitlyCacheable() bool {
+	if cc.has("public") {
+		return true
+	}
+	ttl, ok := cc.sharedMaxAge()
+	return ok && ttl > 0
+}
+
+// forbidsSharedStorage reports whether the response must not be stored by a
+// shared cache (no-store, private) or reused without revalidation (no-cache)
+func (cc cacheControl) forbidsSharedStorage() bool {
+	return cc.has("no-store") || cc.has("no-cache") || cc.has("private")
+}
+
+// immutable reports whether the origin promised the response won't change
+// while it is fresh, so it never needs revalidating
+func (cc cacheControl) immutable() bool {
+	return cc.has("immutable")
+}
+
+// staleIfError returns how long a stale response may be served when
+// revalidation fails with a server error
+func (cc cacheControl) staleIfError() (time.Duration, bool) {
+	return cc.seconds("stale-if-error")
+}
+
+// withMaxAge rewrites a Cache-Control header value so that max-age is the
+// given number of seconds, dropping s-maxage; other directives are kept. An
+// empty value yields just the max-age directive.
+func withMaxAge(value string, seconds int64) string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		name, _, _ := strings.Cut(part, "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "", "max-age", "s-maxage":
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(append(parts, "max-age="+strconv.FormatInt(seconds, 10)), ", ")
+}
+
+// reduceMaxAge rewrites the max-age and s-maxage directives of a Cache-Control
+// header value, subtracting age (floored at zero) so downstream caches don't
+// extend the response's freshness lifetime. Other directives are left as is.
+func reduceMaxAge(value string, age time.Duration) string {
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+		name, arg, found := strings.Cut(parts[i], "=")
+		if !found {
+			continue
+		}
+		lowered := strings.ToLower(strings.TrimSpace(name))
+		if lowered != "max-age" && lowered != "s-maxage" {
+			continue
+		}
+		secs, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(arg), `"`), 10, 64)
+		if err != nil {
+			continue
+		}
+		secs -= int64(age / time.Second)
+		if secs < 0 {
+			secs = 0
+		}
+		parts[i] = lowered + "=" + strconv.FormatInt(secs, 10)
+	}
+	return strings.Join(parts, ", ")
+}
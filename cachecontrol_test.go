@@ -0,0 +1,111 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", `public, Max-Age=60, s-maxage="300", stale-if-error=120`)
+	cc := parseCacheControl(headers)
+
+	if !cc.has("public") {
+		t.Errorf("Expected public directive")
+	}
+	if ttl, ok := cc.sharedMaxAge(); !ok || ttl != 300*time.Second {
+		t.Errorf("Expected s-maxage to win with 300s, got %v (%v)", ttl, ok)
+	}
+	if window, ok := cc.staleIfError(); !ok || window != 120*time.Second {
+		t.Errorf("Expected stale-if-error of 120s, got %v (%v)", window, ok)
+	}
+
+	headers.Set("Cache-Control", "max-age=60")
+	if ttl, ok := parseCacheControl(headers).sharedMaxAge(); !ok || ttl != 60*time.Second {
+		t.Errorf("Expected max-age fallback of 60s, got %v (%v)", ttl, ok)
+	}
+
+	headers.Set("Cache-Control", "max-age=bogus")
+	if _, ok := parseCacheControl(headers).sharedMaxAge(); ok {
+		t.Errorf("Expected invalid max-age to be ignored")
+	}
+}
+
+// TestSMaxAgeDeterminesTTL verifies that s-maxage wins over max-age for
+// middleware TTL decisions
+func TestSMaxAgeDeterminesTTL(t *testing.T) {
+	middleware := NewDefault()
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=30, s-maxage=120")
+		w.Write([]byte(`{}`))
+	}))
+
+	req := httptest.NewRequest("GET", "/shared", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry, found := middleware.GetCacheForTesting().Get(middleware.createCacheKey(req))
+	if !found {
+		t.Fatalf("Expected response to be cached")
+	}
+	if remaining := time.Until(entry.ExpiresAt); remaining > 120*time.Second || remaining < 110*time.Second {
+		t.Errorf("Expected s-maxage TTL of ~120s, got %v", remaining)
+	}
+}
+
+// TestStaleIfErrorServesStaleOnServerError verifies that a stale entry is
+// served with X-Cache-Status: STALE-ERROR when revalidation fails with a 5xx
+func TestStaleIfErrorServesStaleOnServerError(t *testing.T) {
+	middleware := NewDefault()
+
+	failing := false
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"error": "upstream down"}`))
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60, stale-if-error=300")
+		w.Write([]byte(`{"data": "good"}`))
+	}))
+
+	req := httptest.NewRequest("GET", "/resilient", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	cached, found := middleware.GetCacheForTesting().Get(middleware.createCacheKey(req))
+	if !found {
+		t.Fatalf("Expected response to be cached")
+	}
+	// Age the entry past its freshness lifetime
+	cached.FreshUntil = time.Now().Add(-time.Second)
+
+	failing = true
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/resilient", nil))
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected stale 200 response, got %d", resp.Code)
+	}
+	if resp.Header().Get("X-Cache-Status") != "STALE-ERROR" {
+		t.Errorf("Expected X-Cache-Status: STALE-ERROR, got %q", resp.Header().Get("X-Cache-Status"))
+	}
+	if resp.Body.String() != `{"data": "good"}` {
+		t.Errorf("Expected stale body, got %s", resp.Body.String())
+	}
+
+	// A successful revalidation replaces the stale entry
+	failing = false
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/resilient", nil))
+	if resp.Code != http.StatusOK || resp.Header().Get("X-Cache-Status") != "MISS" {
+		t.Errorf("Expected fresh origin response, got %d with status %q", resp.Code, resp.Header().Get("X-Cache-Status"))
+	}
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/resilient", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected revalidated entry to be served as HIT")
+	}
+}
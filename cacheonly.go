@@ -0,0 +1,33 @@
+package selectcache
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// CacheOnlyHandler returns an http.Handler that serves GET and HEAD requests
+// purely from the cache, for use behind a separate layer that fetches from
+// the origin. Misses, including stale entries, are answered with
+// Config.CacheOnlyMissStatus; other methods get 405 Method Not Allowed.
+func (m *Middleware) CacheOnlyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.isCacheableMethod(r.Method) {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := m.createCacheKey(r)
+		if served, _ := m.tryServeFromCache(w, r, key); served {
+			return
+		}
+
+		atomic.AddUint64(&m.missCount, 1)
+		m.metrics.RecordMissMethod(r.Method)
+		if m.logger != nil {
+			m.logger.OnMiss(key, r.URL.Path)
+		}
+		w.Header().Set(m.statusHeader, "MISS")
+		http.Error(w, http.StatusText(m.cacheOnlyStatus), m.cacheOnlyStatus)
+	})
+}
@@ -0,0 +1,78 @@
+package selectcache
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestChunkedResponseCachedDecoded verifies that a real chunked response is
+// de-chunked before it is stored, so the cached body matches what the client read
+func TestChunkedResponseCachedDecoded(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	config := DefaultCacheConfig()
+	cachingListener := NewCachingListener(baseListener, config)
+	defer cachingListener.Close()
+
+	chunks := []string{"first chunk\n", "second chunk\n", strings.Repeat("z", 3000)}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for _, chunk := range chunks {
+			io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	})
+	server := &http.Server{Handler: handler}
+	go server.Serve(cachingListener)
+	defer server.Close()
+
+	resp, err := http.Get("http://" + baseListener.Addr().String() + "/stream")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Fatalf("Expected a chunked response, got %v", resp.TransferEncoding)
+	}
+	want := strings.Join(chunks, "")
+	if string(body) != want {
+		t.Fatalf("Client body mismatch")
+	}
+
+	key := GenerateCacheKey("GET", "/stream", "", map[string]string{"Accept-Encoding": "gzip", "Host": baseListener.Addr().String()})
+	var entry *CacheEntry
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if e, found := cachingListener.cache.Get(key); found {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		t.Fatal("Expected chunked response to be cached")
+	}
+
+	if string(entry.Data) != want {
+		t.Errorf("Cached body does not match decoded content: got %d bytes %q...", len(entry.Data), truncate(entry.Data, 40))
+	}
+	if te := entry.Headers.Get("Transfer-Encoding"); te != "" {
+		t.Errorf("Expected Transfer-Encoding to be dropped from cached headers, got %q", te)
+	}
+	if cl := entry.Headers.Get("Content-Length"); cl != "3025" {
+		t.Errorf("Expected Content-Length of decoded body, got %q", cl)
+	}
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
@@ -0,0 +1,140 @@
+package selectcache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by TTLCache.Set while the store circuit breaker
+// is open and stores are being skipped
+var ErrCircuitOpen = errors.New("cache store circuit open")
+
+// defaultStoreCircuitCooldown is used when CacheConfig.StoreCircuitCooldown is unset
+const defaultStoreCircuitCooldown = 30 * time.Second
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState string
+
+const (
+	// CircuitClosed allows every operation
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects operations until the cooldown elapses
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen allows a single trial operation to decide whether to
+	// close the circuit again
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker stops calling a failing backend. After threshold consecutive
+// failures it opens for the cooldown, then lets one trial through: success
+// closes the circuit, failure opens it for another cooldown. A zero threshold
+// disables the breaker.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trialing bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if cooldown <= 0 {
+		cooldown = defaultStoreCircuitCooldown
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// Allow reports whether an operation may proceed. Once the cooldown has
+// elapsed an open circuit turns half-open and admits one caller, who must
+// report the outcome with RecordSuccess or RecordFailure.
+func (b *CircuitBreaker) Allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.trialing = true
+		return true
+	case CircuitHalfOpen:
+		if b.trialing {
+			return false
+		}
+		b.trialing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess reports a successful operation, closing the circuit
+func (b *CircuitBreaker) RecordSuccess() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trialing = false
+}
+
+// RecordFailure reports a failed operation, opening the circuit once the
+// threshold is reached or when a half-open trial fails
+func (b *CircuitBreaker) RecordFailure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trialing = false
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends an admitted operation that says nothing about the protected
+// resource's health, freeing a half-open trial without counting an outcome
+func (b *CircuitBreaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+}
+
+// State returns the current state of the circuit
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
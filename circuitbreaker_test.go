@@ -0,0 +1,183 @@
+package selectcache
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	breaker := NewCircuitBreaker(3, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		breaker.RecordFailure()
+	}
+	if !breaker.Allow() || breaker.State() != CircuitClosed {
+		t.Fatal("Expected circuit to stay closed below the threshold")
+	}
+
+	// A success resets the consecutive failure count
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	if breaker.State() != CircuitClosed {
+		t.Fatal("Expected non-consecutive failures not to open the circuit")
+	}
+
+	breaker.RecordFailure()
+	if breaker.State() != CircuitOpen || breaker.Allow() {
+		t.Fatal("Expected circuit to open after consecutive failures")
+	}
+}
+
+func TestCircuitBreaker_HalfOpenTrial(t *testing.T) {
+	breaker := NewCircuitBreaker(1, 50*time.Millisecond)
+	breaker.RecordFailure()
+
+	time.Sleep(60 * time.Millisecond)
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("Expected half-open after cooldown, got %s", breaker.State())
+	}
+	if !breaker.Allow() {
+		t.Fatal("Expected a trial operation after cooldown")
+	}
+	if breaker.Allow() {
+		t.Fatal("Expected only one trial operation while half-open")
+	}
+
+	// A failed trial reopens the circuit for another cooldown
+	breaker.RecordFailure()
+	if breaker.State() != CircuitOpen || breaker.Allow() {
+		t.Fatal("Expected failed trial to reopen the circuit")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	breaker.Allow()
+	breaker.RecordSuccess()
+	if breaker.State() != CircuitClosed || !breaker.Allow() {
+		t.Fatal("Expected successful trial to close the circuit")
+	}
+}
+
+func TestCircuitBreaker_DisabledWithZeroThreshold(t *testing.T) {
+	breaker := NewCircuitBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		breaker.RecordFailure()
+	}
+	if !breaker.Allow() || breaker.State() != CircuitClosed {
+		t.Error("Expected a zero threshold to disable the breaker")
+	}
+}
+
+func TestTTLCache_StoreCircuitOpenSkipsStores(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.StoreFailureThreshold = 2
+	config.StoreCircuitCooldown = time.Minute
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	// Simulate a failing backing store
+	cache.storeBreaker.RecordFailure()
+	cache.storeBreaker.RecordFailure()
+
+	if err := cache.Set("key", []byte("data"), nil, time.Minute); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if cache.Size() != 0 {
+		t.Error("Expected no entry to be stored while the circuit is open")
+	}
+	if count := metrics.GetStats().Errors["cache_store_circuit_open"]; count != 1 {
+		t.Errorf("Expected cache_store_circuit_open to be recorded once, got %d", count)
+	}
+	if state := cache.StoreCircuitState(); state != CircuitOpen {
+		t.Errorf("Expected open store circuit in stats, got %s", state)
+	}
+
+	// GetOrSet passes the computed value through uncached
+	entry, err := cache.GetOrSet("computed", time.Minute, func() ([]byte, http.Header, error) {
+		return []byte("value"), nil, nil
+	})
+	if err != nil || entry == nil || string(entry.Data) != "value" {
+		t.Fatalf("Expected computed value passed through, got %v, %v", entry, err)
+	}
+	if cache.Size() != 0 {
+		t.Error("Expected GetOrSet not to store while the circuit is open")
+	}
+}
+
+// TestTTLCache_StoreFailuresOpenCircuit verifies that consecutive backend
+// failures open the circuit, that a successful store in between resets the
+// count, and that stores rejected for lack of room don't count at all
+func TestTTLCache_StoreFailuresOpenCircuit(t *testing.T) {
+	cache := newEvictionBatchCache(t, 1, 1000)
+	defer cache.Close()
+	cache.storeBreaker = NewCircuitBreaker(3, time.Minute)
+
+	errBackend := errors.New("backend unavailable")
+	cache.storeFault = func(key string) error {
+		if strings.HasPrefix(key, "fail") {
+			return errBackend
+		}
+		return nil
+	}
+
+	big := make([]byte, 200*1000)
+	for i := 0; i < 5; i++ {
+		if err := cache.Set("big", big, nil, time.Minute); !errors.Is(err, ErrEvictionBatchExceeded) {
+			t.Fatalf("Expected ErrEvictionBatchExceeded, got %v", err)
+		}
+	}
+	if state := cache.StoreCircuitState(); state != CircuitClosed {
+		t.Fatalf("Expected eviction batch rejections to keep the circuit closed, got %s", state)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cache.Set("fail", []byte("data"), nil, time.Minute); !errors.Is(err, errBackend) {
+			t.Fatalf("Expected the backend error, got %v", err)
+		}
+	}
+	if err := cache.Set("ok", []byte("data"), nil, time.Minute); err != nil {
+		t.Fatalf("Expected a healthy store to succeed, got %v", err)
+	}
+	if state := cache.StoreCircuitState(); state != CircuitClosed {
+		t.Fatalf("Expected a success to keep the circuit closed, got %s", state)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cache.SetMulti(map[string]SetItem{"ok-2": {Data: []byte("data"), TTL: time.Minute}, "fail-2": {Data: []byte("data"), TTL: time.Minute}}); !errors.Is(err, errBackend) {
+			t.Fatalf("Expected the backend error, got %v", err)
+		}
+	}
+	if cache.Has("ok-2") || cache.Has("fail-2") {
+		t.Error("Expected a failed batch to store none of its entries")
+	}
+	if state := cache.StoreCircuitState(); state != CircuitOpen {
+		t.Errorf("Expected 3 consecutive failures to open the circuit, got %s", state)
+	}
+	if err := cache.Set("ok-3", []byte("data"), nil, time.Minute); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+// TestTTLCache_EvictionBatchReleasesTrial verifies that a half-open trial
+// rejected for lack of room doesn't leave the circuit stuck
+func TestTTLCache_EvictionBatchReleasesTrial(t *testing.T) {
+	cache := newEvictionBatchCache(t, 1, 1000)
+	defer cache.Close()
+	cache.storeBreaker = NewCircuitBreaker(1, 10*time.Millisecond)
+	cache.storeBreaker.RecordFailure()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := cache.Set("big", make([]byte, 200*1000), nil, time.Minute); !errors.Is(err, ErrEvictionBatchExceeded) {
+		t.Fatalf("Expected ErrEvictionBatchExceeded, got %v", err)
+	}
+	if err := cache.Set("small", []byte("data"), nil, time.Minute); err != nil {
+		t.Fatalf("Expected the next store to get the trial, got %v", err)
+	}
+	if state := cache.StoreCircuitState(); state != CircuitClosed {
+		t.Errorf("Expected the successful trial to close the circuit, got %s", state)
+	}
+}
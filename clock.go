@@ -0,0 +1,18 @@
+package selectcache
+
+import "time"
+
+// Clock supplies the current time to a TTLCache and its entries. Tests can
+// substitute a fake clock to advance time deterministically instead of
+// sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock, backed by time.Now
+type systemClock struct{}
+
+// Now returns the current wall-clock time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
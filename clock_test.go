@@ -0,0 +1,60 @@
+package selectcache
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestTTLCache_FakeClock(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.Clock = clock
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.Set("key", []byte("data"), make(http.Header), time.Hour)
+	entry, _ := cache.Get("key")
+	if !entry.StoreTime.Equal(clock.Now()) {
+		t.Errorf("StoreTime = %v, want the fake clock's %v", entry.StoreTime, clock.Now())
+	}
+
+	clock.Advance(45 * time.Minute)
+	if got := entry.RemainingTTL(); got != 15*time.Minute {
+		t.Errorf("RemainingTTL = %v, want 15m", got)
+	}
+	if entry.IsExpired() {
+		t.Error("Entry should not have expired yet")
+	}
+
+	// Cleanup uses the same clock
+	clock.Advance(time.Hour)
+	cache.cleanupExpired()
+	if cache.Size() != 0 {
+		t.Error("Expected cleanup to remove the expired entry")
+	}
+}
@@ -0,0 +1,114 @@
+package selectcache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestContentDetector_GzipEncodedJSON verifies that a gzip-encoded JSON
+// response is analyzed by its declared type rather than its compressed bytes
+func TestContentDetector_GzipEncodedJSON(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ContentTypeTTLs["application/json"] = 42 * time.Minute
+	detector := NewContentDetector(config)
+
+	body := gzipBytes(t, `{"items": [1, 2, 3], "next": null}`)
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json; charset=utf-8")
+	headers.Set("Content-Encoding", "gzip")
+
+	analysis := detector.AnalyzeResponse(body, headers, http.StatusOK)
+	if analysis.ContentType != "application/json" {
+		t.Errorf("Expected content type application/json, got %q", analysis.ContentType)
+	}
+	if analysis.IsHTML {
+		t.Error("Expected gzip-encoded JSON not to be treated as HTML")
+	}
+	if !analysis.IsCacheable {
+		t.Fatal("Expected gzip-encoded JSON to be cacheable")
+	}
+	if analysis.RecommendedTTL != 42*time.Minute {
+		t.Errorf("Expected JSON TTL of 42m, got %v", analysis.RecommendedTTL)
+	}
+}
+
+// TestContentDetector_DetectContentTypeFromResponse verifies that an encoded
+// body without a declared type is decoded before it is sniffed
+func TestContentDetector_DetectContentTypeFromResponse(t *testing.T) {
+	detector := NewContentDetector(DefaultCacheConfig())
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		expected string
+	}{
+		{"gzip JSON", gzipBytes(t, `{"ok": true}`), "gzip", "application/json"},
+		{"x-gzip JSON", gzipBytes(t, `[1, 2, 3]`), "x-gzip", "application/json"},
+		{"identity JSON", []byte(`{"ok": true}`), "", "application/json"},
+		{"corrupt gzip", []byte("not gzip at all"), "gzip", "application/octet-stream"},
+		{"unknown encoding", []byte(`{"ok": true}`), "compress", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.encoding != "" {
+				headers.Set("Content-Encoding", tt.encoding)
+			}
+			if got := detector.DetectContentTypeFromResponse(tt.body, headers); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestMiddleware_DecodesUpstreamEncodingForClient verifies that a body the
+// origin gzipped is decoded for a client that doesn't accept gzip, and served
+// encoded to one that does
+func TestMiddleware_DecodesUpstreamEncodingForClient(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	body := `{"compressed": true}`
+	encoded := gzipBytes(t, body)
+	plainReq := httptest.NewRequest("GET", "/data.json", nil)
+	middleware.Cache().setResponse(middleware.createCacheKey(plainReq), &CachedResponse{
+		StatusCode: http.StatusOK,
+		Headers:    http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}, "Etag": {`"v1"`}},
+		Body:       encoded,
+	}, time.Minute)
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the request to be served from cache")
+	}))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, plainReq)
+	if resp.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected no Content-Encoding for a non-gzip client, got %q", resp.Header().Get("Content-Encoding"))
+	}
+	if resp.Body.String() != body {
+		t.Errorf("Expected decoded body %s, got %q", body, resp.Body.Bytes())
+	}
+	if resp.Header().Get("ETag") != `W/"v1"` {
+		t.Errorf("Expected a weakened ETag, got %q", resp.Header().Get("ETag"))
+	}
+
+	gzipReq := httptest.NewRequest("GET", "/data.json", nil)
+	gzipReq.Header.Set("Accept-Encoding", "gzip")
+	middleware.Cache().setResponse(middleware.createCacheKey(gzipReq), &CachedResponse{
+		StatusCode: http.StatusOK,
+		Headers:    http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+		Body:       encoded,
+	}, time.Minute)
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, gzipReq)
+	if resp.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(resp.Body.Bytes(), encoded) {
+		t.Errorf("Expected the gzip body served as is, got encoding %q", resp.Header().Get("Content-Encoding"))
+	}
+}
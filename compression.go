@@ -0,0 +1,270 @@
+package selectcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms for stored response bodies
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionBrotli = "brotli"
+	CompressionZstd   = "zstd"
+)
+
+// contentCodings maps each compression algorithm to its Content-Encoding token
+var contentCodings = map[string]string{
+	CompressionGzip:   "gzip",
+	CompressionBrotli: "br",
+	CompressionZstd:   "zstd",
+}
+
+// The zstd encoder and decoder are safe for concurrent EncodeAll/DecodeAll
+// calls, so one of each is shared
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// compressionAlgorithm returns the algorithm to store bodies with, or "" for
+// none. Unknown algorithms store bodies uncompressed.
+func compressionAlgorithm(algorithm string) string {
+	if _, ok := contentCodings[algorithm]; ok {
+		return algorithm
+	}
+	return ""
+}
+
+// compressBody compresses data with the given algorithm
+func compressBody(algorithm string, data []byte) ([]byte, error) {
+	if algorithm == CompressionZstd {
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch algorithm {
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressionBrotli:
+		w = brotli.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", algorithm)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressBody reverses compressBody
+func decompressBody(algorithm string, data []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case CompressionBrotli:
+		return io.ReadAll(brotli.NewReader(bytes.NewReader(data)))
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", algorithm)
+	}
+}
+
+// decodeContentEncoding decodes up to limit bytes of a body sent with the
+// given Content-Encoding. Identity bodies are returned truncated to limit;
+// stacked or unknown encodings are an error.
+func decodeContentEncoding(encoding string, data []byte, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		if int64(len(data)) > limit {
+			data = data[:limit]
+		}
+		return data, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(data))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	// A truncated stream is fine for a sample
+	sample, err := io.ReadAll(io.LimitReader(r, limit))
+	if err == io.ErrUnexpectedEOF && len(sample) > 0 {
+		err = nil
+	}
+	return sample, err
+}
+
+// isCompressibleType reports whether a Content-Type is worth compressing:
+// text and the structured formats commonly served as text. Images, video and
+// archives are already compressed.
+func isCompressibleType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	for _, kind := range []string{"json", "javascript", "xml", "yaml", "csv"} {
+		if strings.Contains(contentType, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponse compresses a response body for storage with the
+// middleware's algorithm. Bodies that are empty, already content-encoded, not
+// of a compressible type, or that don't shrink are left as they are.
+func (m *Middleware) compressResponse(resp *CachedResponse) {
+	if m.compression == "" || len(resp.Body) == 0 || resp.Headers.Get("Content-Encoding") != "" {
+		return
+	}
+	if !isCompressibleType(resp.Headers.Get("Content-Type")) {
+		return
+	}
+
+	compressed, err := compressBody(m.compression, resp.Body)
+	if err != nil {
+		if m.logger != nil {
+			m.logger.OnError("compress", err)
+		}
+		return
+	}
+	if len(compressed) >= len(resp.Body) {
+		return
+	}
+	resp.Body = compressed
+	resp.Encoding = m.compression
+}
+
+// negotiateEncoding returns the representation of a cached response to serve
+// for a request. A compressed body is served as is, with a matching
+// Content-Encoding, when the client accepts the algorithm; otherwise it is
+// decompressed. Bodies the origin encoded itself get the same treatment.
+func negotiateEncoding(r *http.Request, cached *CachedResponse) (*CachedResponse, error) {
+	if cached.Encoding == "" {
+		return decodeUpstreamEncoding(r, cached)
+	}
+
+	served := *cached
+	served.Headers = cached.Headers.Clone()
+	served.Encoding = ""
+	served.Headers.Add("Vary", "Accept-Encoding")
+
+	if coding := contentCodings[cached.Encoding]; acceptsEncoding(r.Header.Get("Accept-Encoding"), coding) {
+		served.Headers.Set("Content-Encoding", coding)
+		// The encoded bytes differ from those the origin's ETag describes
+		weakenETag(served.Headers)
+		return &served, nil
+	}
+
+	body, err := decompressBody(cached.Encoding, cached.Body)
+	if err != nil {
+		return nil, err
+	}
+	served.Body = body
+	return &served, nil
+}
+
+// upstreamCodings maps the content codings an origin may have applied to the
+// token a client's Accept-Encoding names them by
+var upstreamCodings = map[string]string{
+	"gzip":    "gzip",
+	"x-gzip":  "gzip",
+	"deflate": "deflate",
+	"br":      "br",
+	"zstd":    "zstd",
+}
+
+// decodeUpstreamEncoding decodes a body stored with the origin's
+// Content-Encoding when the client doesn't accept that coding, which would
+// otherwise receive bytes it can't read. Unknown or stacked codings are
+// served unchanged.
+func decodeUpstreamEncoding(r *http.Request, cached *CachedResponse) (*CachedResponse, error) {
+	encoding := strings.ToLower(strings.TrimSpace(cached.Headers.Get("Content-Encoding")))
+	coding, known := upstreamCodings[encoding]
+	if !known || acceptsEncoding(r.Header.Get("Accept-Encoding"), coding) {
+		return cached, nil
+	}
+
+	body, err := decodeContentEncoding(encoding, cached.Body, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	served := *cached
+	served.Body = body
+	served.Headers = cached.Headers.Clone()
+	served.Headers.Del("Content-Encoding")
+	served.Headers.Del("Content-Length")
+	served.Headers.Add("Vary", "Accept-Encoding")
+	// The decoded bytes differ from those the origin's ETag describes
+	weakenETag(served.Headers)
+	return &served, nil
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header value accepts the
+// given content coding with a non-zero quality. An explicit entry for the
+// coding takes precedence over a "*" wildcard.
+func acceptsEncoding(acceptEncoding, coding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != coding && name != "*" {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		if name == coding {
+			return quality > 0
+		}
+		wildcard = quality > 0
+	}
+	return wildcard
+}
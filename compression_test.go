@@ -0,0 +1,204 @@
+package selectcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonCorpus returns a JSON document shaped like a typical API listing
+func jsonCorpus(items int) []byte {
+	type item struct {
+		ID          int      `json:"id"`
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Tags        []string `json:"tags"`
+		Price       float64  `json:"price"`
+		InStock     bool     `json:"in_stock"`
+	}
+	list := make([]item, items)
+	for i := range list {
+		list[i] = item{
+			ID:          i,
+			Name:        fmt.Sprintf("Product %d", i),
+			Description: fmt.Sprintf("A dependable product, number %d in the catalogue", i),
+			Tags:        []string{"catalogue", fmt.Sprintf("group-%d", i%7)},
+			Price:       float64(i%100) + 0.99,
+			InStock:     i%3 != 0,
+		}
+	}
+	data, _ := json.Marshal(list)
+	return data
+}
+
+func newCompressionTestHandler(t *testing.T, algorithm string, body []byte) (*Middleware, http.Handler) {
+	config := DefaultConfig()
+	config.CompressionAlgorithm = algorithm
+	middleware := New(config)
+	t.Cleanup(middleware.Close)
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(body)
+	}))
+	return middleware, handler
+}
+
+func serveWithEncoding(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/products", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_CompressedStorage(t *testing.T) {
+	body := jsonCorpus(50)
+
+	tests := []struct {
+		algorithm string
+		coding    string
+	}{
+		{CompressionGzip, "gzip"},
+		{CompressionBrotli, "br"},
+		{CompressionZstd, "zstd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			middleware, handler := newCompressionTestHandler(t, tt.algorithm, body)
+
+			serveWithEncoding(handler, tt.coding)
+			if stats := middleware.DetailedStats(); stats.MemoryBytes >= int64(len(body)) {
+				t.Errorf("Expected the stored body to be compressed, using %d bytes for %d", stats.MemoryBytes, len(body))
+			}
+
+			rec := serveWithEncoding(handler, tt.coding)
+			if rec.Header().Get("X-Cache-Status") != "HIT" {
+				t.Fatal("Expected response from cache")
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.coding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.coding)
+			}
+			if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Accept-Encoding") {
+				t.Error("Expected Vary: Accept-Encoding on a compressed response")
+			}
+			if got := rec.Header().Get("ETag"); got != `W/"v1"` {
+				t.Errorf("Expected the ETag to be weakened for the encoded body, got %q", got)
+			}
+			decoded, err := decompressBody(tt.algorithm, rec.Body.Bytes())
+			if err != nil {
+				t.Fatalf("Failed to decode served body: %v", err)
+			}
+			if string(decoded) != string(body) {
+				t.Error("Decoded body does not match the original")
+			}
+		})
+	}
+}
+
+func TestMiddleware_CompressedStorageFallsBackToIdentity(t *testing.T) {
+	body := jsonCorpus(50)
+	_, handler := newCompressionTestHandler(t, CompressionBrotli, body)
+
+	// The client accepts gzip but not brotli, so the stored body is decoded
+	serveWithEncoding(handler, "gzip")
+	rec := serveWithEncoding(handler, "gzip")
+
+	if rec.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatal("Expected response from cache")
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Expected no Content-Encoding, got %q", got)
+	}
+	if rec.Body.String() != string(body) {
+		t.Error("Expected the original body")
+	}
+	if got := rec.Header().Get("ETag"); got != `"v1"` {
+		t.Errorf("Expected the original ETag for the identity body, got %q", got)
+	}
+}
+
+func TestMiddleware_CompressionSkipsUnsuitableBodies(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers http.Header
+	}{
+		{"image", http.Header{"Content-Type": {"image/png"}}},
+		{"already encoded", http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.CompressionAlgorithm = CompressionGzip
+			middleware := New(config)
+			defer middleware.Close()
+
+			resp := &CachedResponse{Headers: tt.headers, Body: []byte(strings.Repeat("a", 1000))}
+			middleware.compressResponse(resp)
+			if resp.Encoding != "" || len(resp.Body) != 1000 {
+				t.Error("Expected the body to be stored as is")
+			}
+		})
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		coding string
+		want   bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip", "br", false},
+		{"", "gzip", false},
+		{"br;q=0", "br", false},
+		{"zstd;q=0.5", "zstd", true},
+		{"*", "zstd", true},
+		{"*;q=0", "gzip", false},
+		{"gzip;q=0, *", "gzip", false},
+		{"*;q=0, GZIP", "gzip", true},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.header, tt.coding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.coding, got, tt.want)
+		}
+	}
+}
+
+// benchmarkCompression compresses a JSON corpus with the given algorithm,
+// reporting the compressed size as a fraction of the original
+func benchmarkCompression(b *testing.B, algorithm string) {
+	corpus := jsonCorpus(500)
+	var compressed []byte
+
+	b.SetBytes(int64(len(corpus)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if compressed, err = compressBody(algorithm, corpus); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(compressed))/float64(len(corpus)), "ratio")
+}
+
+func BenchmarkCompression_Gzip(b *testing.B) {
+	benchmarkCompression(b, CompressionGzip)
+}
+
+func BenchmarkCompression_Brotli(b *testing.B) {
+	benchmarkCompression(b, CompressionBrotli)
+}
+
+func BenchmarkCompression_Zstd(b *testing.B) {
+	benchmarkCompression(b, CompressionZstd)
+}
@@ -7,40 +7,265 @@ package selectcache
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"path"
 	"strings"
 	"time"
 )
 
+// Eviction policies supported by TTLCache
+const (
+	// EvictionPolicyLRU evicts the least recently used entries first
+	EvictionPolicyLRU = "lru"
+	// EvictionPolicyLFU evicts the least frequently used entries first
+	EvictionPolicyLFU = "lfu"
+)
+
+// Cache key hash lengths supported by KeyHashBits
+const (
+	// DefaultKeyHashBits keeps 64 bits of the key hash, as 16 hex characters
+	DefaultKeyHashBits = 64
+	// FullKeyHashBits keeps the whole SHA-256 key hash, as 64 hex characters
+	FullKeyHashBits = 256
+)
+
 // CacheConfig holds configuration for the transport-layer caching middleware
 type CacheConfig struct {
 	// DefaultTTL is the default time-to-live for cached responses
 	DefaultTTL time.Duration `json:"default_ttl"`
 
-	// ContentTypeTTLs provides per-content-type TTL overrides
+	// ContentTypeTTLs provides per-content-type TTL overrides. Keys are
+	// exact media types ("image/png") or wildcards ("image/*"); an exact
+	// match takes precedence over a wildcard.
 	ContentTypeTTLs map[string]time.Duration `json:"content_type_ttls"`
 
+	// PathTTLs override the TTL for matching request paths, taking precedence
+	// over ContentTypeTTLs. The first matching pattern wins.
+	PathTTLs []PathTTL `json:"path_ttls"`
+
+	// TTLJitter randomizes each entry's expiry by up to ±TTLJitter of its TTL
+	// (0.0-1.0) so entries stored together don't all expire at once.
+	// Zero disables jitter.
+	TTLJitter float64 `json:"ttl_jitter"`
+
+	// SlidingExpiration extends an entry's lifetime by its TTL on every Get,
+	// so entries only expire after going unread for a full TTL
+	SlidingExpiration bool `json:"sliding_expiration"`
+
+	// CacheBuckets maps named TTL tiers to their TTLs, selected per response
+	// via the X-Cache-Bucket header. A known bucket overrides ContentTypeTTLs.
+	CacheBuckets map[string]time.Duration `json:"cache_buckets"`
+
 	// MaxMemoryMB is the maximum memory in megabytes for cache storage
 	MaxMemoryMB int64 `json:"max_memory_mb"`
 
 	// MaxEntries is the maximum number of cache entries
 	MaxEntries int `json:"max_entries"`
 
-	// ExcludedTypes are content types that should never be cached
+	// MaxResponseSize is the largest response body in bytes that will be cached.
+	// Zero means 10% of MaxMemoryMB.
+	MaxResponseSize int64 `json:"max_response_size"`
+
+	// MaxEntrySizeBytes caps the size of a single cache entry (body plus
+	// headers). TTLCache.Set rejects larger entries without evicting others,
+	// and the detector won't cache bodies above it. Zero leaves entries bounded
+	// only by MaxResponseSize or its derived default.
+	MaxEntrySizeBytes int64 `json:"max_entry_size_bytes"`
+
+	// MaxEvictionBatch caps how many entries a single store may evict to
+	// make room. A store that would need more evicts that many and is then
+	// rejected with ErrEvictionBatchExceeded, so later stores carry on making
+	// room and no one Set stalls on a long run of evictions. Zero evicts as
+	// many as needed.
+	MaxEvictionBatch int `json:"max_eviction_batch"`
+
+	// MinBodyBytes is the smallest response body in bytes that will be
+	// cached; tinier responses aren't worth an entry. Zero caches any size.
+	MinBodyBytes int `json:"min_body_bytes"`
+
+	// SoftMemoryThresholdPct is the percentage of MaxMemoryMB that each cleanup
+	// pass trims the cache down to, so Set rarely has to evict synchronously.
+	// Zero disables proactive trimming.
+	SoftMemoryThresholdPct int `json:"soft_memory_threshold_pct"`
+
+	// ShardCount is the number of partitions the cache is split into to reduce
+	// lock contention. Limits are enforced approximately across shards.
+	// Zero uses the default of 16.
+	ShardCount int `json:"shard_count"`
+
+	// EvictionPolicy selects which entries are evicted when limits are reached:
+	// "lru" (default) or "lfu"
+	EvictionPolicy string `json:"eviction_policy"`
+
+	// SegmentQuotas gives content-type classes ("application/json" or
+	// "image/*") a percentage of MaxMemoryMB each. A class over its quota
+	// evicts its own entries, so one class can't starve another. Entries in
+	// no class share what's left. Quotas must total at most 100.
+	SegmentQuotas map[string]int `json:"segment_quotas"`
+
+	// IgnoreQueryParams are query parameters left out of cache keys, such as
+	// tracking parameters; a trailing "*" matches by prefix ("utm_*")
+	IgnoreQueryParams []string `json:"ignore_query_params"`
+
+	// KeyHashBits is how many bits of the SHA-256 request hash cache keys
+	// keep: 64 (the default, for zero), 128, or 256 for the full hash.
+	// Changing it changes every key, so existing entries are no longer found.
+	KeyHashBits int `json:"key_hash_bits"`
+
+	// KeyPrefix is prepended to every cache key, namespacing entries when
+	// several services or tenants share a store. TTLCache.DeleteByPrefix
+	// stays within it.
+	KeyPrefix string `json:"key_prefix"`
+
+	// IncludeHostInKey adds the request's Host header to cache keys, so
+	// virtual hosts behind one listener don't share entries for the same path
+	IncludeHostInKey bool `json:"include_host_in_key"`
+
+	// NormalizeTrailingSlash strips a trailing slash from paths before
+	// building cache keys, so "/api/users/" and "/api/users" share entries.
+	// The root path "/" is left alone.
+	NormalizeTrailingSlash bool `json:"normalize_trailing_slash"`
+
+	// IncludedTypes, when non-empty, switches to allowlist mode: only
+	// responses whose content type contains one of these are cached, and
+	// anything unlisted (including a missing Content-Type) is rejected.
+	// ExcludedTypes and HTML detection still apply on top, so a type that is
+	// both included and excluded is not cached.
+	IncludedTypes []string `json:"included_types"`
+
+	// ExcludedTypes are content types that should never be cached, matched
+	// as substrings or by major type ("image/*")
 	ExcludedTypes []string `json:"excluded_types"`
 
+	// CacheHitMarkerHeader is the response header that marks a response as
+	// already served from a cache; such responses are not re-cached
+	CacheHitMarkerHeader string `json:"cache_hit_marker_header"`
+
+	// ExposeTTLHeader adds an X-Cache-TTL header to responses served from
+	// cache, giving the whole seconds left until the entry expires
+	ExposeTTLHeader bool `json:"expose_ttl_header"`
+
+	// StripHeaders are response headers removed before an entry is stored.
+	// Empty uses DefaultStripHeaders (Set-Cookie and hop-by-hop headers).
+	StripHeaders []string `json:"strip_headers"`
+
+	// AllowHeaders, when non-empty, switches to allowlist mode: only these
+	// response headers are stored. StripHeaders still applies on top.
+	AllowHeaders []string `json:"allow_headers"`
+
+	// RequireExplicitCacheControl caches only responses the origin marked
+	// cacheable with Cache-Control public or a positive s-maxage or max-age,
+	// on top of the other rules
+	RequireExplicitCacheControl bool `json:"require_explicit_cache_control"`
+
+	// NoCacheOnSetCookie marks responses carrying Set-Cookie as
+	// non-cacheable, since they usually belong to one user's session
+	NoCacheOnSetCookie bool `json:"no_cache_on_set_cookie"`
+
 	// EnableMetrics determines if performance metrics are collected
 	EnableMetrics bool `json:"enable_metrics"`
 
 	// CleanupInterval is how often expired entries are removed
 	CleanupInterval time.Duration `json:"cleanup_interval"`
 
+	// CleanupScheduler, when set, runs this cache's cleanup on a shared
+	// scheduler instead of a goroutine of its own; its interval replaces
+	// CleanupInterval. Close deregisters the cache.
+	CleanupScheduler *CleanupScheduler `json:"-"`
+
+	// DisableAutoCleanup stops the cache from cleaning itself, either on its
+	// own goroutine or through CleanupScheduler; expired entries are then
+	// only removed when read or when TTLCache.CleanupNow is called
+	DisableAutoCleanup bool `json:"disable_auto_cleanup"`
+
+	// RefreshAhead is how long before expiry recently accessed entries are
+	// proactively refreshed via RefreshFunc. It is checked on each cleanup
+	// pass, so it should be larger than CleanupInterval. Zero disables refresh.
+	RefreshAhead time.Duration `json:"refresh_ahead"`
+
+	// ImmutableTTL is how long responses marked Cache-Control: immutable are
+	// cached, in place of their max-age; a cache bucket or X-Cache-TTL still
+	// wins. Their entries are never stale. Zero ignores immutable.
+	ImmutableTTL time.Duration `json:"immutable_ttl"`
+
+	// StoreFailureThreshold is the number of consecutive store failures after
+	// which the store circuit breaker opens and stores are skipped, passing
+	// responses through uncached. Oversized entries don't count as failures.
+	// Zero disables the breaker.
+	StoreFailureThreshold int `json:"store_failure_threshold"`
+
+	// StoreCircuitCooldown is how long the store circuit stays open before a
+	// trial store is attempted. Zero uses the default of 30 seconds.
+	StoreCircuitCooldown time.Duration `json:"store_circuit_cooldown"`
+
+	// RefreshFunc re-fetches the response for a cache key during refresh-ahead
+	RefreshFunc func(key string) (*CachedResponse, error) `json:"-"`
+
+	// Logger receives structured cache events; nil disables logging
+	Logger Logger `json:"-"`
+
+	// Clock is the time source for entry expiry and access times; nil uses
+	// the system clock. Latency metrics always use real time.
+	Clock Clock `json:"-"`
+
+	// OnEvict is called whenever an entry leaves the cache through eviction,
+	// expiry or Delete (but not Clear). It runs after the cache locks are
+	// released, so it may safely call back into the cache.
+	OnEvict func(key string, entry *CacheEntry) `json:"-"`
+
 	// BufferSize is the size of the read buffer for connection analysis
 	BufferSize int `json:"buffer_size"`
 
-	// ConnectionTimeout is the maximum time to wait for connection analysis
+	// ConnectionTimeout is the maximum time a connection may sit idle (no
+	// reads or writes) before it is closed. A request still waiting for its
+	// response doesn't count as idle.
 	ConnectionTimeout time.Duration `json:"connection_timeout"`
+
+	// MaxConnections caps the number of connections a CachingListener holds
+	// open at once. Zero means unlimited.
+	MaxConnections int `json:"max_connections"`
+
+	// RejectExcessConnections makes Accept close connections beyond
+	// MaxConnections and return ErrTooManyConnections, instead of blocking
+	// until a slot frees
+	RejectExcessConnections bool `json:"reject_excess_connections"`
+
+	// DrainTimeout is how long CachingListener.Close waits for in-flight
+	// connections to finish before force-closing them. Zero closes them
+	// immediately.
+	DrainTimeout time.Duration `json:"drain_timeout"`
+
+	// ResponseSettleTimeout, when positive, analyzes a buffered response
+	// whose headers are complete once it has seen no writes for this long,
+	// for slow handlers that never give a clear sign of completion. A
+	// response short of its Content-Length or final chunk is still not
+	// cached, and responses with neither are only analyzed once they settle
+	// rather than by write-size heuristics that can cut them short. Zero
+	// relies on framing and those heuristics alone.
+	ResponseSettleTimeout time.Duration `json:"response_settle_timeout"`
+
+	// MaxResponseHeaderBytes and MaxResponseHeaders bound the header block of
+	// a response the transport layer will analyze. Larger or malformed
+	// header blocks are passed through without being cached. Zero uses
+	// 16KB and 100 header lines.
+	MaxResponseHeaderBytes int `json:"max_response_header_bytes"`
+	MaxResponseHeaders     int `json:"max_response_headers"`
+
+	// AnalysisWorkers, when positive, moves content detection and storing of
+	// complete responses off the write path onto this many workers shared by
+	// a CachingListener's connections. When they are all busy and their queue
+	// is full, the response is not cached and analysis_pool_full is recorded
+	// rather than the write waiting. Zero analyzes inline. The pool is sized
+	// when the listener is created.
+	AnalysisWorkers int `json:"analysis_workers"`
 }
 
+// Defaults for CacheConfig.MaxResponseHeaderBytes and MaxResponseHeaders
+const (
+	defaultMaxResponseHeaderBytes = 16 * 1024
+	defaultMaxResponseHeaders     = 100
+)
+
 // DefaultCacheConfig returns sensible defaults for the caching middleware
 func DefaultCacheConfig() *CacheConfig {
 	return &CacheConfig{
@@ -48,14 +273,21 @@ func DefaultCacheConfig() *CacheConfig {
 		ContentTypeTTLs: make(map[string]time.Duration),
 		MaxMemoryMB:     512,   // 512MB default limit
 		MaxEntries:      10000, // 10k entries default
+		ShardCount:      16,
+		EvictionPolicy:  EvictionPolicyLRU,
 		ExcludedTypes: []string{
 			"text/html",
 			"application/xhtml+xml",
 		},
-		EnableMetrics:     true,
-		CleanupInterval:   5 * time.Minute,
-		BufferSize:        8192, // 8KB buffer for analysis
-		ConnectionTimeout: 30 * time.Second,
+		CacheHitMarkerHeader: "X-Cache-Status",
+		StripHeaders:         DefaultStripHeaders(),
+		NoCacheOnSetCookie:   true,
+		IncludeHostInKey:     true,
+		KeyHashBits:          DefaultKeyHashBits,
+		EnableMetrics:        true,
+		CleanupInterval:      5 * time.Minute,
+		BufferSize:           8192, // 8KB buffer for analysis
+		ConnectionTimeout:    30 * time.Second,
 	}
 }
 
@@ -77,6 +309,18 @@ func (c *CacheConfig) Validate() error {
 		return err
 	}
 
+	if err := c.validateCacheBuckets(); err != nil {
+		return err
+	}
+
+	if err := c.validatePathTTLs(); err != nil {
+		return err
+	}
+
+	if err := validateSegmentQuotas(c.SegmentQuotas); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -90,6 +334,22 @@ func (c *CacheConfig) validateTimeSettings() error {
 		return fmt.Errorf("cleanup interval must be positive, got %v", c.CleanupInterval)
 	}
 
+	if c.RefreshAhead < 0 {
+		return fmt.Errorf("refresh ahead must not be negative, got %v", c.RefreshAhead)
+	}
+
+	if c.ImmutableTTL < 0 {
+		return fmt.Errorf("immutable TTL must not be negative, got %v", c.ImmutableTTL)
+	}
+
+	if c.TTLJitter < 0 || c.TTLJitter > 1 {
+		return fmt.Errorf("TTL jitter must be between 0 and 1, got %v", c.TTLJitter)
+	}
+
+	if c.StoreCircuitCooldown < 0 {
+		return fmt.Errorf("store circuit cooldown must not be negative, got %v", c.StoreCircuitCooldown)
+	}
+
 	return nil
 }
 
@@ -103,6 +363,44 @@ func (c *CacheConfig) validateResourceLimits() error {
 		return fmt.Errorf("max entries must be positive, got %d", c.MaxEntries)
 	}
 
+	if c.ShardCount < 0 {
+		return fmt.Errorf("shard count must not be negative, got %d", c.ShardCount)
+	}
+
+	if c.MaxResponseSize < 0 {
+		return fmt.Errorf("max response size must not be negative, got %d", c.MaxResponseSize)
+	}
+
+	if c.MaxEntrySizeBytes < 0 {
+		return fmt.Errorf("max entry size must not be negative, got %d", c.MaxEntrySizeBytes)
+	}
+
+	if c.MaxEvictionBatch < 0 {
+		return fmt.Errorf("max eviction batch must not be negative, got %d", c.MaxEvictionBatch)
+	}
+
+	if err := validateKeyHashBits(c.KeyHashBits); err != nil {
+		return err
+	}
+
+	if c.StoreFailureThreshold < 0 {
+		return fmt.Errorf("store failure threshold must not be negative, got %d", c.StoreFailureThreshold)
+	}
+
+	if c.MinBodyBytes < 0 {
+		return fmt.Errorf("min body bytes must not be negative, got %d", c.MinBodyBytes)
+	}
+
+	if c.SoftMemoryThresholdPct < 0 || c.SoftMemoryThresholdPct > 100 {
+		return fmt.Errorf("soft memory threshold must be between 0 and 100 percent, got %d", c.SoftMemoryThresholdPct)
+	}
+
+	switch c.EvictionPolicy {
+	case "", EvictionPolicyLRU, EvictionPolicyLFU:
+	default:
+		return fmt.Errorf("eviction policy must be %q or %q, got %q", EvictionPolicyLRU, EvictionPolicyLFU, c.EvictionPolicy)
+	}
+
 	return nil
 }
 
@@ -116,9 +414,39 @@ func (c *CacheConfig) validateNetworkSettings() error {
 		return fmt.Errorf("connection timeout must be positive, got %v", c.ConnectionTimeout)
 	}
 
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("max connections must not be negative, got %d", c.MaxConnections)
+	}
+
+	if c.DrainTimeout < 0 {
+		return fmt.Errorf("drain timeout must not be negative, got %v", c.DrainTimeout)
+	}
+
+	if c.ResponseSettleTimeout < 0 {
+		return fmt.Errorf("response settle timeout must not be negative, got %v", c.ResponseSettleTimeout)
+	}
+
+	if c.MaxResponseHeaderBytes < 0 || c.MaxResponseHeaders < 0 {
+		return fmt.Errorf("response header limits must not be negative, got %d bytes and %d headers", c.MaxResponseHeaderBytes, c.MaxResponseHeaders)
+	}
+
+	if c.AnalysisWorkers < 0 {
+		return fmt.Errorf("analysis workers must not be negative, got %d", c.AnalysisWorkers)
+	}
+
 	return nil
 }
 
+// validateKeyHashBits checks that a key hash length is supported; zero
+// selects the default
+func validateKeyHashBits(bits int) error {
+	switch bits {
+	case 0, DefaultKeyHashBits, 128, FullKeyHashBits:
+		return nil
+	}
+	return fmt.Errorf("key hash bits must be %d, 128 or %d, got %d", DefaultKeyHashBits, FullKeyHashBits, bits)
+}
+
 // validateContentTypeTTLs validates TTL values for configured content types
 func (c *CacheConfig) validateContentTypeTTLs() error {
 	for contentType, ttl := range c.ContentTypeTTLs {
@@ -130,6 +458,31 @@ func (c *CacheConfig) validateContentTypeTTLs() error {
 	return nil
 }
 
+// validateCacheBuckets validates TTL values for configured cache buckets
+func (c *CacheConfig) validateCacheBuckets() error {
+	for bucket, ttl := range c.CacheBuckets {
+		if ttl <= 0 {
+			return fmt.Errorf("TTL for cache bucket %s must be positive, got %v", bucket, ttl)
+		}
+	}
+
+	return nil
+}
+
+// validatePathTTLs validates patterns and TTL values for path overrides
+func (c *CacheConfig) validatePathTTLs() error {
+	for _, rule := range c.PathTTLs {
+		if rule.TTL <= 0 {
+			return fmt.Errorf("TTL for path pattern %s must be positive, got %v", rule.Pattern, rule.TTL)
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %s: %w", rule.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
 // LoadFromJSON loads configuration from JSON bytes
 func (c *CacheConfig) LoadFromJSON(data []byte) error {
 	return json.Unmarshal(data, c)
@@ -140,22 +493,134 @@ func (c *CacheConfig) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(c, "", "  ")
 }
 
-// GetTTLForContentType returns the TTL for a specific content type,
-// falling back to DefaultTTL if no specific TTL is configured
+// GetTTLForContentType returns the TTL for a specific content type, trying
+// an exact match before a "type/*" wildcard and falling back to DefaultTTL if
+// no specific TTL is configured
 func (c *CacheConfig) GetTTLForContentType(contentType string) time.Duration {
-	if ttl, exists := c.ContentTypeTTLs[contentType]; exists {
+	if ttl, exists := ttlForContentType(c.ContentTypeTTLs, contentType); exists {
 		return ttl
 	}
 	return c.DefaultTTL
 }
 
-// IsContentTypeExcluded checks if a content type should be excluded from caching
+// ttlForContentType looks up a content type's TTL, trying an exact match
+// before its media type and then a "type/*" wildcard
+func ttlForContentType(ttls map[string]time.Duration, contentType string) (time.Duration, bool) {
+	if ttl, exists := ttls[contentType]; exists {
+		return ttl, true
+	}
+	mediaType := normalizeMediaType(contentType)
+	if ttl, exists := ttls[mediaType]; exists {
+		return ttl, true
+	}
+	if major, _, found := strings.Cut(mediaType, "/"); found {
+		if ttl, exists := ttls[major+"/*"]; exists {
+			return ttl, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeMediaType lowercases a Content-Type and drops its parameters
+func normalizeMediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// contentTypeMatches reports whether a content type matches a configured
+// pattern: "type/*" matches any subtype, anything else matches as a
+// case-insensitive substring
+func contentTypeMatches(contentType, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	if prefix, found := strings.CutSuffix(pattern, "/*"); found {
+		return strings.HasPrefix(normalizeMediaType(contentType), prefix+"/")
+	}
+	return strings.Contains(strings.ToLower(contentType), pattern)
+}
+
+// MaxCacheableSize returns the largest response body size in bytes that may be
+// cached: the smaller of MaxResponseSize and MaxEntrySizeBytes when set,
+// defaulting to 10% of the total cache memory
+func (c *CacheConfig) MaxCacheableSize() int64 {
+	limit := c.MaxMemoryMB * 1024 * 1024 / 10
+	if c.MaxResponseSize > 0 {
+		limit = c.MaxResponseSize
+	}
+	if c.MaxEntrySizeBytes > 0 && (c.MaxResponseSize <= 0 || c.MaxEntrySizeBytes < limit) {
+		limit = c.MaxEntrySizeBytes
+	}
+	return limit
+}
+
+// responseHeaderLimits returns the largest response header block, in bytes
+// and in header lines, that will be analyzed for caching
+func (c *CacheConfig) responseHeaderLimits() (maxBytes, maxHeaders int) {
+	maxBytes, maxHeaders = c.MaxResponseHeaderBytes, c.MaxResponseHeaders
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxResponseHeaderBytes
+	}
+	if maxHeaders <= 0 {
+		maxHeaders = defaultMaxResponseHeaders
+	}
+	return maxBytes, maxHeaders
+}
+
+// GetTTLForPath returns the TTL of the first PathTTLs rule matching the
+// request path and whether any rule matched
+func (c *CacheConfig) GetTTLForPath(requestPath string) (time.Duration, bool) {
+	return ttlForPath(c.PathTTLs, requestPath)
+}
+
+// GetTTLForBucket returns the TTL for a named cache bucket and whether
+// the bucket is configured
+func (c *CacheConfig) GetTTLForBucket(bucket string) (time.Duration, bool) {
+	if bucket == "" {
+		return 0, false
+	}
+	ttl, exists := c.CacheBuckets[bucket]
+	return ttl, exists
+}
+
+// IsContentTypeIncluded checks if a content type passes the IncludedTypes
+// allowlist. Every content type passes when the allowlist is empty.
+func (c *CacheConfig) IsContentTypeIncluded(contentType string) bool {
+	if len(c.IncludedTypes) == 0 {
+		return true
+	}
+	if contentType == "" {
+		return false
+	}
+
+	for _, included := range c.IncludedTypes {
+		if contentTypeMatches(contentType, included) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsContentTypeExcluded checks if a content type should be excluded from
+// caching. Patterns match as substrings, or by major type when written as
+// "type/*".
 func (c *CacheConfig) IsContentTypeExcluded(contentType string) bool {
-	contentTypeLower := strings.ToLower(contentType)
 	for _, excluded := range c.ExcludedTypes {
-		if strings.Contains(contentTypeLower, strings.ToLower(excluded)) {
+		if contentTypeMatches(contentType, excluded) {
 			return true
 		}
 	}
 	return false
 }
+
+// WouldCacheContentType reports whether a response with the given content type
+// and status code would be cached under this configuration, and for how long.
+// It applies the same allowlist, exclusion, HTML, status, and TTL logic as the detector
+// without needing a real response.
+func (c *CacheConfig) WouldCacheContentType(contentType string, statusCode int) (bool, time.Duration) {
+	headers := make(http.Header)
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+
+	analysis := NewContentDetector(c).AnalyzeResponse(nil, headers, statusCode)
+	return analysis.IsCacheable, analysis.RecommendedTTL
+}
@@ -40,6 +40,45 @@ func TestCacheConfig_Validate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "soft memory threshold above 100 percent",
+			config: &CacheConfig{
+				DefaultTTL:             time.Minute,
+				MaxMemoryMB:            100,
+				MaxEntries:             1000,
+				SoftMemoryThresholdPct: 120,
+				CleanupInterval:        time.Minute,
+				BufferSize:             4096,
+				ConnectionTimeout:      30 * time.Second,
+			},
+			wantError: true,
+		},
+		{
+			name: "TTL jitter above 1",
+			config: &CacheConfig{
+				DefaultTTL:        time.Minute,
+				TTLJitter:         1.5,
+				MaxMemoryMB:       100,
+				MaxEntries:        1000,
+				CleanupInterval:   time.Minute,
+				BufferSize:        4096,
+				ConnectionTimeout: 30 * time.Second,
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid path TTL pattern",
+			config: &CacheConfig{
+				DefaultTTL:        time.Minute,
+				PathTTLs:          []PathTTL{{Pattern: "/api/[", TTL: time.Minute}},
+				MaxMemoryMB:       100,
+				MaxEntries:        1000,
+				CleanupInterval:   time.Minute,
+				BufferSize:        4096,
+				ConnectionTimeout: 30 * time.Second,
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -87,3 +126,55 @@ func TestCacheConfig_GetTTLForContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheConfig_WouldCacheContentType(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ContentTypeTTLs["image/webp"] = 2 * time.Hour
+
+	tests := []struct {
+		name          string
+		contentType   string
+		statusCode    int
+		expectedCache bool
+		expectedTTL   time.Duration
+	}{
+		{
+			name:          "excluded type",
+			contentType:   "text/html; charset=utf-8",
+			statusCode:    200,
+			expectedCache: false,
+		},
+		{
+			name:          "included with default TTL",
+			contentType:   "application/json",
+			statusCode:    200,
+			expectedCache: true,
+			expectedTTL:   config.DefaultTTL,
+		},
+		{
+			name:          "included with specific TTL",
+			contentType:   "image/webp",
+			statusCode:    200,
+			expectedCache: true,
+			expectedTTL:   2 * time.Hour,
+		},
+		{
+			name:          "uncacheable status",
+			contentType:   "image/webp",
+			statusCode:    500,
+			expectedCache: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, ttl := config.WouldCacheContentType(tt.contentType, tt.statusCode)
+			if cache != tt.expectedCache {
+				t.Errorf("WouldCacheContentType() cache = %v, want %v", cache, tt.expectedCache)
+			}
+			if ttl != tt.expectedTTL {
+				t.Errorf("WouldCacheContentType() ttl = %v, want %v", ttl, tt.expectedTTL)
+			}
+		})
+	}
+}
@@ -9,7 +9,11 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,10 +41,39 @@ type CachingConnection struct {
 	cacheKey       string
 	currentRequest *http.Request
 
+	// Set once the buffered response exceeds the cacheable size cap or
+	// header limits; buffering is abandoned until the next request is parsed
+	responseTooLarge bool
+
+	// Fires once a buffered response stops receiving writes for
+	// ResponseSettleTimeout; guarded by writeMu
+	settleTimer *time.Timer
+
+	// Workers that detect and store complete responses when AnalysisWorkers
+	// is set; nil analyzes inline
+	analysisPool *analysisPool
+
 	// Connection state
-	closed   bool
-	readPos  int
-	writePos int
+	acceptedAt time.Time
+	closed     bool
+	readPos    int
+	writePos   int
+
+	// Remaining TTL of the last response served from cache, and whether it
+	// was stale
+	servedTTL   time.Duration
+	servedStale bool
+
+	// Set once a 101 Switching Protocols response is written; from then on the
+	// connection carries another protocol and is passed through untouched
+	passthrough atomic.Bool
+
+	// Idle timeout tracking; lastActivity holds UnixNano of the last Read/Write,
+	// and awaitingResponse is set from a complete request header block until
+	// the server starts writing, so a slow handler isn't taken for idleness
+	lastActivity     atomic.Int64
+	awaitingResponse atomic.Bool
+	idleTimer        *time.Timer
 
 	// Timeouts
 	readDeadline  time.Time
@@ -54,14 +87,71 @@ type CachingConnection struct {
 func NewCachingConnection(conn net.Conn, cache *TTLCache, config *CacheConfig, metrics *CacheMetrics, detector *ContentDetector) *CachingConnection {
 	id := generateConnectionID()
 
-	return &CachingConnection{
-		Conn:     conn,
-		id:       id,
-		cache:    cache,
-		config:   config,
-		metrics:  metrics,
-		detector: detector,
+	c := &CachingConnection{
+		Conn:       conn,
+		id:         id,
+		cache:      cache,
+		config:     config,
+		metrics:    metrics,
+		detector:   detector,
+		acceptedAt: time.Now(),
+	}
+	c.touch()
+
+	if config != nil && config.ConnectionTimeout > 0 {
+		c.idleTimer = time.AfterFunc(config.ConnectionTimeout, c.checkIdle)
+	}
+
+	return c
+}
+
+// touch records activity on the connection for idle timeout tracking
+func (c *CachingConnection) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+// recordBufferOverflow counts a buffer cleared for exceeding its size limit,
+// which leaves the traffic on it uncached
+func (c *CachingConnection) recordBufferOverflow(name string) {
+	if c.metrics != nil {
+		c.metrics.RecordError(name)
+	}
+}
+
+// checkIdle runs when the idle timer fires. If there was activity since the
+// timer was armed, or a request is still waiting for its response, it is
+// re-armed; otherwise the connection is closed. No locks are held here, so
+// Close can take them freely.
+func (c *CachingConnection) checkIdle() {
+	c.stateMu.RLock()
+	closed := c.closed
+	c.stateMu.RUnlock()
+
+	if closed {
+		return
+	}
+
+	timeout := c.config.ConnectionTimeout
+	if c.awaitingResponse.Load() {
+		c.idleTimer.Reset(timeout)
+		return
+	}
+	idle := time.Since(time.Unix(0, c.lastActivity.Load()))
+	if idle < timeout {
+		c.idleTimer.Reset(timeout - idle)
+		return
+	}
+
+	if c.metrics != nil {
+		c.metrics.RecordError("connection_idle_timeout")
 	}
+	c.Close()
+}
+
+// containsHeaderEnd reports whether b holds the blank line ending an HTTP
+// header block
+func containsHeaderEnd(b []byte) bool {
+	return bytes.Contains(b, []byte("\r\n\r\n")) || bytes.Contains(b, []byte("\n\n"))
 }
 
 // ID returns the unique identifier for this connection
@@ -82,7 +172,10 @@ func (c *CachingConnection) Read(b []byte) (int, error) {
 
 	// Read from underlying connection first (no locks held)
 	n, err := c.Conn.Read(b)
-	if err != nil {
+	if n > 0 {
+		c.touch()
+	}
+	if err != nil || c.passthrough.Load() {
 		return n, err
 	}
 	// Only lock for buffer operations
@@ -92,10 +185,17 @@ func (c *CachingConnection) Read(b []byte) (int, error) {
 	if len(c.requestBuffer)+n > maxBufferSize {
 		// Clear buffer and reset to prevent unbounded growth
 		c.requestBuffer = c.requestBuffer[:0]
+		c.recordBufferOverflow("request_buffer_overflow")
 	}
 
 	c.requestBuffer = append(c.requestBuffer, b[:n]...)
 
+	// The end of a header block, possibly split across reads, means the
+	// server owes a response
+	if tail := len(c.requestBuffer) - n - 3; containsHeaderEnd(c.requestBuffer[max(tail, 0):]) {
+		c.awaitingResponse.Store(true)
+	}
+
 	// Check if we need to parse HTTP request
 	needsParsing := !c.isHTTPRequest && len(c.requestBuffer) > 0
 	requestBufferCopy := make([]byte, len(c.requestBuffer))
@@ -104,6 +204,7 @@ func (c *CachingConnection) Read(b []byte) (int, error) {
 	// If buffer is getting large and we can't parse HTTP, clear it
 	if len(c.requestBuffer) > 8192 && !c.isHTTPRequest {
 		c.requestBuffer = c.requestBuffer[:0]
+		c.recordBufferOverflow("request_buffer_overflow")
 	}
 
 	c.readMu.Unlock()
@@ -118,6 +219,13 @@ func (c *CachingConnection) Read(b []byte) (int, error) {
 
 // Write intercepts write operations to cache responses
 func (c *CachingConnection) Write(b []byte) (int, error) {
+	c.touch()
+	c.awaitingResponse.Store(false)
+
+	if c.passthrough.Load() {
+		return c.Conn.Write(b)
+	}
+
 	// Check for cached response first
 	if cached, written := c.tryServeCachedResponse(b); cached {
 		return written, nil
@@ -140,6 +248,7 @@ func (c *CachingConnection) tryServeCachedResponse(b []byte) (bool, int) {
 	c.stateMu.RLock()
 	closed := c.closed
 	cacheKey := c.cacheKey
+	req := c.currentRequest
 	c.stateMu.RUnlock()
 
 	if closed {
@@ -151,9 +260,16 @@ func (c *CachingConnection) tryServeCachedResponse(b []byte) (bool, int) {
 			// Clear cache key to prevent subsequent cache lookups on same connection
 			c.stateMu.Lock()
 			c.cacheKey = ""
+			c.servedTTL = entry.RemainingTTL()
+			c.servedStale = entry.IsStale()
 			c.stateMu.Unlock()
-			// Return cached response
-			cachedData := c.buildHTTPResponse(entry)
+			// Answer a matching conditional request without the body
+			var cachedData []byte
+			if req != nil && etagMatches(req.Header.Get("If-None-Match"), entry.ETag) {
+				cachedData = c.buildNotModifiedResponse(entry)
+			} else {
+				cachedData = c.buildHTTPResponse(entry)
+			}
 			written, _ := c.writeCachedResponse(cachedData, len(b))
 			return true, written
 		}
@@ -181,18 +297,51 @@ func (c *CachingConnection) writeAndBufferResponse(b []byte) (int, error) {
 	// Only lock for buffer operations
 	c.writeMu.Lock()
 
+	// Skip buffering once the response is known to be too large to cache
+	if c.responseTooLarge {
+		c.writeMu.Unlock()
+		return n, err
+	}
+
 	// Check buffer size limit to prevent memory leaks
 	if len(c.responseBuffer)+len(b) > maxBufferSize {
 		// Clear buffer and reset to prevent unbounded growth
 		c.responseBuffer = c.responseBuffer[:0]
+		c.recordBufferOverflow("response_buffer_overflow")
 	}
 
 	c.responseBuffer = append(c.responseBuffer, b...)
 
-	// If response buffer is getting large and we haven't analyzed yet, clear it periodically
-	// This prevents memory buildup for non-HTTP traffic or failed parsing
-	if len(c.responseBuffer) > 16384 { // 16KB threshold
-		c.responseBuffer = c.responseBuffer[:0]
+	// A protocol upgrade ends HTTP on this connection; stop buffering for good
+	if isSwitchingProtocols(c.responseBuffer) {
+		c.responseBuffer = nil
+		c.passthrough.Store(true)
+		c.writeMu.Unlock()
+		c.enterPassthrough()
+		return n, err
+	}
+
+	// Abandon caching as soon as the body crosses the size cap rather than
+	// buffering a response that would be rejected anyway
+	if c.exceedsResponseSizeCap() {
+		c.responseBuffer = nil
+		c.responseTooLarge = true
+		if c.metrics != nil {
+			c.metrics.RecordError("response_too_large")
+		}
+		c.writeMu.Unlock()
+		return n, err
+	}
+
+	// A header block that outgrows the header limits is never analyzed, and
+	// non-HTTP traffic never completes one; stop buffering either rather
+	// than let it build up. Framed HTTP bodies are bounded by the size cap.
+	if c.exceedsResponseHeaderLimits() {
+		c.responseBuffer = nil
+		c.responseTooLarge = true
+		if c.metrics != nil {
+			c.metrics.RecordError("response_headers_too_large")
+		}
 	}
 
 	c.writeMu.Unlock()
@@ -200,12 +349,62 @@ func (c *CachingConnection) writeAndBufferResponse(b []byte) (int, error) {
 	return n, err
 }
 
+// enterPassthrough drops request state after a protocol upgrade so the
+// upgraded stream is never parsed or matched against the cache
+func (c *CachingConnection) enterPassthrough() {
+	c.readMu.Lock()
+	c.requestBuffer = nil
+	c.readMu.Unlock()
+
+	c.stateMu.Lock()
+	c.cacheKey = ""
+	c.stateMu.Unlock()
+}
+
+// isSwitchingProtocols reports whether a buffered response starts with a
+// 101 Switching Protocols status line
+func isSwitchingProtocols(buf []byte) bool {
+	return len(buf) >= 12 && bytes.HasPrefix(buf, []byte("HTTP/1.")) && string(buf[8:12]) == " 101"
+}
+
+// exceedsResponseSizeCap checks if the buffered response body has grown past
+// the configured cacheable size. Caller must hold writeMu.
+func (c *CachingConnection) exceedsResponseSizeCap() bool {
+	bodySize := len(c.responseBuffer)
+	if headerEnd := bytes.Index(c.responseBuffer, []byte("\r\n\r\n")); headerEnd != -1 {
+		bodySize -= headerEnd + 4
+	}
+	return int64(bodySize) > c.config.MaxCacheableSize()
+}
+
+// exceedsResponseHeaderLimits checks if the buffered response header block,
+// complete or not, is larger than the configured header limits. Caller must
+// hold writeMu.
+func (c *CachingConnection) exceedsResponseHeaderLimits() bool {
+	maxBytes, maxHeaders := c.config.responseHeaderLimits()
+
+	headerEnd, _ := splitResponseHeaders(c.responseBuffer)
+	if headerEnd == -1 {
+		headerEnd = len(c.responseBuffer)
+	}
+	if headerEnd > maxBytes {
+		return true
+	}
+	// Lines after the status line are headers
+	return bytes.Count(c.responseBuffer[:headerEnd], []byte("\n")) > maxHeaders
+}
+
 // checkAndAnalyzeResponse determines if response analysis is needed and triggers it.
 func (c *CachingConnection) checkAndAnalyzeResponse(b []byte) {
+	if c.passthrough.Load() {
+		return
+	}
+
 	c.writeMu.Lock()
 	responseBufferCopy := make([]byte, len(c.responseBuffer))
 	copy(responseBufferCopy, c.responseBuffer)
 	needsAnalysis := c.shouldAnalyzeResponse(b)
+	c.scheduleSettle(needsAnalysis)
 	c.writeMu.Unlock()
 
 	if needsAnalysis {
@@ -216,22 +415,119 @@ func (c *CachingConnection) checkAndAnalyzeResponse(b []byte) {
 	}
 }
 
+// scheduleSettle re-arms the settle timer after a write that left a response
+// with complete headers unanalyzed, and stops it otherwise. Caller must hold
+// writeMu.
+func (c *CachingConnection) scheduleSettle(analyzing bool) {
+	timeout := c.config.ResponseSettleTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	pending := !analyzing && len(c.responseBuffer) > 0
+	if pending {
+		headerEnd, _ := splitResponseHeaders(c.responseBuffer)
+		pending = headerEnd != -1
+	}
+
+	switch {
+	case !pending:
+		if c.settleTimer != nil {
+			c.settleTimer.Stop()
+		}
+	case c.settleTimer == nil:
+		c.settleTimer = time.AfterFunc(timeout, c.settle)
+	default:
+		c.settleTimer.Reset(timeout)
+	}
+}
+
+// settle analyzes a response that has stopped receiving writes. Framing is
+// still respected, so an incomplete framed body stays uncached.
+func (c *CachingConnection) settle() {
+	c.stateMu.RLock()
+	closed := c.closed
+	cacheKey := c.cacheKey
+	c.stateMu.RUnlock()
+
+	if closed || c.passthrough.Load() {
+		return
+	}
+
+	c.writeMu.Lock()
+	responseBufferCopy := make([]byte, len(c.responseBuffer))
+	copy(responseBufferCopy, c.responseBuffer)
+	c.writeMu.Unlock()
+
+	c.analyzeAndCacheResponseFromBuffer(responseBufferCopy, cacheKey)
+}
+
 // shouldAnalyzeResponse determines if the current response data should be analyzed for caching.
+// Responses with a Content-Length are analyzed once the full body is buffered,
+// chunked responses once the terminating chunk may have arrived; anything else
+// waits for the response to settle when ResponseSettleTimeout is set, or falls
+// back to treating a small write after the headers as the end of the response.
 func (c *CachingConnection) shouldAnalyzeResponse(b []byte) bool {
 	if len(c.responseBuffer) == 0 {
 		return false
 	}
 
-	headerEnd := bytes.Index(c.responseBuffer, []byte("\r\n\r\n"))
+	headerEnd, bodyStart := splitResponseHeaders(c.responseBuffer)
 	if headerEnd == -1 {
-		headerEnd = bytes.Index(c.responseBuffer, []byte("\n\n"))
+		return false
+	}
+
+	contentLength, chunked := responseFraming(c.responseBuffer[:headerEnd])
+	switch {
+	case contentLength >= 0:
+		return int64(len(c.responseBuffer)-bodyStart) >= contentLength
+	case chunked:
+		return bytes.HasSuffix(c.responseBuffer, []byte("\r\n\r\n"))
+	case c.config.ResponseSettleTimeout > 0:
+		return false // Unframed; wait for the response to settle
 	}
 
-	// Only analyze if we have headers and this write might be the last one
-	// (heuristic: small write after headers suggests end of response)
-	return headerEnd != -1 && (len(b) < 1024 ||
+	return len(b) < 1024 ||
 		bytes.Contains(b, []byte("\r\n\r\n")) ||
-		bytes.Contains(b, []byte("\n\n")))
+		bytes.Contains(b, []byte("\n\n"))
+}
+
+// splitResponseHeaders locates the end of the header block in a buffered
+// response. It returns -1 for headerEnd while the headers are incomplete.
+func splitResponseHeaders(buf []byte) (headerEnd, bodyStart int) {
+	if i := bytes.Index(buf, []byte("\r\n\r\n")); i != -1 {
+		return i, i + 4
+	}
+	if i := bytes.Index(buf, []byte("\n\n")); i != -1 {
+		return i, i + 2
+	}
+	return -1, -1
+}
+
+// responseFraming scans raw response headers for the body framing. It returns
+// a contentLength of -1 when no valid Content-Length header is present.
+func responseFraming(headerData []byte) (contentLength int64, chunked bool) {
+	contentLength = -1
+	for _, line := range bytes.Split(headerData, []byte("\n")) {
+		name, value, found := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+		if !found {
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		switch {
+		case bytes.EqualFold(name, []byte("Content-Length")):
+			if n, err := strconv.ParseInt(string(value), 10, 64); err == nil && n >= 0 {
+				contentLength = n
+			}
+		case bytes.EqualFold(name, []byte("Transfer-Encoding")):
+			chunked = bytes.Contains(bytes.ToLower(value), []byte("chunked"))
+		}
+	}
+	if chunked {
+		// Transfer-Encoding takes precedence over Content-Length (RFC 9112 6.3)
+		contentLength = -1
+	}
+	return contentLength, chunked
 }
 
 // Close closes the connection and performs cleanup
@@ -253,6 +549,9 @@ func (c *CachingConnection) Close() error {
 
 	c.writeMu.Lock()
 	c.responseBuffer = nil
+	if c.settleTimer != nil {
+		c.settleTimer.Stop()
+	}
 	c.writeMu.Unlock()
 
 	// Now acquire state lock and set closed flag
@@ -266,6 +565,10 @@ func (c *CachingConnection) Close() error {
 
 	c.closed = true
 
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+
 	// Call the close callback if set
 	if c.closeCallback != nil {
 		c.closeCallback()
@@ -341,8 +644,14 @@ func (c *CachingConnection) tryParseHTTPRequestFromBuffer(requestBuffer []byte)
 	c.requestBuffer = c.requestBuffer[:0]
 	c.readMu.Unlock()
 
-	// Generate cache key for GET and HEAD requests
-	if req.Method == "GET" || req.Method == "HEAD" {
+	// A new request starts a new response, so resume response buffering
+	c.writeMu.Lock()
+	c.responseTooLarge = false
+	c.writeMu.Unlock()
+
+	// Generate cache key for GET and HEAD requests; upgrade handshakes must
+	// always reach the server
+	if (req.Method == "GET" || req.Method == "HEAD") && req.Header.Get("Upgrade") == "" {
 		headers := make(map[string]string)
 
 		// Include caching-relevant headers
@@ -351,12 +660,12 @@ func (c *CachingConnection) tryParseHTTPRequestFromBuffer(requestBuffer []byte)
 				headers[header] = value
 			}
 		}
-
-		query := ""
-		if req.URL.RawQuery != "" {
-			query = req.URL.RawQuery
+		if c.config.IncludeHostInKey {
+			addHostKeyPart(headers, req.Host)
 		}
 
+		query := NormalizeQuery(req.URL.RawQuery, c.config.IgnoreQueryParams)
+
 		// For HEAD requests, use GET method in cache key so they share cache entries
 		// This ensures consistency with the middleware layer behavior
 		method := req.Method
@@ -364,7 +673,7 @@ func (c *CachingConnection) tryParseHTTPRequestFromBuffer(requestBuffer []byte)
 			method = "GET"
 		}
 
-		cacheKey := GenerateCacheKey(method, req.URL.Path, query, headers)
+		cacheKey := c.config.KeyPrefix + GenerateCacheKeyBits(method, keyPath(req.URL.Path, c.config.NormalizeTrailingSlash), query, headers, c.config.KeyHashBits)
 
 		// Update cache key with proper locking
 		c.stateMu.Lock()
@@ -378,6 +687,11 @@ func (c *CachingConnection) analyzeAndCacheResponseFromBuffer(responseBuffer []b
 	// Safely read shared state
 	c.stateMu.RLock()
 	isHTTPRequest := c.isHTTPRequest
+	var requestMethod, requestPath string
+	if c.currentRequest != nil {
+		requestMethod = c.currentRequest.Method
+		requestPath = c.currentRequest.URL.Path
+	}
 	c.stateMu.RUnlock()
 
 	if !isHTTPRequest || cacheKey == "" || len(responseBuffer) == 0 {
@@ -385,37 +699,39 @@ func (c *CachingConnection) analyzeAndCacheResponseFromBuffer(responseBuffer []b
 	}
 
 	// Look for end of HTTP headers
-	headerEnd := bytes.Index(responseBuffer, []byte("\r\n\r\n"))
+	headerEnd, bodyStart := splitResponseHeaders(responseBuffer)
 	if headerEnd == -1 {
-		headerEnd = bytes.Index(responseBuffer, []byte("\n\n"))
-		if headerEnd == -1 {
-			return // Headers not complete yet
-		}
+		return // Headers not complete yet
 	}
 
 	// Parse response headers
 	headerData := responseBuffer[:headerEnd]
-	bodyData := responseBuffer[headerEnd+4:]
+	bodyData := responseBuffer[bodyStart:]
 
 	resp, err := c.parseHTTPResponse(headerData, bodyData)
 	if err != nil {
-		return // Invalid response
+		// A malformed header block won't become valid; drop it
+		if c.metrics != nil {
+			c.metrics.RecordError("response_parse_failed")
+		}
+		c.writeMu.Lock()
+		c.responseBuffer = c.responseBuffer[:0]
+		c.writeMu.Unlock()
+		return
 	}
 
-	// Analyze response for caching
-	analysis := c.detector.AnalyzeResponse(bodyData, resp.Header, resp.StatusCode)
-
-	if analysis.IsCacheable {
-		// Store in cache
-		ttl := analysis.RecommendedTTL
-		if ttl == 0 {
-			ttl = c.config.DefaultTTL
-		}
+	bodyData, complete := decodeResponseBody(resp, bodyData)
+	if !complete {
+		return // Body not fully written yet; keep buffering
+	}
 
-		err := c.cache.Set(cacheKey, bodyData, resp.Header, ttl)
-		if err != nil && c.metrics != nil {
-			c.metrics.RecordError("cache_store_failed")
-		}
+	// Detection and storing may be offloaded; the response is complete
+	// either way, so the buffer is cleared here
+	store := func() { c.storeAnalyzedResponse(requestMethod, requestPath, cacheKey, resp, bodyData) }
+	if c.analysisPool == nil {
+		store()
+	} else if !c.analysisPool.submit(store) && c.metrics != nil {
+		c.metrics.RecordError("analysis_pool_full")
 	}
 
 	// Clear response buffer after successful analysis to prevent memory leaks
@@ -424,6 +740,85 @@ func (c *CachingConnection) analyzeAndCacheResponseFromBuffer(responseBuffer []b
 	c.writeMu.Unlock()
 }
 
+// storeAnalyzedResponse runs content detection on a complete response and
+// caches it if appropriate. A response to HEAD has no body to judge the size
+// of, so it is analyzed without one.
+func (c *CachingConnection) storeAnalyzedResponse(requestMethod, requestPath, cacheKey string, resp *http.Response, bodyData []byte) {
+	// A 206 is only kept when it holds the whole representation, as a 200
+	if resp.StatusCode == http.StatusPartialContent {
+		if !coversWholeRepresentation(resp.Header, bodyData) {
+			if c.metrics != nil {
+				c.metrics.RecordError("partial_content_uncached")
+			}
+			return
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header.Del("Content-Range")
+	}
+
+	// gRPC-Web errors arrive as 200s; only whole, successful calls are kept
+	if !grpcWebCacheable(resp.Header, resp.Trailer, bodyData) {
+		if c.metrics != nil {
+			c.metrics.RecordError("grpc_web_uncached")
+		}
+		return
+	}
+
+	analyzed := bodyData
+	if requestMethod == http.MethodHead {
+		analyzed = nil
+	}
+	analysis := c.detector.AnalyzeResponseForPath(requestPath, analyzed, resp.Header, resp.StatusCode)
+	if !analysis.IsCacheable {
+		return
+	}
+
+	ttl := analysis.RecommendedTTL
+	if ttl == 0 {
+		ttl = c.config.DefaultTTL
+	}
+
+	// Keep the status line so the response is replayed faithfully; the
+	// handler's X-Cache-TTL was meant for this cache, not for clients
+	resp.Header.Del(CacheTTLHeader)
+	entry := c.cache.createCacheEntry(cacheKey, bodyData, resp.Header, ttl)
+	entry.StatusCode = resp.StatusCode
+	entry.StatusText = customReasonPhrase(resp.StatusCode, strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))))
+	entry.Immutable = c.config.ImmutableTTL > 0 && parseCacheControl(resp.Header).immutable()
+
+	// Failed stores are counted by category in the cache itself
+	c.cache.insert(entry)
+}
+
+// decodeResponseBody returns the body as it should be cached. Bodies with a
+// Content-Length are trimmed to it, and chunked bodies are de-chunked with the
+// headers rewritten to carry a Content-Length so the cached entry can be
+// replayed verbatim. complete is false while the body is still short of its
+// Content-Length or the terminating zero-length chunk has not been seen.
+func decodeResponseBody(resp *http.Response, bodyData []byte) (body []byte, complete bool) {
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		if resp.ContentLength < 0 {
+			return bodyData, true
+		}
+		if int64(len(bodyData)) < resp.ContentLength {
+			return nil, false
+		}
+		// Anything past Content-Length belongs to a later response
+		return bodyData[:resp.ContentLength], true
+	}
+
+	decoded, err := io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(bodyData)))
+	if err != nil {
+		return nil, false
+	}
+
+	// http.ReadResponse already strips Transfer-Encoding from the header map
+	resp.Header.Del("Transfer-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(decoded)))
+	return decoded, true
+}
+
 // parseHTTPResponse parses HTTP response using Go's standard library for better performance and compatibility
 func (c *CachingConnection) parseHTTPResponse(headerData, bodyData []byte) (*http.Response, error) {
 	// Reconstruct the full HTTP response for standard library parsing
@@ -451,23 +846,42 @@ func (c *CachingConnection) parseHTTPResponse(headerData, bodyData []byte) (*htt
 	return resp, nil
 }
 
-// buildHTTPResponse constructs an HTTP response from a cache entry
+// buildHTTPResponse constructs an HTTP response from a cache entry, with the
+// original status and a Cache-Control max-age of the entry's remaining TTL
 func (c *CachingConnection) buildHTTPResponse(entry *CacheEntry) []byte {
 	var buf bytes.Buffer
 
-	// Status line (assume HTTP/1.1 and 200 OK for cached responses)
-	buf.WriteString("HTTP/1.1 200 OK\r\n")
+	// Status line; entries stored without a status are 200 OK
+	statusCode := entry.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	buf.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, reasonPhrase(statusCode, entry.StatusText)))
 
-	// Headers
+	// Headers. The stored freshness headers describe the original response,
+	// so they are replaced by the remaining lifetime of the entry, and the
+	// framing is recomputed from the body actually written.
 	for key, values := range entry.Headers {
+		switch key {
+		case "Cache-Control", "Age", "Content-Length", "Transfer-Encoding":
+			continue
+		}
 		for _, value := range values {
 			buf.WriteString(fmt.Sprintf("%s: %s\r\n", key, value))
 		}
 	}
+	maxAge := int64(entry.RemainingTTL() / time.Second)
+	buf.WriteString(fmt.Sprintf("Cache-Control: %s\r\n", withMaxAge(strings.Join(entry.Headers.Values("Cache-Control"), ", "), maxAge)))
+	if bodyAllowedForStatus(statusCode) {
+		buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(entry.Data)))
+	}
 
 	// Add cache-specific headers
 	buf.WriteString("X-Cache-Status: HIT\r\n")
-	buf.WriteString(fmt.Sprintf("X-Cache-Age: %d\r\n", int(time.Since(entry.StoreTime).Seconds())))
+	buf.WriteString(fmt.Sprintf("X-Cache-Age: %d\r\n", int(entry.now().Sub(entry.StoreTime).Seconds())))
+	if c.config.ExposeTTLHeader {
+		buf.WriteString(fmt.Sprintf("%s: %d\r\n", CacheTTLHeader, maxAge))
+	}
 
 	// End of headers
 	buf.WriteString("\r\n")
@@ -478,6 +892,52 @@ func (c *CachingConnection) buildHTTPResponse(entry *CacheEntry) []byte {
 	return buf.Bytes()
 }
 
+// reasonPhrase returns the reason phrase to replay for a status code: the
+// stored custom phrase if there is one, otherwise the standard phrase
+func reasonPhrase(statusCode int, custom string) string {
+	if custom != "" {
+		return custom
+	}
+	return http.StatusText(statusCode)
+}
+
+// customReasonPhrase returns phrase if it differs from the standard phrase
+// for the status code, so only non-standard phrases need storing
+func customReasonPhrase(statusCode int, phrase string) string {
+	if phrase == http.StatusText(statusCode) {
+		return ""
+	}
+	return phrase
+}
+
+// notModifiedHeaders are the stored headers repeated on a 304 response, as the
+// ones a cache must send with it (RFC 9110 15.4.5)
+var notModifiedHeaders = []string{"Content-Location", "ETag", "Expires", "Vary"}
+
+// buildNotModifiedResponse constructs a bodiless 304 Not Modified response
+// for a conditional request matching the cache entry's ETag
+func (c *CachingConnection) buildNotModifiedResponse(entry *CacheEntry) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("HTTP/1.1 304 Not Modified\r\n")
+	for _, key := range notModifiedHeaders {
+		for _, value := range entry.Headers.Values(key) {
+			buf.WriteString(fmt.Sprintf("%s: %s\r\n", key, value))
+		}
+	}
+	maxAge := int64(entry.RemainingTTL() / time.Second)
+	buf.WriteString(fmt.Sprintf("Cache-Control: %s\r\n", withMaxAge(strings.Join(entry.Headers.Values("Cache-Control"), ", "), maxAge)))
+
+	buf.WriteString("X-Cache-Status: HIT\r\n")
+	buf.WriteString(fmt.Sprintf("X-Cache-Age: %d\r\n", int(entry.now().Sub(entry.StoreTime).Seconds())))
+	if c.config.ExposeTTLHeader {
+		buf.WriteString(fmt.Sprintf("%s: %d\r\n", CacheTTLHeader, maxAge))
+	}
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
 // writeCachedResponse writes a cached response directly to the underlying connection
 func (c *CachingConnection) writeCachedResponse(data []byte, originalLength int) (int, error) {
 	_, err := c.Conn.Write(data)
@@ -525,12 +985,23 @@ func (c *CachingConnection) GetStats() ConnectionStats {
 		HasCacheKey:   c.cacheKey != "",
 		RequestSize:   requestSize,
 		ResponseSize:  responseSize,
-		LocalAddr:     c.LocalAddr().String(),
-		RemoteAddr:    c.RemoteAddr().String(),
+		LocalAddr:     addrString(c.LocalAddr()),
+		RemoteAddr:    addrString(c.RemoteAddr()),
 		Closed:        c.closed,
+		Age:           time.Since(c.acceptedAt),
+		ServedTTL:     c.servedTTL,
+		ServedStale:   c.servedStale,
 	}
 }
 
+// addrString formats a network address, tolerating connections without one
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
 // ConnectionStats contains statistics for a caching connection
 type ConnectionStats struct {
 	ID            string `json:"id"`
@@ -541,4 +1012,13 @@ type ConnectionStats struct {
 	LocalAddr     string `json:"local_addr"`
 	RemoteAddr    string `json:"remote_addr"`
 	Closed        bool   `json:"closed"`
+
+	// Age is the time since the connection was accepted
+	Age time.Duration `json:"age"`
+
+	// ServedTTL is the remaining TTL of the last response served from cache
+	// on this connection, and ServedStale whether it was past its freshness
+	// lifetime. Both are zero if nothing was served from cache.
+	ServedTTL   time.Duration `json:"served_ttl"`
+	ServedStale bool          `json:"served_stale"`
 }
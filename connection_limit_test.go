@@ -0,0 +1,135 @@
+package selectcache
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func newLimitedListener(t *testing.T, limit int, reject bool) *CachingListener {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	config := DefaultCacheConfig()
+	config.MaxConnections = limit
+	config.RejectExcessConnections = reject
+	cl := NewCachingListener(inner, config)
+	t.Cleanup(func() { cl.Close() })
+	return cl
+}
+
+// dial opens a client connection that is closed when the test ends
+func dial(t *testing.T, cl *CachingListener) net.Conn {
+	t.Helper()
+	client, err := net.Dial("tcp", cl.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCachingListener_MaxConnectionsRejects(t *testing.T) {
+	const limit = 3
+	cl := newLimitedListener(t, limit, true)
+
+	var accepted []net.Conn
+	for i := 0; i < limit; i++ {
+		dial(t, cl)
+		conn, err := cl.Accept()
+		if err != nil {
+			t.Fatalf("Accept %d failed below the limit: %v", i, err)
+		}
+		accepted = append(accepted, conn)
+	}
+
+	// The N+1th connection is closed and reported as a temporary error
+	excess := dial(t, cl)
+	if _, err := cl.Accept(); !errors.Is(err, ErrTooManyConnections) {
+		t.Fatalf("Expected ErrTooManyConnections, got %v", err)
+	}
+	var netErr net.Error
+	if err := error(ErrTooManyConnections); !errors.As(err, &netErr) || !netErr.Temporary() {
+		t.Error("Expected ErrTooManyConnections to be a temporary net.Error")
+	}
+	excess.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := excess.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the rejected connection to be closed")
+	}
+
+	if n := cl.GetStats().ActiveConnections; n != limit {
+		t.Errorf("Expected %d active connections, got %d", limit, n)
+	}
+	if rejected := cl.GetMetrics().GetStats().Errors["connection_rejected"]; rejected != 1 {
+		t.Errorf("Expected 1 rejected connection in metrics, got %d", rejected)
+	}
+
+	// Closing a connection frees its slot
+	accepted[0].Close()
+	dial(t, cl)
+	if _, err := cl.Accept(); err != nil {
+		t.Errorf("Expected Accept to succeed once a slot freed, got %v", err)
+	}
+}
+
+func TestCachingListener_MaxConnectionsBlocks(t *testing.T) {
+	cl := newLimitedListener(t, 1, false)
+
+	dial(t, cl)
+	first, err := cl.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	dial(t, cl)
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := cl.Accept()
+		accepted <- err
+	}()
+
+	select {
+	case err := <-accepted:
+		t.Fatalf("Expected Accept to block at the limit, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case err := <-accepted:
+		if err != nil {
+			t.Errorf("Expected blocked Accept to succeed once a slot freed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected blocked Accept to resume after a connection closed")
+	}
+}
+
+func TestCachingListener_CloseReleasesBlockedAccept(t *testing.T) {
+	cl := newLimitedListener(t, 1, false)
+
+	dial(t, cl)
+	if _, err := cl.Accept(); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := cl.Accept()
+		accepted <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cl.Close()
+	select {
+	case err := <-accepted:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Expected net.ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to release a blocked Accept")
+	}
+}
@@ -0,0 +1,82 @@
+package selectcache
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachingListener_ConnectionSnapshots(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	const clients = 5
+	accepted := make(chan net.Conn, clients)
+	go func() {
+		for i := 0; i < clients; i++ {
+			conn, err := cachingListener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	var serverConns []net.Conn
+	for i := 0; i < clients; i++ {
+		client, err := net.Dial("tcp", baseListener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer client.Close()
+		serverConns = append(serverConns, <-accepted)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	snapshots := cachingListener.ConnectionSnapshots()
+	if len(snapshots) != clients {
+		t.Fatalf("Expected %d snapshots, got %d", clients, len(snapshots))
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == "" || snapshot.RemoteAddr == "" {
+			t.Errorf("Snapshot missing identity: %+v", snapshot)
+		}
+		if snapshot.Age <= 0 {
+			t.Errorf("Expected positive connection age, got %v", snapshot.Age)
+		}
+	}
+
+	// Snapshots taken while connections close must not deadlock
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, conn := range serverConns {
+			conn.Close()
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			cachingListener.ConnectionSnapshots()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ConnectionSnapshots deadlocked with concurrent Close")
+	}
+	wg.Wait()
+
+	if remaining := len(cachingListener.ConnectionSnapshots()); remaining != 0 {
+		t.Errorf("Expected no live connections after close, got %d", remaining)
+	}
+}
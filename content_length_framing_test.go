@@ -0,0 +1,79 @@
+package selectcache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestContentLengthResponseCachedOnlyWhenComplete verifies that a response
+// delivered in small, oddly-sized writes is not cached until the buffered body
+// reaches its Content-Length
+func TestContentLengthResponseCachedOnlyWhenComplete(t *testing.T) {
+	config := DefaultCacheConfig()
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	mockConn := newMockConn()
+	conn := NewCachingConnection(mockConn, cache, config, metrics, NewContentDetector(config))
+
+	request := []byte("GET /data.json HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	mockConn.writeToReadBuffer(request)
+	if _, err := conn.Read(make([]byte, len(request))); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	key := GenerateCacheKey("GET", "/data.json", "", map[string]string{"Host": "example.com"})
+
+	body := []byte(`{"items":[` + string(bytes.Repeat([]byte(`{"id":1,"name":"item"},`), 400)) + `{"id":0}]}`)
+	response := append([]byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n", len(body))), body...)
+
+	// Writes well under the old 1KB "last write" heuristic
+	for len(response) > 0 {
+		n := 700
+		if n > len(response) {
+			n = len(response)
+		}
+		conn.Write(response[:n])
+		response = response[n:]
+
+		if len(response) > 0 {
+			if _, found := cache.Get(key); found {
+				t.Fatalf("Response cached before the body was complete (%d bytes remaining)", len(response))
+			}
+		}
+	}
+
+	entry, found := cache.Get(key)
+	if !found {
+		t.Fatal("Expected complete response to be cached")
+	}
+	if !bytes.Equal(entry.Data, body) {
+		t.Errorf("Cached body truncated: got %d bytes, want %d", len(entry.Data), len(body))
+	}
+}
+
+func TestResponseFraming(t *testing.T) {
+	tests := []struct {
+		name          string
+		headers       string
+		contentLength int64
+		chunked       bool
+	}{
+		{"content length", "HTTP/1.1 200 OK\r\nContent-Length: 42", 42, false},
+		{"case insensitive", "HTTP/1.1 200 OK\r\ncontent-length:  7 ", 7, false},
+		{"chunked", "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked", -1, true},
+		{"chunked wins", "HTTP/1.1 200 OK\r\nContent-Length: 10\r\nTransfer-Encoding: gzip, chunked", -1, true},
+		{"unframed", "HTTP/1.1 200 OK\r\nContent-Type: text/plain", -1, false},
+		{"invalid length", "HTTP/1.1 200 OK\r\nContent-Length: abc", -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentLength, chunked := responseFraming([]byte(tt.headers))
+			if contentLength != tt.contentLength || chunked != tt.chunked {
+				t.Errorf("responseFraming() = (%d, %v), want (%d, %v)", contentLength, chunked, tt.contentLength, tt.chunked)
+			}
+		})
+	}
+}
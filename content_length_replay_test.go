@@ -0,0 +1,187 @@
+package selectcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// gzipBytes returns data gzip-compressed
+func gzipBytes(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, data); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// TestMiddleware_ReplayRecomputesContentLength verifies that cached responses
+// are served with the length of the bytes actually written rather than the
+// stored Content-Length
+func TestMiddleware_ReplayRecomputesContentLength(t *testing.T) {
+	compressed := gzipBytes(t, "hello, compressed world")
+
+	tests := []struct {
+		name           string
+		headers        http.Header
+		body           []byte
+		rangeHeader    string
+		acceptEncoding string
+		wantBody       []byte
+	}{
+		{
+			name:     "plain",
+			headers:  http.Header{"Content-Type": {"text/plain"}},
+			body:     []byte("hello"),
+			wantBody: []byte("hello"),
+		},
+		{
+			name:        "range",
+			headers:     http.Header{"Content-Type": {"image/png"}, "Accept-Ranges": {"bytes"}},
+			body:        []byte("0123456789"),
+			rangeHeader: "bytes=2-5",
+			wantBody:    []byte("2345"),
+		},
+		{
+			name:           "compressed",
+			headers:        http.Header{"Content-Type": {"text/plain"}, "Content-Encoding": {"gzip"}},
+			body:           compressed,
+			acceptEncoding: "gzip",
+			wantBody:       compressed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := New(DefaultConfig())
+			defer middleware.Close()
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("Request should have been served from cache")
+			}))
+
+			req := httptest.NewRequest("GET", "/resource", nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			// Store the response with a Content-Length that no longer matches
+			tt.headers.Set("Content-Length", "999")
+			middleware.cache.setResponse(middleware.createCacheKey(req), &CachedResponse{
+				StatusCode: http.StatusOK,
+				Headers:    tt.headers,
+				Body:       tt.body,
+				StoreTime:  time.Now(),
+			}, time.Hour)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if !bytes.Equal(rec.Body.Bytes(), tt.wantBody) {
+				t.Errorf("Body = %q, want %q", rec.Body.Bytes(), tt.wantBody)
+			}
+			if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(len(tt.wantBody)); got != want {
+				t.Errorf("Content-Length = %q, want %s", got, want)
+			}
+		})
+	}
+}
+
+// TestMiddleware_HeadKeepsStoredContentLength verifies that a HEAD response
+// cached without a body keeps the Content-Length of the GET body it describes
+func TestMiddleware_HeadKeepsStoredContentLength(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should have been served from cache")
+	}))
+
+	req := httptest.NewRequest("HEAD", "/resource", nil)
+	middleware.cache.setResponse(middleware.createCacheKey(req), &CachedResponse{
+		StatusCode: http.StatusOK,
+		Headers:    http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"42"}},
+		StoreTime:  time.Now(),
+	}, time.Hour)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Length"); got != "42" {
+		t.Errorf("Content-Length = %q, want 42", got)
+	}
+}
+
+// TestCachingListener_ReplayRecomputesContentLength verifies that the
+// transport layer frames cached bodies by their actual length, so clients
+// neither hang nor truncate when the stored Content-Length is stale
+func TestCachingListener_ReplayRecomputesContentLength(t *testing.T) {
+	compressed := gzipBytes(t, "hello, compressed world")
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		body    []byte
+	}{
+		{"plain", http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"999"}}, []byte("hello")},
+		{"chunked", http.Header{"Content-Type": {"text/plain"}, "Transfer-Encoding": {"chunked"}}, []byte("hello")},
+		{"compressed", http.Header{"Content-Type": {"text/plain"}, "Content-Encoding": {"gzip"}, "Content-Length": {"23"}}, compressed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to create listener: %v", err)
+			}
+			cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+			defer cachingListener.Close()
+
+			// The cached response replaces whatever the handler writes
+			server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "fresh")
+			})}
+			go server.Serve(cachingListener)
+			defer server.Close()
+
+			// The client sends no Accept-Encoding, so the key has no headers
+			entry := cachingListener.cache.createCacheEntry(GenerateCacheKey("GET", "/resource", "", map[string]string{"Host": baseListener.Addr().String()}), tt.body, tt.headers, time.Hour)
+			if _, err := cachingListener.cache.insert(entry); err != nil {
+				t.Fatalf("Failed to store entry: %v", err)
+			}
+
+			client := &http.Client{
+				Timeout:   2 * time.Second,
+				Transport: &http.Transport{DisableKeepAlives: true, DisableCompression: true},
+			}
+			resp, err := client.Get("http://" + baseListener.Addr().String() + "/resource")
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+
+			if resp.Header.Get("X-Cache-Status") != "HIT" {
+				t.Fatalf("Expected response from cache, got X-Cache-Status %q", resp.Header.Get("X-Cache-Status"))
+			}
+			if !bytes.Equal(body, tt.body) {
+				t.Errorf("Body = %q, want %q", body, tt.body)
+			}
+			if resp.ContentLength != int64(len(tt.body)) {
+				t.Errorf("Content-Length = %d, want %d", resp.ContentLength, len(tt.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetTTLForContentType_Wildcards(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ContentTypeTTLs = map[string]time.Duration{
+		"image/*":   24 * time.Hour,
+		"image/png": time.Hour,
+		"text/*":    10 * time.Minute,
+	}
+
+	tests := []struct {
+		contentType string
+		want        time.Duration
+	}{
+		{"image/webp", 24 * time.Hour},
+		{"image/png", time.Hour},
+		{"IMAGE/WEBP", 24 * time.Hour},
+		{"text/css; charset=utf-8", 10 * time.Minute},
+		{"application/json", config.DefaultTTL},
+		{"imagery/png", config.DefaultTTL},
+	}
+
+	for _, tt := range tests {
+		if got := config.GetTTLForContentType(tt.contentType); got != tt.want {
+			t.Errorf("GetTTLForContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestIsContentTypeExcluded_Wildcards(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ExcludedTypes = []string{"video/*", "text/event-stream"}
+
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"video/mp4", true},
+		{"Video/WebM; codecs=vp9", true},
+		{"text/event-stream", true},
+		{"image/png", false},
+		{"application/video", false},
+	}
+
+	for _, tt := range tests {
+		if got := config.IsContentTypeExcluded(tt.contentType); got != tt.want {
+			t.Errorf("IsContentTypeExcluded(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestIsContentTypeIncluded_Wildcards(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.IncludedTypes = []string{"image/*"}
+
+	if !config.IsContentTypeIncluded("image/avif") {
+		t.Error("Expected image/avif to match image/*")
+	}
+	if config.IsContentTypeIncluded("application/json") {
+		t.Error("Expected application/json to be rejected")
+	}
+}
+
+func TestMiddleware_ExcludeContentTypesWildcard(t *testing.T) {
+	config := DefaultConfig()
+	config.ExcludeContentTypes = []string{"image/*"}
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Write([]byte("data"))
+	}))
+
+	for _, contentType := range []string{"image/webp", "application/json"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?type="+contentType, nil))
+	}
+	if size := middleware.cache.Size(); size != 1 {
+		t.Errorf("Expected only the JSON response to be cached, got %d entries", size)
+	}
+}
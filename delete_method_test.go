@@ -84,3 +84,83 @@ func TestDeleteMethodWithHEADRequests(t *testing.T) {
 		t.Logf("SUCCESS: Delete method successfully removed HEAD-cached entry")
 	}
 }
+
+// TestDeleteRemovesHeaderVariants verifies that Delete purges entries keyed
+// on request headers such as Accept-Encoding using only the URL
+func TestDeleteRemovesHeaderVariants(t *testing.T) {
+	middleware := NewDefault()
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"encoding": "` + r.Header.Get("Accept-Encoding") + `"}`))
+	}))
+
+	variants := []map[string]string{
+		{"Accept-Encoding": "gzip"},
+		{"Accept-Encoding": "br", "Accept": "application/json"},
+		{},
+	}
+	request := func(headers map[string]string) {
+		req := httptest.NewRequest("GET", "/api/data?id=1", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for _, headers := range variants {
+		request(headers)
+	}
+	// An unrelated resource that must survive the purge
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/other", nil))
+
+	if itemCount, _, _ := middleware.Stats(); itemCount != 4 {
+		t.Fatalf("Expected 4 cached entries, got %d", itemCount)
+	}
+
+	middleware.Delete("http://example.com/api/data?id=1")
+
+	if itemCount, _, _ := middleware.Stats(); itemCount != 1 {
+		t.Errorf("Expected all variants to be purged leaving 1 entry, got %d", itemCount)
+	}
+
+	before := calls
+	for _, headers := range variants {
+		request(headers)
+	}
+	if calls-before != len(variants) {
+		t.Errorf("Expected every variant to miss after Delete, got %d handler calls", calls-before)
+	}
+}
+
+// evictOnStoreLogger removes every entry from its cache as soon as it is
+// stored, standing in for a concurrent eviction that lands before the
+// middleware indexes the key
+type evictOnStoreLogger struct {
+	recordingLogger
+	cache *TTLCache
+}
+
+func (l *evictOnStoreLogger) OnStore(key string, size int) { l.cache.Delete(key) }
+
+// TestMiddleware_VariantIndexEvictedBeforeIndexing verifies that a key
+// evicted before it was indexed doesn't stay in the variant index
+func TestMiddleware_VariantIndexEvictedBeforeIndexing(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+	middleware.cache.config.Logger = &evictOnStoreLogger{cache: middleware.cache}
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/1", nil))
+
+	middleware.variantsMu.Lock()
+	defer middleware.variantsMu.Unlock()
+	if len(middleware.variantOf) != 0 || len(middleware.variants) != 0 {
+		t.Errorf("Expected the evicted key not to be indexed, got %v", middleware.variantOf)
+	}
+}
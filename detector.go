@@ -26,15 +26,44 @@ func (d *ContentDetector) IsHTMLContent(response []byte, headers http.Header) bo
 	return d.isHTMLContentType(contentType)
 }
 
-// ShouldCache determines if a response should be cached based on content analysis
+// ShouldCache determines if a response should be cached based on content
+// analysis. A nil response means the body isn't known, as for HEAD requests
+// and WouldCacheContentType, so MinBodyBytes isn't applied to it.
 func (d *ContentDetector) ShouldCache(response []byte, headers http.Header, statusCode int) bool {
 	// Check if status code is cacheable (typically 200, 301, 304, etc.)
 	if !d.isCacheableStatusCode(statusCode) {
 		return false
 	}
 
-	// Check content type exclusions
+	// Don't re-cache responses already served from an upstream cache
+	if isMarkedCacheHit(headers, d.config.CacheHitMarkerHeader) {
+		return false
+	}
+
+	// A zero freshness lifetime means the response must not be reused. A
+	// handler's X-Cache-TTL takes precedence over Cache-Control.
+	handlerTTL, hasHandlerTTL := headerTTL(headers)
+	if hasHandlerTTL {
+		if handlerTTL == 0 {
+			return false
+		}
+	} else if ttl, ok := remainingSharedMaxAge(headers); ok && ttl == 0 {
+		return false
+	}
+	if d.config.RequireExplicitCacheControl && !parseCacheControl(headers).explicitlyCacheable() && !hasHandlerTTL {
+		return false
+	}
+
+	// Cookies are per-user state
+	if d.config.NoCacheOnSetCookie && headers.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	// Check the content type allowlist, then exclusions on top of it
 	contentType := headers.Get("Content-Type")
+	if !d.config.IsContentTypeIncluded(contentType) {
+		return false
+	}
 	if d.config.IsContentTypeExcluded(contentType) {
 		return false // Excluded means don't cache
 	}
@@ -44,8 +73,12 @@ func (d *ContentDetector) ShouldCache(response []byte, headers http.Header, stat
 		return false // Don't cache HTML
 	}
 
-	// Check response size limits (avoid caching very large responses)
-	if len(response) > int(d.config.MaxMemoryMB)*1024*1024/10 { // Max 10% of total cache for single entry
+	// Check response size limits (avoid caching very large or tiny responses).
+	// Statuses that carry no body are never too small.
+	if int64(len(response)) > d.config.MaxCacheableSize() {
+		return false
+	}
+	if response != nil && bodyAllowedForStatus(statusCode) && len(response) < d.config.MinBodyBytes {
 		return false
 	}
 
@@ -91,6 +124,15 @@ func (d *ContentDetector) isHTMLContentType(contentType string) bool {
 	return false
 }
 
+// isMarkedCacheHit checks if the response carries a marker header indicating
+// it was already served from a cache
+func isMarkedCacheHit(headers http.Header, marker string) bool {
+	if marker == "" {
+		return false
+	}
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(headers.Get(marker))), "HIT")
+}
+
 // isCacheableStatusCode checks if the HTTP status code indicates a cacheable response
 func (d *ContentDetector) isCacheableStatusCode(statusCode int) bool {
 	// Common cacheable status codes
@@ -117,9 +159,15 @@ func (d *ContentDetector) isCacheableStatusCode(statusCode int) bool {
 
 // AnalyzeResponse performs comprehensive analysis of a response for caching decisions
 func (d *ContentDetector) AnalyzeResponse(response []byte, headers http.Header, statusCode int) *ResponseAnalysis {
+	return d.AnalyzeResponseForPath("", response, headers, statusCode)
+}
+
+// AnalyzeResponseForPath analyzes a response like AnalyzeResponse, applying any
+// PathTTLs override for the request path in place of the content-type TTL
+func (d *ContentDetector) AnalyzeResponseForPath(requestPath string, response []byte, headers http.Header, statusCode int) *ResponseAnalysis {
 	analysis := &ResponseAnalysis{
 		StatusCode:  statusCode,
-		ContentType: d.GetContentType(headers),
+		ContentType: d.DetectContentTypeFromResponse(response, headers),
 		Size:        len(response),
 		IsHTML:      d.IsHTMLContent(response, headers),
 		IsCacheable: false,
@@ -128,9 +176,27 @@ func (d *ContentDetector) AnalyzeResponse(response []byte, headers http.Header,
 	// Determine cacheability
 	analysis.IsCacheable = d.ShouldCache(response, headers, statusCode)
 
-	// Set TTL based on content type
+	// Set TTL based on path or content type, overridden by Cache-Control
+	// s-maxage or max-age less any upstream Age, or ImmutableTTL for
+	// immutable responses, letting an explicit cache bucket and then a
+	// handler's X-Cache-TTL win
 	if analysis.IsCacheable {
 		analysis.RecommendedTTL = d.config.GetTTLForContentType(analysis.ContentType)
+		if ttl, matched := d.config.GetTTLForPath(requestPath); matched {
+			analysis.RecommendedTTL = ttl
+		}
+		if ttl, ok := remainingSharedMaxAge(headers); ok {
+			analysis.RecommendedTTL = ttl
+		}
+		if d.config.ImmutableTTL > 0 && parseCacheControl(headers).immutable() {
+			analysis.RecommendedTTL = d.config.ImmutableTTL
+		}
+		if ttl, exists := d.config.GetTTLForBucket(headers.Get(CacheBucketHeader)); exists {
+			analysis.RecommendedTTL = ttl
+		}
+		if ttl, ok := headerTTL(headers); ok {
+			analysis.RecommendedTTL = ttl
+		}
 	}
 
 	return analysis
@@ -146,6 +212,25 @@ type ResponseAnalysis struct {
 	RecommendedTTL time.Duration `json:"recommended_ttl"`
 }
 
+// sniffSampleSize is how much of a body is inspected to detect its type
+const sniffSampleSize = 512
+
+// DetectContentTypeFromResponse returns the declared Content-Type of a
+// response, detecting it from the body only when none is declared. A body
+// sent with a Content-Encoding is decoded transiently first, since sniffing
+// compressed bytes would only find the compression format.
+func (d *ContentDetector) DetectContentTypeFromResponse(data []byte, headers http.Header) string {
+	if headers.Get("Content-Type") != "" {
+		return d.GetContentType(headers)
+	}
+
+	sample, err := decodeContentEncoding(headers.Get("Content-Encoding"), data, sniffSampleSize)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return d.DetectContentTypeFromBytes(sample)
+}
+
 // DetectContentTypeFromBytes attempts to detect content type from response bytes
 // This is a fallback when Content-Type header is missing or unreliable
 func (d *ContentDetector) DetectContentTypeFromBytes(data []byte) string {
@@ -211,6 +296,11 @@ func (d *ContentDetector) detectBinaryFormats(data []byte) string {
 		return "application/pdf"
 	}
 
+	// gRPC-Web frames carrying protobuf messages
+	if looksLikeGRPCWeb(data) {
+		return "application/grpc-web+proto"
+	}
+
 	return ""
 }
 
@@ -0,0 +1,86 @@
+package selectcache
+
+import (
+	"sort"
+	"time"
+)
+
+// EntryInfo describes a cached entry without its body, for inspection and
+// admin dashboards. Fields a cache does not track are left zero.
+type EntryInfo struct {
+	Key         string    `json:"key"`
+	Path        string    `json:"path,omitempty"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	StoreTime   time.Time `json:"store_time"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	AccessTime  time.Time `json:"access_time"`
+	AccessCount uint64    `json:"access_count"`
+}
+
+// Entries returns a snapshot of metadata for every unexpired entry, sorted by
+// key. Body data is never included.
+func (c *TTLCache) Entries() []EntryInfo {
+	var entries []EntryInfo
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.entries {
+			if entry.IsExpired() {
+				continue
+			}
+			entries = append(entries, EntryInfo{
+				Key:         key,
+				ContentType: entry.ContentType,
+				Size:        entry.Size,
+				StoreTime:   entry.StoreTime,
+				ExpiresAt:   entry.ExpiresAt,
+				AccessTime:  entry.AccessTime,
+				AccessCount: entry.AccessCount,
+			})
+		}
+		shard.mu.RUnlock()
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// TopEntries returns metadata for the n most accessed unexpired entries, most
+// accessed first; entries with equal counts are ordered by key. It returns
+// every entry when there are fewer than n.
+func (c *TTLCache) TopEntries(n int) []EntryInfo {
+	return topEntries(c.Entries(), n)
+}
+
+// topEntries sorts entries by access count and keeps the first n
+func topEntries(entries []EntryInfo, n int) []EntryInfo {
+	if n <= 0 {
+		return nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].AccessCount > entries[j].AccessCount })
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// ListEntries returns metadata for every cached response, sorted by key, with
+// each key mapped back to the path and query it was stored for. Body data is
+// never included.
+func (m *Middleware) ListEntries() []EntryInfo {
+	entries := m.cache.Entries()
+
+	m.variantsMu.Lock()
+	for i := range entries {
+		_, entries[i].Path = splitResourceHost(m.variantOf[entries[i].Key])
+	}
+	m.variantsMu.Unlock()
+
+	return entries
+}
+
+// TopEntries returns metadata for the n most accessed cached responses, most
+// accessed first, with each key mapped back to its path and query
+func (m *Middleware) TopEntries(n int) []EntryInfo {
+	return topEntries(m.ListEntries(), n)
+}
@@ -0,0 +1,132 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_Entries(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	cache.Set("b", []byte("second"), http.Header{"Content-Type": []string{"text/plain"}}, time.Minute)
+	cache.Set("a", []byte(`{"first":true}`), http.Header{"Content-Type": []string{"application/json"}}, time.Minute)
+	cache.Set("expired", []byte("gone"), http.Header{}, time.Millisecond)
+	cache.Get("a")
+	cache.Get("a")
+	time.Sleep(5 * time.Millisecond)
+
+	entries := cache.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 unexpired entries, got %d", len(entries))
+	}
+	if entries[0].Key != "a" || entries[1].Key != "b" {
+		t.Errorf("Expected entries sorted by key, got %q, %q", entries[0].Key, entries[1].Key)
+	}
+
+	a := entries[0]
+	if a.ContentType != "application/json" || a.AccessCount != 2 || a.Size == 0 {
+		t.Errorf("Unexpected metadata: %+v", a)
+	}
+	if a.StoreTime.IsZero() || !a.ExpiresAt.After(a.StoreTime) || a.AccessTime.Before(a.StoreTime) {
+		t.Errorf("Unexpected timing metadata: %+v", a)
+	}
+
+	// The snapshot is a copy
+	entries[0].Key = "mutated"
+	if cache.Entries()[0].Key != "a" {
+		t.Error("Expected Entries to return a copy")
+	}
+}
+
+func TestMiddleware_ListEntries(t *testing.T) {
+	middleware := NewDefault()
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	for _, target := range []string{"/api/users", "/api/items?page=2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	entries := middleware.ListEntries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	paths := map[string]EntryInfo{}
+	for _, entry := range entries {
+		paths[entry.Path] = entry
+	}
+	for _, path := range []string{"/api/users", "/api/items?page=2"} {
+		entry, found := paths[path]
+		if !found {
+			t.Errorf("Expected an entry for %s, got %+v", path, entries)
+			continue
+		}
+		if entry.ContentType != "application/json" || entry.Size != len(`{"ok":true}`)+len("Content-Typeapplication/json") {
+			t.Errorf("Unexpected metadata for %s: %+v", path, entry)
+		}
+		if entry.StoreTime.IsZero() || !entry.ExpiresAt.After(entry.StoreTime) {
+			t.Errorf("Unexpected timing metadata for %s: %+v", path, entry)
+		}
+	}
+}
+
+func TestTTLCache_TopEntries(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	hits := map[string]int{"cold": 0, "warm": 2, "hot": 5, "also-warm": 2}
+	for key, n := range hits {
+		cache.Set(key, []byte(key), http.Header{}, time.Minute)
+		for i := 0; i < n; i++ {
+			cache.Get(key)
+		}
+	}
+
+	top := cache.TopEntries(3)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(top))
+	}
+	expected := []string{"hot", "also-warm", "warm"}
+	for i, key := range expected {
+		if top[i].Key != key {
+			t.Errorf("Position %d: expected %q, got %q", i, key, top[i].Key)
+		}
+	}
+	if top[0].AccessCount != 5 {
+		t.Errorf("Expected hottest entry to report 5 accesses, got %d", top[0].AccessCount)
+	}
+
+	if all := cache.TopEntries(10); len(all) != 4 {
+		t.Errorf("Expected every entry when n exceeds the count, got %d", len(all))
+	}
+	if none := cache.TopEntries(0); len(none) != 0 {
+		t.Errorf("Expected no entries for n=0, got %d", len(none))
+	}
+}
+
+func TestMiddleware_TopEntries(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	for _, target := range []string{"/a", "/b", "/b", "/b", "/c", "/c"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	top := middleware.TopEntries(1)
+	if len(top) != 1 || top[0].Path != "/b" {
+		t.Fatalf("Expected /b to be the most accessed entry, got %+v", top)
+	}
+	if top[0].AccessCount != 2 {
+		t.Errorf("Expected 2 cache hits on /b, got %d", top[0].AccessCount)
+	}
+}
@@ -0,0 +1,55 @@
+package selectcache
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// generateETag computes a strong ETag from the SHA-256 of the body, so
+// identical bodies get the same tag across processes and restarts
+func generateETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag using
+// the weak comparison required for conditional GET and HEAD requests
+func etagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers a conditional request with 304 Not Modified when
+// If-None-Match matches the cached ETag. Headers must already be populated.
+func (m *Middleware) writeNotModified(w http.ResponseWriter, r *http.Request, cached *CachedResponse) bool {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || cached.StatusCode != http.StatusOK {
+		return false
+	}
+	if !etagMatches(ifNoneMatch, cached.Headers.Get("ETag")) {
+		return false
+	}
+
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// weakenETag marks a strong ETag weak, for bodies whose bytes differ from
+// those the origin's ETag describes
+func weakenETag(headers http.Header) {
+	if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		headers.Set("ETag", "W/"+etag)
+	}
+}
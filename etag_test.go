@@ -0,0 +1,74 @@
+package selectcache
+
+import (
+	"net/http"
+	"testing"
+)
+
+// etagHeaders returns the JSON response headers for the ETag tests, with an
+// upstream ETag unless etag is empty
+func etagHeaders(etag string) http.Header {
+	headers := http.Header{"Content-Type": {"application/json"}}
+	if etag != "" {
+		headers.Set("ETag", etag)
+	}
+	return headers
+}
+
+func TestMiddleware_GenerateETag(t *testing.T) {
+	config := DefaultConfig()
+	config.GenerateETag = true
+	handler := newHeaderTestHandler(t, config, etagHeaders(""), `{"id":1}`)
+
+	serveWithHeader(handler, "/api/item", "If-None-Match", "") // populate the cache
+	hit := serveWithHeader(handler, "/api/item", "If-None-Match", "")
+	etag := hit.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected cached response to carry a generated ETag")
+	}
+	if etag != generateETag([]byte(`{"id":1}`)) {
+		t.Errorf("Generated ETag %q is not derived from the body", etag)
+	}
+
+	// A fresh middleware (e.g. after a restart) generates the same tag
+	other := newHeaderTestHandler(t, config, etagHeaders(""), `{"id":1}`)
+	serveWithHeader(other, "/api/item", "If-None-Match", "")
+	if got := serveWithHeader(other, "/api/item", "If-None-Match", "").Header().Get("ETag"); got != etag {
+		t.Errorf("ETag not stable across instances: %q vs %q", got, etag)
+	}
+
+	notModified := serveWithHeader(handler, "/api/item", "If-None-Match", etag)
+	if notModified.Code != http.StatusNotModified {
+		t.Fatalf("Expected 304 for matching If-None-Match, got %d", notModified.Code)
+	}
+	if notModified.Body.Len() != 0 {
+		t.Errorf("Expected empty body on 304, got %q", notModified.Body.String())
+	}
+
+	if rec := serveWithHeader(handler, "/api/item", "If-None-Match", `"something-else"`); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for non-matching If-None-Match, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_GenerateETagKeepsUpstreamTag(t *testing.T) {
+	config := DefaultConfig()
+	config.GenerateETag = true
+	handler := newHeaderTestHandler(t, config, etagHeaders(`"v1"`), `{"id":1}`)
+
+	serveWithHeader(handler, "/api/item", "If-None-Match", "")
+	if got := serveWithHeader(handler, "/api/item", "If-None-Match", "").Header().Get("ETag"); got != `"v1"` {
+		t.Errorf("Expected upstream ETag to be preserved, got %q", got)
+	}
+	if rec := serveWithHeader(handler, "/api/item", "If-None-Match", `W/"v1"`); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected weak comparison to match, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_GenerateETagDisabledByDefault(t *testing.T) {
+	handler := newHeaderTestHandler(t, DefaultConfig(), etagHeaders(""), `{"id":1}`)
+
+	serveWithHeader(handler, "/api/item", "If-None-Match", "")
+	if got := serveWithHeader(handler, "/api/item", "If-None-Match", "").Header().Get("ETag"); got != "" {
+		t.Errorf("Expected no ETag without GenerateETag, got %q", got)
+	}
+}
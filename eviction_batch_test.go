@@ -0,0 +1,137 @@
+package selectcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newEvictionBatchCache returns a cache holding count entries of about 1KB
+// each, with a memory cap of about that much
+func newEvictionBatchCache(tb testing.TB, batch, count int) *TTLCache {
+	tb.Helper()
+
+	config := DefaultCacheConfig()
+	config.MaxMemoryMB = int64((count + 999) / 1000)
+	config.MaxEntries = count + 1
+	config.MaxEvictionBatch = batch
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+
+	data := make([]byte, 1000)
+	for i := 0; i < count; i++ {
+		if err := cache.Set(fmt.Sprintf("small-%d", i), data, http.Header{}, time.Hour); err != nil {
+			tb.Fatalf("Failed to prefill: %v", err)
+		}
+	}
+	return cache
+}
+
+func TestTTLCache_MaxEvictionBatch(t *testing.T) {
+	cache := newEvictionBatchCache(t, 10, 1000)
+	defer cache.Close()
+
+	var evicted int
+	cache.config.OnEvict = func(string, *CacheEntry) { evicted++ }
+	before := cache.Size()
+
+	// Room for this entry takes far more than 10 evictions
+	big := make([]byte, 200*1000)
+	err := cache.Set("big", big, http.Header{}, time.Hour)
+	if !errors.Is(err, ErrEvictionBatchExceeded) {
+		t.Fatalf("Expected ErrEvictionBatchExceeded, got %v", err)
+	}
+	if _, found := cache.Get("big"); found {
+		t.Error("Expected the rejected entry not to be stored")
+	}
+	if removed := before - cache.Size(); removed != 10 || evicted != 10 {
+		t.Errorf("Expected exactly 10 entries evicted, got %d (%d reported)", removed, evicted)
+	}
+	if cache.metrics.GetStats().Errors["eviction_batch_exceeded"] != 1 {
+		t.Error("Expected eviction_batch_exceeded to be recorded")
+	}
+
+	// Entries needing no more than the batch are stored as usual
+	if err := cache.Set("small-new", make([]byte, 1000), http.Header{}, time.Hour); err != nil {
+		t.Errorf("Expected a small entry to be stored, got %v", err)
+	}
+
+	// Repeated attempts keep making room until the entry fits
+	for i := 0; i < 100 && errors.Is(err, ErrEvictionBatchExceeded); i++ {
+		err = cache.Set("big", big, http.Header{}, time.Hour)
+	}
+	if err != nil {
+		t.Errorf("Expected the entry to fit after repeated stores, got %v", err)
+	}
+}
+
+func TestTTLCache_MaxEvictionBatchSetMulti(t *testing.T) {
+	cache := newEvictionBatchCache(t, 5, 1000)
+	defer cache.Close()
+
+	items := make(map[string]SetItem)
+	for i := 0; i < 20; i++ {
+		items[fmt.Sprintf("batch-%d", i)] = SetItem{Data: make([]byte, 1000), TTL: time.Hour}
+	}
+	if err := cache.SetMulti(items); !errors.Is(err, ErrEvictionBatchExceeded) {
+		t.Fatalf("Expected ErrEvictionBatchExceeded, got %v", err)
+	}
+	if _, found := cache.Get("batch-0"); found {
+		t.Error("Expected nothing from the rejected batch to be stored")
+	}
+}
+
+// TestTTLCache_MaxEvictionBatchReplacedEntry verifies that an entry removed
+// to make way for a replacement that then doesn't fit is reported as gone
+func TestTTLCache_MaxEvictionBatchReplacedEntry(t *testing.T) {
+	cache := newEvictionBatchCache(t, 10, 1000)
+	defer cache.Close()
+
+	var evicted []string
+	cache.config.OnEvict = func(key string, _ *CacheEntry) { evicted = append(evicted, key) }
+	deletions := cache.metrics.GetStats().Deletions
+
+	err := cache.Set("small-500", make([]byte, 200*1000), http.Header{}, time.Hour)
+	if !errors.Is(err, ErrEvictionBatchExceeded) {
+		t.Fatalf("Expected ErrEvictionBatchExceeded, got %v", err)
+	}
+	if cache.Has("small-500") {
+		t.Fatal("Expected neither the old nor the new entry to be cached")
+	}
+	found := false
+	for _, key := range evicted {
+		found = found || key == "small-500"
+	}
+	if !found {
+		t.Errorf("Expected OnEvict for the replaced entry, got %v", evicted)
+	}
+	if got := cache.metrics.GetStats().Deletions; got != deletions+1 {
+		t.Errorf("Expected the replaced entry to count as a deletion, got %d after %d", got, deletions)
+	}
+	if memory := cache.metrics.GetStats().TotalMemoryBytes; memory != uint64(cache.totalMemoryBytes.Load()) {
+		t.Errorf("Expected memory metrics of %d, got %d", cache.totalMemoryBytes.Load(), memory)
+	}
+}
+
+// BenchmarkTTLCache_LargeStoreEviction measures making room for an 8MB entry
+// in a full cache of 16000 1KB entries, with and without an eviction batch
+// cap. Each iteration refills the cache, which is excluded from the timing,
+// as is copying the entry itself.
+func BenchmarkTTLCache_LargeStoreEviction(b *testing.B) {
+	for _, batch := range []int{0, 64} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				cache := newEvictionBatchCache(b, batch, 16000)
+				b.StartTimer()
+
+				cache.checkMemoryLimits(8*1000*1000, 1)
+
+				b.StopTimer()
+				cache.Close()
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package selectcache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// evictionHeap is a min-heap of cache entries ordered so that the next entry
+// to evict is at the root: oldest access time for LRU, or lowest access count
+// (ties broken by oldest access time) for LFU. Entries track their own heap
+// index so access updates can reposition them in O(log n).
+type evictionHeap struct {
+	entries []*CacheEntry
+	lfu     bool
+}
+
+// newEvictionHeap creates an empty heap for the given eviction policy
+func newEvictionHeap(policy string) *evictionHeap {
+	return &evictionHeap{lfu: policy == EvictionPolicyLFU}
+}
+
+// Len implements heap.Interface
+func (h *evictionHeap) Len() int {
+	return len(h.entries)
+}
+
+// Less implements heap.Interface
+func (h *evictionHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	return h.ranksBefore(a.AccessCount, a.AccessTime, b.AccessCount, b.AccessTime)
+}
+
+// ranksBefore reports whether an entry with the first access count and time
+// should be evicted before one with the second
+func (h *evictionHeap) ranksBefore(countA uint64, accessA time.Time, countB uint64, accessB time.Time) bool {
+	if h.lfu && countA != countB {
+		return countA < countB
+	}
+	return accessA.Before(accessB)
+}
+
+// Swap implements heap.Interface
+func (h *evictionHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].heapIndex = i
+	h.entries[j].heapIndex = j
+}
+
+// Push implements heap.Interface
+func (h *evictionHeap) Push(x any) {
+	entry := x.(*CacheEntry)
+	entry.heapIndex = len(h.entries)
+	h.entries = append(h.entries, entry)
+}
+
+// Pop implements heap.Interface
+func (h *evictionHeap) Pop() any {
+	n := len(h.entries)
+	entry := h.entries[n-1]
+	h.entries[n-1] = nil
+	h.entries = h.entries[:n-1]
+	entry.heapIndex = -1
+	return entry
+}
+
+// add inserts an entry into the heap
+func (h *evictionHeap) add(entry *CacheEntry) {
+	heap.Push(h, entry)
+}
+
+// remove deletes an entry from the heap
+func (h *evictionHeap) remove(entry *CacheEntry) {
+	if entry.heapIndex >= 0 && entry.heapIndex < len(h.entries) && h.entries[entry.heapIndex] == entry {
+		heap.Remove(h, entry.heapIndex)
+	}
+}
+
+// update repositions an entry after its access time or count changed
+func (h *evictionHeap) update(entry *CacheEntry) {
+	if entry.heapIndex >= 0 && entry.heapIndex < len(h.entries) && h.entries[entry.heapIndex] == entry {
+		heap.Fix(h, entry.heapIndex)
+	}
+}
+
+// peek returns the next entry to evict without removing it
+func (h *evictionHeap) peek() *CacheEntry {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	return h.entries[0]
+}
+
+// reset removes all entries from the heap
+func (h *evictionHeap) reset() {
+	h.entries = nil
+}
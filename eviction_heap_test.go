@@ -0,0 +1,101 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestEvictionHeapEvictsLeastRecentlyUsed verifies the heap tracks access
+// order through gets, overwrites, and deletes
+func TestEvictionHeapEvictsLeastRecentlyUsed(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntries = 3
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Set(key, []byte(key), headers, time.Hour)
+		time.Sleep(time.Millisecond)
+	}
+
+	// Touch "a" so "b" becomes the oldest, overwrite "c", then add "d"
+	cache.Get("a")
+	cache.Set("c", []byte("c2"), headers, time.Hour)
+	cache.Set("d", []byte("d"), headers, time.Hour)
+
+	if _, found := cache.Get("b"); found {
+		t.Errorf("Expected least recently used entry b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("Expected entry %s to survive eviction", key)
+		}
+	}
+
+	cache.Delete("a")
+	heapSize := 0
+	for _, shard := range cache.shards {
+		heapSize += shard.eviction.Len()
+	}
+	if heapSize != cache.Size() {
+		t.Errorf("Heap size %d out of sync with cache size %d", heapSize, cache.Size())
+	}
+}
+
+const benchmarkEvictionEntries = 100000
+
+// newFullBenchmarkCache creates a cache filled to its entry limit
+func newFullBenchmarkCache(b *testing.B) *TTLCache {
+	config := DefaultCacheConfig()
+	config.MaxEntries = benchmarkEvictionEntries
+	cache := NewTTLCache(config, nil)
+
+	headers := make(http.Header)
+	for i := 0; i < benchmarkEvictionEntries; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte("v"), headers, time.Hour)
+	}
+	return cache
+}
+
+// BenchmarkEviction_Heap measures a Set that triggers one eviction on a full
+// cache using the eviction heap
+func BenchmarkEviction_Heap(b *testing.B) {
+	cache := newFullBenchmarkCache(b)
+	defer cache.Close()
+	headers := make(http.Header)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(fmt.Sprintf("new-%d", i), []byte("v"), headers, time.Hour)
+	}
+}
+
+// BenchmarkEviction_FullSort measures the previous approach of building and
+// sorting a slice of every entry to evict one
+func BenchmarkEviction_FullSort(b *testing.B) {
+	entries := make(map[string]*CacheEntry, benchmarkEvictionEntries)
+	for i := 0; i < benchmarkEvictionEntries; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		entries[key] = &CacheEntry{key: key, AccessTime: time.Now()}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sorted := make([]*CacheEntry, 0, len(entries))
+		for _, entry := range entries {
+			sorted = append(sorted, entry)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].AccessTime.Before(sorted[j].AccessTime)
+		})
+		delete(entries, sorted[0].key)
+
+		// Keep the map full for the next iteration
+		key := fmt.Sprintf("new-%d", i)
+		entries[key] = &CacheEntry{key: key, AccessTime: time.Now()}
+	}
+}
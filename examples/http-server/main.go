@@ -220,26 +220,8 @@ func main() {
 			time.Now().Format("15:04:05"), itemCount, hitCount, missCount, hitRatio)
 	}))
 
-	// Enhanced cache clear endpoint
-	http.HandleFunc("/cache/clear", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "POST" {
-			itemCount, _, _ := cache.Stats()
-			cache.Clear()
-
-			response := map[string]interface{}{
-				"message":       "Cache cleared successfully",
-				"items_cleared": itemCount,
-				"timestamp":     time.Now(),
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(response)
-			fmt.Printf("[%s] Cache cleared - removed %d items\n", time.Now().Format("15:04:05"), itemCount)
-		} else {
-			w.Header().Set("Allow", "POST")
-			http.Error(w, "Method not allowed. Use POST to clear cache.", http.StatusMethodNotAllowed)
-		}
-	})
+	// Cache purge endpoint: POST clears everything, ?url=... purges one URL
+	http.Handle("/cache/clear", cache.PurgeHandler())
 
 	fmt.Println("🚀 Advanced HTTP Server with Caching started on :8080")
 	fmt.Println("")
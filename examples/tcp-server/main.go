@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net"
@@ -277,54 +276,9 @@ document.addEventListener('DOMContentLoaded', function() {
 		fmt.Printf("[%s] Generated PNG image (cached 24h)\n", time.Now().Format("15:04:05"))
 	})
 
-	// Cache metrics endpoint - shows transport layer cache statistics
-	mux.HandleFunc("/cache/metrics", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		// Get metrics from the caching listener
-		// Note: In a real implementation, you'd expose these metrics from the CachingListener
-		metrics := map[string]interface{}{
-			"transport_cache": map[string]interface{}{
-				"description":        "Transport-layer cache operating at TCP connection level",
-				"max_memory_mb":      256,
-				"max_entries":        5000,
-				"default_ttl":        "20 minutes",
-				"cleanup_interval":   "5 minutes",
-				"buffer_size":        8192,
-				"connection_timeout": "30 seconds",
-				"metrics_enabled":    true,
-			},
-			"content_type_ttls": map[string]string{
-				"application/json": "15 minutes",
-				"text/css":         "1 hour",
-				"text/javascript":  "1 hour",
-				"image/png":        "24 hours",
-				"image/jpeg":       "24 hours",
-			},
-			"excluded_types": []string{
-				"text/html",
-				"application/xhtml+xml",
-				"text/event-stream",
-				"application/octet-stream",
-			},
-			"cache_behavior": map[string]interface{}{
-				"intercepts_at":     "TCP connection level",
-				"operates_before":   "HTTP middleware",
-				"content_detection": "automatic",
-				"memory_managed":    true,
-			},
-			"timestamp": time.Now(),
-		}
-
-		// In a real implementation, you'd get actual cache statistics here
-		// For demo purposes, we'll show the configuration
-		fmt.Printf("[%s] Generated cache metrics\n", time.Now().Format("15:04:05"))
-
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(metrics); err != nil {
-			http.Error(w, "Failed to encode metrics", http.StatusInternalServerError)
-		}
-	})
+	// Cache metrics endpoint - shows live transport layer cache statistics
+	// Append ?reset=true to reset the counters after reading them
+	mux.Handle("/cache/metrics", cachingListener.MetricsHandler())
 
 	return mux
 }
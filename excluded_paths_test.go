@@ -0,0 +1,82 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMiddleware_ExcludedPaths verifies that prefix and regex exclusions are
+// never cached while other paths are
+func TestMiddleware_ExcludedPaths(t *testing.T) {
+	config := DefaultConfig()
+	config.ExcludedPaths = []string{"/admin/", `.*\.php$`}
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/admin/users", false},
+		{"/index.php", false},
+		{"/api/report.php", false},
+		{"/api/users", true},
+		{"/php/readme", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+			}
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if _, found := middleware.Cache().Get(middleware.createCacheKey(req)); found != tt.cached {
+				t.Errorf("Expected cached=%v for %s, got %v", tt.cached, tt.path, found)
+			}
+		})
+	}
+}
+
+// TestMiddleware_ExcludedPathsAllocations verifies that excluded paths reach
+// the handler without the middleware allocating
+func TestMiddleware_ExcludedPathsAllocations(t *testing.T) {
+	config := DefaultConfig()
+	config.ExcludedPaths = []string{"/admin/", `\.php$`}
+	middleware := New(config)
+	defer middleware.Close()
+	handler := middleware.Handler(noContentHandler)
+
+	w := &discardResponseWriter{header: make(http.Header)}
+	for _, path := range []string{"/admin/users", "/index.php"} {
+		req := httptest.NewRequest("GET", path, nil)
+		if allocs := testing.AllocsPerRun(100, func() { handler.ServeHTTP(w, req) }); allocs != 0 {
+			t.Errorf("Expected no allocations for %s, got %.1f per request", path, allocs)
+		}
+	}
+}
+
+// TestConfig_ValidateExcludedPaths verifies that invalid regular expressions
+// are reported by Validate and NewWithError and rejected by New
+func TestConfig_ValidateExcludedPaths(t *testing.T) {
+	config := DefaultConfig()
+	config.ExcludedPaths = []string{"/admin/", `(unclosed`}
+	if err := config.Validate(); err == nil {
+		t.Fatal("Expected Validate to reject an invalid pattern")
+	}
+	if m, err := NewWithError(config); err == nil || m != nil {
+		t.Fatalf("Expected NewWithError to return an error and no middleware, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected New to panic on an invalid pattern")
+		}
+	}()
+	New(config)
+}
@@ -0,0 +1,24 @@
+package selectcache
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compileExcludedPaths splits ExcludedPaths into path prefixes, for patterns
+// beginning with "/", and compiled regular expressions for the rest
+func compileExcludedPaths(patterns []string) (prefixes []string, regexps []*regexp.Regexp, err error) {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "/") {
+			prefixes = append(prefixes, pattern)
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid excluded path pattern %q: %w", pattern, err)
+		}
+		regexps = append(regexps, re)
+	}
+	return prefixes, regexps, nil
+}
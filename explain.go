@@ -0,0 +1,144 @@
+package selectcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExplainResult describes how the middleware would treat a request
+type ExplainResult struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Key    string `json:"key"`
+	// Bypassed is true when the request skips the cache entirely: an
+	// uncacheable method, a protocol upgrade, a bypassed path or BypassFunc
+	Bypassed bool `json:"bypassed"`
+	// Cached is true when a servable entry exists for the key
+	Cached       bool          `json:"cached"`
+	Stale        bool          `json:"stale,omitempty"`
+	RemainingTTL time.Duration `json:"remaining_ttl"`
+	// ResponseCacheable and ResponseTTL are the decision the middleware would
+	// make for the hypothetical response described by the query
+	ResponseCacheable bool          `json:"response_cacheable"`
+	ResponseTTL       time.Duration `json:"response_ttl,omitempty"`
+}
+
+// ExplainHandler returns an http.Handler that explains how a request would
+// be cached, without touching the cache or its statistics. It accepts GET
+// requests with these query parameters:
+//
+//   - url: the request URL to explain (required)
+//   - method: the request method (default GET)
+//   - header: a request header as "Name: value"; may be repeated
+//   - status, content_type, cache_control: the hypothetical response to judge
+//     (default 200, application/json and none). Its body is assumed to be
+//     MinBodyBytes long.
+//
+// The result is returned as JSON.
+func (m *Middleware) ExplainHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		req, err := explainRequest(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response, err := explainResponse(query, req.Method, m.minBodyBytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result := m.explain(req, response)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// explain reports how the middleware would treat req and a response like the
+// recorded one. It only reads the cache.
+func (m *Middleware) explain(req *http.Request, response *ResponseRecorder) ExplainResult {
+	result := ExplainResult{
+		URL:    req.URL.String(),
+		Method: req.Method,
+		Key:    m.createCacheKey(req),
+		Bypassed: !m.isCacheableMethod(req.Method) || req.Header.Get("Upgrade") != "" ||
+			m.isBypassedPath(req.URL.Path) || (m.bypass != nil && m.bypass(req)),
+	}
+
+	if entry, found := m.cache.peek(result.Key); found && !m.exceedsMaxServeAge(entry.StoreTime) {
+		result.Cached = true
+		result.Stale = entry.IsStale()
+		result.RemainingTTL = entry.RemainingTTL()
+	}
+
+	if !result.Bypassed && m.shouldCache(response) && !m.isPrivateResponse(req, response.Headers()) {
+		result.ResponseCacheable = true
+		result.ResponseTTL = m.ttlForResponse(req, response)
+	}
+	return result
+}
+
+// explainRequest builds the request to explain from ExplainHandler's query
+func explainRequest(query url.Values) (*http.Request, error) {
+	target := query.Get("url")
+	if target == "" {
+		return nil, errors.New("missing url parameter")
+	}
+	method := strings.ToUpper(query.Get("method"))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range query["header"] {
+		name, value, found := strings.Cut(header, ":")
+		if !found {
+			return nil, fmt.Errorf("header must be \"Name: value\", got %q", header)
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return req, nil
+}
+
+// explainResponse records the hypothetical response described by
+// ExplainHandler's query
+func explainResponse(query url.Values, method string, bodySize int) (*ResponseRecorder, error) {
+	status := http.StatusOK
+	if value := query.Get("status"); value != "" {
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 100 || code > 999 {
+			return nil, fmt.Errorf("invalid status %q", value)
+		}
+		status = code
+	}
+	contentType := query.Get("content_type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	recorder := NewResponseRecorder(&discardResponseWriter{header: make(http.Header)}, method)
+	recorder.Header().Set("Content-Type", contentType)
+	if cacheControl := query.Get("cache_control"); cacheControl != "" {
+		recorder.Header().Set("Cache-Control", cacheControl)
+	}
+	recorder.WriteHeader(status)
+	recorder.Write(make([]byte, bodySize))
+	return recorder, nil
+}
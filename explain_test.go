@@ -0,0 +1,110 @@
+package selectcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// explainQuery calls the explain handler with the given query parameters
+func explainQuery(t *testing.T, middleware *Middleware, params url.Values) ExplainResult {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	middleware.ExplainHandler().ServeHTTP(resp, httptest.NewRequest("GET", "/explain?"+params.Encode(), nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var result ExplainResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode explain result: %v", err)
+	}
+	return result
+}
+
+// TestMiddleware_ExplainHandler verifies that the explain endpoint reports
+// the key, the stored entry and the cacheability decision without changing
+// the cache or its statistics
+func TestMiddleware_ExplainHandler(t *testing.T) {
+	config := DefaultConfig()
+	config.BypassPathPrefixes = []string{"/admin/"}
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	cachedReq := httptest.NewRequest("GET", "http://example.com/api/items", nil)
+	cachedReq.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), cachedReq)
+	_, hitsBefore, missesBefore := middleware.Stats()
+
+	result := explainQuery(t, middleware, url.Values{
+		"url":    {"http://example.com/api/items"},
+		"header": {"Accept: application/json"},
+	})
+	if result.Key != middleware.createCacheKey(cachedReq) {
+		t.Errorf("Expected key %q, got %q", middleware.createCacheKey(cachedReq), result.Key)
+	}
+	if !result.Cached || result.RemainingTTL <= 14*time.Minute {
+		t.Errorf("Expected a cached entry with about 15m left, got cached=%v remaining %v", result.Cached, result.RemainingTTL)
+	}
+	if !result.ResponseCacheable || result.ResponseTTL != 15*time.Minute {
+		t.Errorf("Expected a JSON response to be cacheable for 15m, got %v for %v", result.ResponseCacheable, result.ResponseTTL)
+	}
+
+	// A different Accept header is a different variant
+	if result := explainQuery(t, middleware, url.Values{"url": {"http://example.com/api/items"}}); result.Cached {
+		t.Error("Expected no entry for a request without the Accept header")
+	}
+
+	result = explainQuery(t, middleware, url.Values{
+		"url":          {"http://example.com/page"},
+		"content_type": {"text/html"},
+	})
+	if result.ResponseCacheable {
+		t.Error("Expected an HTML response not to be cacheable")
+	}
+
+	result = explainQuery(t, middleware, url.Values{"url": {"http://example.com/admin/users"}})
+	if !result.Bypassed || result.ResponseCacheable {
+		t.Errorf("Expected a bypassed path to be reported as bypassed, got %+v", result)
+	}
+
+	items, hits, misses := middleware.Stats()
+	if items != 1 || hits != hitsBefore || misses != missesBefore {
+		t.Errorf("Expected explain not to change the cache, got %d items, %d hits, %d misses", items, hits, misses)
+	}
+}
+
+// TestMiddleware_ExplainHandlerErrors verifies that bad explain requests are
+// rejected
+func TestMiddleware_ExplainHandlerErrors(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"missing url", "GET", "/explain", http.StatusBadRequest},
+		{"bad header", "GET", "/explain?url=/x&header=nocolon", http.StatusBadRequest},
+		{"bad status", "GET", "/explain?url=/x&status=abc", http.StatusBadRequest},
+		{"POST", "POST", "/explain?url=/x", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			middleware.ExplainHandler().ServeHTTP(resp, httptest.NewRequest(tt.method, tt.target, nil))
+			if resp.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, resp.Code)
+			}
+		})
+	}
+}
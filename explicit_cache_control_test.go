@@ -0,0 +1,92 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// explicitCacheControlCases are responses with and without an explicit
+// positive caching directive
+var explicitCacheControlCases = []struct {
+	name      string
+	headers   http.Header
+	cacheable bool
+}{
+	{"no directives", http.Header{}, false},
+	{"max-age", http.Header{"Cache-Control": {"max-age=60"}}, true},
+	{"s-maxage", http.Header{"Cache-Control": {"s-maxage=60"}}, true},
+	{"public", http.Header{"Cache-Control": {"public"}}, true},
+	{"must-revalidate only", http.Header{"Cache-Control": {"must-revalidate"}}, false},
+	{"max-age=0", http.Header{"Cache-Control": {"max-age=0"}}, false},
+}
+
+// TestMiddleware_RequireExplicitCacheControl verifies that only responses
+// with an explicit positive directive are cached when it is required
+func TestMiddleware_RequireExplicitCacheControl(t *testing.T) {
+	for _, tt := range explicitCacheControlCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.RequireExplicitCacheControl = true
+			middleware := New(config)
+			defer middleware.Close()
+
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header()[k] = v
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"ok": true}`))
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+
+			if items, _, _ := middleware.Stats(); (items == 1) != tt.cacheable {
+				t.Errorf("Expected cached=%v, got %d items", tt.cacheable, items)
+			}
+		})
+	}
+}
+
+// TestMiddleware_RequireExplicitSurrogateControl verifies that a positive
+// Surrogate-Control max-age also counts as explicit
+func TestMiddleware_RequireExplicitSurrogateControl(t *testing.T) {
+	config := DefaultConfig()
+	config.RequireExplicitCacheControl = true
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(SurrogateControlHeader, "max-age=60")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+
+	if items, _, _ := middleware.Stats(); items != 1 {
+		t.Errorf("Expected the response to be cached, got %d items", items)
+	}
+}
+
+// TestContentDetector_RequireExplicitCacheControl verifies the transport
+// layer's decision with and without explicit directives, and that responses
+// without them stay cacheable by default
+func TestContentDetector_RequireExplicitCacheControl(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.RequireExplicitCacheControl = true
+	strict := NewContentDetector(config)
+	permissive := NewContentDetector(DefaultCacheConfig())
+
+	for _, tt := range explicitCacheControlCases {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := tt.headers.Clone()
+			headers.Set("Content-Type", "application/json")
+			if got := strict.ShouldCache([]byte(`{}`), headers, http.StatusOK); got != tt.cacheable {
+				t.Errorf("Expected ShouldCache %v, got %v", tt.cacheable, got)
+			}
+		})
+	}
+
+	if !permissive.ShouldCache([]byte(`{}`), http.Header{"Content-Type": {"application/json"}}, http.StatusOK) {
+		t.Error("Expected responses without directives to be cacheable by default")
+	}
+}
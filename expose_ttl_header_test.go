@@ -0,0 +1,77 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMiddleware_ExposeTTLHeader verifies that cache hits report the seconds
+// left until expiry, and only when ExposeTTLHeader is enabled
+func TestMiddleware_ExposeTTLHeader(t *testing.T) {
+	for _, expose := range []bool{true, false} {
+		clock := newFakeClock()
+		config := DefaultConfig()
+		config.DefaultTTL = 5 * time.Minute
+		config.ExposeTTLHeader = expose
+		middleware := New(config)
+		middleware.cache.clock = clock
+
+		handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"price": 42}`))
+		}))
+		get := func() *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest("GET", "/price", nil))
+			return resp
+		}
+
+		if ttl := get().Header().Get(CacheTTLHeader); ttl != "" {
+			t.Errorf("Expected no %s on a miss, got %q", CacheTTLHeader, ttl)
+		}
+
+		clock.Advance(2 * time.Minute)
+		resp := get()
+		want := ""
+		if expose {
+			want = "180"
+		}
+		if ttl := resp.Header().Get(CacheTTLHeader); ttl != want {
+			t.Errorf("ExposeTTLHeader=%v: expected %s %q on a hit, got %q", expose, CacheTTLHeader, want, ttl)
+		}
+		middleware.Close()
+	}
+}
+
+// TestBuildHTTPResponse_ExposeTTLHeader verifies that transport cache hits
+// carry X-Cache-TTL when enabled
+func TestBuildHTTPResponse_ExposeTTLHeader(t *testing.T) {
+	for _, expose := range []bool{true, false} {
+		conn := newMockConn()
+		config := DefaultCacheConfig()
+		config.ExposeTTLHeader = expose
+		cache := NewTTLCache(config, nil)
+		cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+
+		entry := &CacheEntry{
+			Data:      []byte("body"),
+			Headers:   http.Header{"Etag": {`"v1"`}},
+			StoreTime: time.Now(),
+			ExpiresAt: time.Now().Add(90*time.Second + 500*time.Millisecond),
+		}
+		for name, response := range map[string]string{
+			"full":         string(cc.buildHTTPResponse(entry)),
+			"not modified": string(cc.buildNotModifiedResponse(entry)),
+		} {
+			if got := strings.Contains(response, "\r\nX-Cache-TTL: 90\r\n"); got != expose {
+				t.Errorf("ExposeTTLHeader=%v: %s response has X-Cache-TTL: %v\n%s", expose, name, got, response)
+			}
+		}
+
+		cc.Close()
+		cache.Close()
+	}
+}
@@ -0,0 +1,94 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// noContentHandler answers without allocating, so measured allocations are
+// the middleware's own
+var noContentHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+// TestMiddleware_FastPathAllocations verifies that uncacheable methods and
+// bypassed paths reach the handler without the middleware allocating
+func TestMiddleware_FastPathAllocations(t *testing.T) {
+	config := DefaultConfig()
+	config.BypassPathPrefixes = []string{"/api/write/"}
+	middleware := New(config)
+	defer middleware.Close()
+	handler := middleware.Handler(noContentHandler)
+
+	w := &discardResponseWriter{header: make(http.Header)}
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"POST", httptest.NewRequest("POST", "/api/items", nil)},
+		{"bypassed path", httptest.NewRequest("GET", "/api/write/items", nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(100, func() { handler.ServeHTTP(w, tt.req) }); allocs != 0 {
+				t.Errorf("Expected no allocations, got %.1f per request", allocs)
+			}
+		})
+	}
+}
+
+// TestMiddleware_BypassPathPrefixes verifies that bypassed paths are never
+// cached while other paths still are
+func TestMiddleware_BypassPathPrefixes(t *testing.T) {
+	config := DefaultConfig()
+	config.BypassPathPrefixes = []string{"/live/"}
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+
+	for _, path := range []string{"/live/feed", "/live/feed", "/static/feed"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if items, _, misses := middleware.Stats(); items != 1 || misses != 1 {
+		t.Errorf("Expected only /static/feed to be looked up and cached, got %d items and %d misses", items, misses)
+	}
+}
+
+// benchmarkMiddleware serves req through the middleware b.N times
+func benchmarkMiddleware(b *testing.B, config Config, req *http.Request) {
+	middleware := New(config)
+	defer middleware.Close()
+	handler := middleware.Handler(noContentHandler)
+	w := &discardResponseWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(w, req)
+	}
+}
+
+// BenchmarkMiddleware_POST measures the pass-through for uncacheable methods
+func BenchmarkMiddleware_POST(b *testing.B) {
+	benchmarkMiddleware(b, DefaultConfig(), httptest.NewRequest("POST", "/api/items", nil))
+}
+
+// BenchmarkMiddleware_BypassedPath measures the pass-through for bypassed paths
+func BenchmarkMiddleware_BypassedPath(b *testing.B) {
+	config := DefaultConfig()
+	config.BypassPathPrefixes = []string{"/api/"}
+	benchmarkMiddleware(b, config, httptest.NewRequest("GET", "/api/items", nil))
+}
+
+// BenchmarkMiddleware_UncacheableGET measures the full miss path, with key
+// generation and response recording, for a response that isn't stored
+func BenchmarkMiddleware_UncacheableGET(b *testing.B) {
+	benchmarkMiddleware(b, DefaultConfig(), httptest.NewRequest("GET", "/api/items", nil))
+}
@@ -0,0 +1,62 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseRecorder_FlushStreamsAndSkipsCaching(t *testing.T) {
+	middleware := NewDefault()
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Expected the wrapped ResponseWriter to implement http.Flusher")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: one\n\n"))
+		flusher.Flush()
+		w.Write([]byte("data: two\n\n"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+
+	if !rec.Flushed {
+		t.Error("Expected Flush to reach the underlying writer")
+	}
+	if rec.Body.String() != "data: one\n\ndata: two\n\n" {
+		t.Errorf("Expected full stream to reach the client, got %q", rec.Body.String())
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+	if calls != 2 {
+		t.Errorf("Expected streamed response not to be cached, handler called %d times", calls)
+	}
+}
+
+func TestResponseRecorder_FlushStopsBuffering(t *testing.T) {
+	recorder := NewResponseRecorder(httptest.NewRecorder(), "GET")
+	recorder.Write([]byte("before"))
+	recorder.Flush()
+	recorder.Write([]byte("after"))
+
+	if !recorder.Streamed() {
+		t.Error("Expected recorder to be marked as streamed")
+	}
+	if recorder.Size() != 0 {
+		t.Errorf("Expected no body buffered after Flush, got %d bytes", recorder.Size())
+	}
+}
+
+func TestResponseRecorder_FlushWithoutFlusher(t *testing.T) {
+	// A writer without Flush support must not panic
+	recorder := NewResponseRecorder(&discardResponseWriter{header: make(http.Header)}, "GET")
+	recorder.Flush()
+
+	if recorder.StatusCode() != http.StatusOK || !recorder.Streamed() {
+		t.Errorf("Expected implicit 200 and streamed flag, got %d, %v", recorder.StatusCode(), recorder.Streamed())
+	}
+}
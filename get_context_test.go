@@ -0,0 +1,69 @@
+package selectcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_GetContext(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	cache.Set("key", []byte("value"), http.Header{}, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if entry, found := cache.GetContext(ctx, "key"); !found || string(entry.Data) != "value" {
+		t.Fatalf("Expected entry with a live context, got %v, %v", entry, found)
+	}
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, found := cache.GetContext(cancelled, "key"); found {
+		t.Error("Expected lookup to abort with a cancelled context")
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if _, found := cache.GetContext(expired, "key"); found {
+		t.Error("Expected lookup to abort past the context deadline")
+	}
+}
+
+func TestMiddleware_CancelledRequestAbortsLookup(t *testing.T) {
+	logger := &recordingLogger{}
+	config := DefaultConfig()
+	config.Logger = logger
+	middleware := New(config)
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	// Populate the cache
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api", nil).WithContext(ctx))
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected nothing written for a disconnected client, got %q", rec.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("Expected origin not to be called after an aborted lookup, got %d calls", calls)
+	}
+	if _, hits, _ := middleware.Stats(); hits != 0 {
+		t.Errorf("Expected aborted lookup not to count as a hit, got %d", hits)
+	}
+	if logger.count("error:get") != 1 {
+		t.Errorf("Expected aborted lookup to be reported, got events %v", logger.events)
+	}
+}
@@ -0,0 +1,83 @@
+package selectcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// inflightCall is a GetOrSet computation that concurrent callers wait on
+type inflightCall struct {
+	done  chan struct{}
+	entry *CacheEntry
+	err   error
+}
+
+// GetOrSet returns the cached entry for key, or runs compute and stores its
+// result with the given TTL. Concurrent callers for the same key share a single
+// compute call. A failed compute is not cached; its error is returned to every
+// caller waiting on it. If compute panics, waiting callers get an error and the
+// panic is passed on to the caller that ran it.
+func (c *TTLCache) GetOrSet(key string, ttl time.Duration, compute func() ([]byte, http.Header, error)) (*CacheEntry, error) {
+	if entry, found := c.Get(key); found {
+		return entry, nil
+	}
+
+	c.inflightMu.Lock()
+	if call, exists := c.inflight[key]; exists {
+		c.inflightMu.Unlock()
+		<-call.done
+		return call.entry, call.err
+	}
+	// Another caller may have stored the entry since the lookup above
+	if entry, found := c.peek(key); found {
+		c.inflightMu.Unlock()
+		return entry, nil
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.inflightMu.Unlock()
+
+	defer func() {
+		c.inflightMu.Lock()
+		delete(c.inflight, key)
+		c.inflightMu.Unlock()
+		close(call.done)
+	}()
+	defer func() {
+		if v := recover(); v != nil {
+			call.entry, call.err = nil, fmt.Errorf("compute panicked: %v", v)
+			panic(v)
+		}
+	}()
+
+	data, headers, err := compute()
+	if err != nil {
+		call.err = err
+		if c.config.Logger != nil {
+			c.config.Logger.OnError("compute", err)
+		}
+		return nil, err
+	}
+
+	// With the store circuit open, hand back the computed entry uncached
+	call.entry, call.err = c.store(key, data, headers, ttl)
+	if errors.Is(call.err, ErrCircuitOpen) {
+		call.err = nil
+	}
+	return call.entry, call.err
+}
+
+// peek returns an unexpired entry without updating access time or metrics
+func (c *TTLCache) peek(key string) (*CacheEntry, bool) {
+	shard := c.shardFor(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.entries[key]
+	if !exists || entry.IsExpired() {
+		return nil, false
+	}
+	return entry, true
+}
@@ -0,0 +1,142 @@
+package selectcache
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_GetOrSetComputesOnce(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	var computes atomic.Int32
+	release := make(chan struct{})
+	compute := func() ([]byte, http.Header, error) {
+		computes.Add(1)
+		<-release
+		return []byte("value"), http.Header{"Content-Type": []string{"text/plain"}}, nil
+	}
+
+	const callers = 50
+	var wg sync.WaitGroup
+	results := make(chan *CacheEntry, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry, err := cache.GetOrSet("key", time.Minute, compute)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			results <- entry
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if got := computes.Load(); got != 1 {
+		t.Errorf("Expected compute to run once, ran %d times", got)
+	}
+	for entry := range results {
+		if string(entry.Data) != "value" || entry.ContentType != "text/plain" {
+			t.Errorf("Unexpected entry: %q %q", entry.Data, entry.ContentType)
+		}
+	}
+
+	entry, err := cache.GetOrSet("key", time.Minute, func() ([]byte, http.Header, error) {
+		t.Error("compute should not run for a cached key")
+		return nil, nil, nil
+	})
+	if err != nil || string(entry.Data) != "value" {
+		t.Errorf("Expected cached entry, got %v, %v", entry, err)
+	}
+}
+
+func TestTTLCache_GetOrSetErrorNotCached(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	errBackend := errors.New("backend unavailable")
+	_, err := cache.GetOrSet("key", time.Minute, func() ([]byte, http.Header, error) {
+		return nil, nil, errBackend
+	})
+	if !errors.Is(err, errBackend) {
+		t.Fatalf("Expected compute error to propagate, got %v", err)
+	}
+	if _, found := cache.Get("key"); found {
+		t.Fatal("Expected failed compute not to be cached")
+	}
+
+	entry, err := cache.GetOrSet("key", time.Minute, func() ([]byte, http.Header, error) {
+		return []byte("recovered"), nil, nil
+	})
+	if err != nil || string(entry.Data) != "recovered" {
+		t.Errorf("Expected retry to compute and store, got %v, %v", entry, err)
+	}
+}
+
+// TestTTLCache_GetOrSetComputePanics verifies that callers waiting on a
+// compute that panics get an error instead of a nil entry, and that the
+// panic reaches the caller running compute
+func TestTTLCache_GetOrSetComputePanics(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), nil)
+	defer cache.Close()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	leaderPanic := make(chan interface{}, 1)
+	go func() {
+		defer func() { leaderPanic <- recover() }()
+		cache.GetOrSet("key", time.Minute, func() ([]byte, http.Header, error) {
+			close(entered)
+			<-release
+			panic("backend exploded")
+		})
+	}()
+	<-entered
+
+	const waiters = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry, err := cache.GetOrSet("key", time.Minute, func() ([]byte, http.Header, error) {
+				return []byte("value"), nil, nil
+			})
+			if entry != nil && err == nil {
+				return // arrived after the failed call finished and computed afresh
+			}
+			errs <- err
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	if v := <-leaderPanic; v != "backend exploded" {
+		t.Errorf("Expected the panic to reach the computing caller, got %v", v)
+	}
+	waited := 0
+	for err := range errs {
+		waited++
+		if err == nil || !strings.Contains(err.Error(), "compute panicked: backend exploded") {
+			t.Errorf("Expected a compute panicked error, got %v", err)
+		}
+	}
+	if waited == 0 {
+		t.Error("Expected some callers to wait on the panicking compute")
+	}
+}
@@ -2,4 +2,7 @@ module github.com/go-i2p/go-select-cache
 
 go 1.24.2
 
-require github.com/patrickmn/go-cache v2.1.0+incompatible
+require (
+	github.com/andybalholm/brotli v1.2.5
+	github.com/klauspost/compress v1.18.0
+)
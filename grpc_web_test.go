@@ -0,0 +1,193 @@
+package selectcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// grpcWebFrame builds one gRPC-Web frame
+func grpcWebFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, grpcWebFrameHeaderSize, grpcWebFrameHeaderSize+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// grpcWebBody builds a body of one message frame and a trailers frame
+// carrying status
+func grpcWebBody(status string) []byte {
+	message := grpcWebFrame(0x00, []byte{0x0a, 0x06, 'w', 'i', 'd', 'g', 'e', 't'})
+	trailers := grpcWebFrame(grpcWebTrailersFlag, []byte("grpc-status:"+status+"\r\ngrpc-message:\r\n"))
+	return append(message, trailers...)
+}
+
+// TestParseGRPCWebFrames verifies frame walking over sample bodies
+func TestParseGRPCWebFrames(t *testing.T) {
+	ok := grpcWebBody("0")
+	tests := []struct {
+		name     string
+		body     []byte
+		complete bool
+		status   string
+	}{
+		{"message and trailers", ok, true, "0"},
+		{"error status", grpcWebBody("5"), true, "5"},
+		{"messages only", grpcWebFrame(0x00, []byte("abc")), true, ""},
+		{"empty", nil, true, ""},
+		{"truncated payload", ok[:len(ok)-3], false, ""},
+		{"truncated header", ok[:3], false, ""},
+		{"data after trailers", append(append([]byte{}, ok...), 0x00), false, "0"},
+		{"unknown flag", grpcWebFrame(0x02, []byte("abc")), false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trailers, complete := parseGRPCWebFrames(tt.body)
+			if complete != tt.complete {
+				t.Errorf("Expected complete=%v, got %v", tt.complete, complete)
+			}
+			if status := grpcWebTrailerStatus(trailers); status != tt.status {
+				t.Errorf("Expected grpc-status %q, got %q", tt.status, status)
+			}
+		})
+	}
+}
+
+// TestDetectContentType_GRPCWeb verifies that gRPC-Web frames are detected
+// from the frame header, including in a truncated sample
+func TestDetectContentType_GRPCWeb(t *testing.T) {
+	detector := NewContentDetector(DefaultCacheConfig())
+	body := grpcWebBody("0")
+
+	if contentType := detector.DetectContentTypeFromBytes(body); contentType != "application/grpc-web+proto" {
+		t.Errorf("Expected application/grpc-web+proto, got %q", contentType)
+	}
+	if contentType := detector.DetectContentTypeFromBytes(body[:15]); contentType != "application/grpc-web+proto" {
+		t.Errorf("Expected a truncated sample to be detected, got %q", contentType)
+	}
+	if contentType := detector.DetectContentTypeFromBytes([]byte(`{"id": 1}`)); contentType == "application/grpc-web+proto" {
+		t.Error("Expected JSON not to be detected as gRPC-Web")
+	}
+}
+
+// TestMiddleware_GRPCWeb verifies that successful gRPC-Web responses are
+// replayed byte for byte and that failed or cut-off calls are not cached
+func TestMiddleware_GRPCWeb(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   []byte
+		status string
+		cached bool
+	}{
+		{"ok", grpcWebBody("0"), "", true},
+		{"error in trailers frame", grpcWebBody("5"), "", false},
+		{"trailers-only error", nil, "14", false},
+		{"truncated", grpcWebBody("0")[:12], "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := New(DefaultConfig())
+			defer middleware.Close()
+
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/grpc-web+proto")
+				if tt.status != "" {
+					w.Header().Set("Grpc-Status", tt.status)
+				}
+				w.Write(tt.body)
+			}))
+			req := httptest.NewRequest("GET", "/widgets.Widgets/Get", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			if hit := resp.Header().Get("X-Cache-Status") == "HIT"; hit != tt.cached {
+				t.Fatalf("Expected hit=%v, got %v", tt.cached, hit)
+			}
+			if !bytes.Equal(resp.Body.Bytes(), tt.body) {
+				t.Errorf("Expected the body byte for byte, got %x", resp.Body.Bytes())
+			}
+			want := uint64(0)
+			if !tt.cached {
+				want = 2 // both requests missed
+			}
+			if count := middleware.GetMetrics().GetStats().Errors["grpc_web_uncached"]; count != want {
+				t.Errorf("Expected %d grpc_web_uncached errors, got %d", want, count)
+			}
+		})
+	}
+}
+
+// TestMiddleware_ContentTypeTTLs verifies per content type TTLs
+func TestMiddleware_ContentTypeTTLs(t *testing.T) {
+	config := DefaultConfig()
+	config.ContentTypeTTLs = map[string]time.Duration{
+		"application/x-protobuf": 30 * time.Second,
+		"application/*":          2 * time.Minute,
+	}
+	middleware := New(config)
+	defer middleware.Close()
+
+	tests := []struct {
+		contentType string
+		body        []byte
+		ttl         time.Duration
+	}{
+		{"application/x-protobuf", []byte{0x0a, 0x02, 'o', 'k'}, 30 * time.Second},
+		{"application/x-protobuf; proto=v1", []byte{0x0a, 0x02, 'o', 'k'}, 30 * time.Second},
+		{"application/grpc-web+proto", grpcWebBody("0"), 2 * time.Minute},
+		{"text/plain; charset=utf-8", []byte("ok"), config.DefaultTTL},
+	}
+	for i, tt := range tests {
+		contentType, body, want := tt.contentType, tt.body, tt.ttl
+		handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write(body)
+		}))
+		req := httptest.NewRequest("GET", fmt.Sprintf("/messages/%d", i), nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		stored, found := middleware.cache.getResponse(middleware.createCacheKey(req))
+		if !found {
+			t.Errorf("%s: expected the response to be cached", contentType)
+			continue
+		}
+		if ttl := stored.ExpiresAt.Sub(stored.StoreTime); ttl != want {
+			t.Errorf("%s: expected a TTL of %v, got %v", contentType, want, ttl)
+		}
+	}
+}
+
+// TestCachingConnection_GRPCWeb verifies that the transport stores gRPC-Web
+// bodies unaltered and skips failed calls
+func TestCachingConnection_GRPCWeb(t *testing.T) {
+	for status, cached := range map[string]bool{"0": true, "5": false} {
+		t.Run("grpc-status "+status, func(t *testing.T) {
+			config := DefaultCacheConfig()
+			cache := NewTTLCache(config, nil)
+			defer cache.Close()
+			conn, mockConn := newPooledConnection(config, cache, nil, nil)
+			defer conn.Close()
+
+			body := grpcWebBody(status)
+			mockConn.writeToReadBuffer([]byte("GET /widgets.Widgets/Get HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+			conn.Read(make([]byte, 1024))
+			conn.Write(append([]byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/grpc-web+proto\r\nContent-Length: %d\r\n\r\n", len(body))), body...))
+
+			key := GenerateCacheKey("GET", "/widgets.Widgets/Get", "", map[string]string{"Host": "example.com"})
+			entry, found := waitForEntry(cache, key, 100*time.Millisecond)
+			if found != cached {
+				t.Fatalf("Expected cached=%v, got %v", cached, found)
+			}
+			if found && !bytes.Equal(entry.Data, body) {
+				t.Errorf("Expected the body byte for byte, got %x", entry.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package selectcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// gRPC-Web bodies are a sequence of frames: a flag byte, a 4-byte big-endian
+// payload length and the payload. A frame flagged as trailers ends the
+// response and carries grpc-status as HTTP/1-style header lines, so a
+// response is only known to be complete, and successful, once it is read.
+const (
+	grpcWebFrameHeaderSize = 5
+	grpcWebCompressedFlag  = 0x01
+	grpcWebTrailersFlag    = 0x80
+)
+
+// isGRPCWebContentType reports whether a Content-Type is binary gRPC-Web
+// ("application/grpc-web" or "application/grpc-web+proto"). The base64
+// "application/grpc-web-text" variant isn't framed in binary and is cached
+// like any other response.
+func isGRPCWebContentType(contentType string) bool {
+	mediaType := normalizeMediaType(contentType)
+	return mediaType == "application/grpc-web" || strings.HasPrefix(mediaType, "application/grpc-web+")
+}
+
+// validGRPCWebFlag reports whether a frame flag byte has only known bits set
+func validGRPCWebFlag(flag byte) bool {
+	return flag&^(grpcWebCompressedFlag|grpcWebTrailersFlag) == 0
+}
+
+// parseGRPCWebFrames walks the frames of a gRPC-Web body and returns the
+// trailers frame's payload, if any. complete is false unless the frames
+// exactly fill the body, ending at the trailers frame when there is one.
+func parseGRPCWebFrames(body []byte) (trailers []byte, complete bool) {
+	for len(body) > 0 {
+		if len(body) < grpcWebFrameHeaderSize || !validGRPCWebFlag(body[0]) {
+			return nil, false
+		}
+		length := binary.BigEndian.Uint32(body[1:grpcWebFrameHeaderSize])
+		if uint64(length) > uint64(len(body)-grpcWebFrameHeaderSize) {
+			return nil, false
+		}
+
+		flag := body[0]
+		payload := body[grpcWebFrameHeaderSize : grpcWebFrameHeaderSize+int(length)]
+		body = body[grpcWebFrameHeaderSize+int(length):]
+		if flag&grpcWebTrailersFlag != 0 {
+			return payload, len(body) == 0
+		}
+	}
+	return nil, true
+}
+
+// looksLikeGRPCWeb reports whether data, which may be a truncated sample,
+// starts with a complete gRPC-Web frame followed by nothing or by another
+// frame's flag byte
+func looksLikeGRPCWeb(data []byte) bool {
+	if len(data) < grpcWebFrameHeaderSize || !validGRPCWebFlag(data[0]) {
+		return false
+	}
+	length := binary.BigEndian.Uint32(data[1:grpcWebFrameHeaderSize])
+	if uint64(length) > uint64(len(data)-grpcWebFrameHeaderSize) {
+		return false
+	}
+	rest := data[grpcWebFrameHeaderSize+int(length):]
+	return len(rest) == 0 || validGRPCWebFlag(rest[0])
+}
+
+// grpcWebTrailerStatus returns the grpc-status line of a trailers frame
+func grpcWebTrailerStatus(trailers []byte) string {
+	for _, line := range bytes.Split(trailers, []byte("\n")) {
+		name, value, found := strings.Cut(string(line), ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "grpc-status") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// grpcWebCacheable reports whether a gRPC-Web response is whole and
+// succeeded, with a grpc-status of 0. gRPC errors arrive with HTTP 200, so
+// the status code alone would cache them. The status is taken from the
+// headers of a trailers-only response or from HTTP trailers when sent there;
+// otherwise the frames must fill the body and end in a trailers frame
+// carrying it. Other content types are always cacheable as far as this check
+// goes.
+func grpcWebCacheable(headers, httpTrailers http.Header, body []byte) bool {
+	if !isGRPCWebContentType(headers.Get("Content-Type")) {
+		return true
+	}
+
+	if status := headers.Get("Grpc-Status"); status != "" {
+		return status == "0"
+	}
+	if status := httpTrailers.Get("Grpc-Status"); status != "" {
+		return status == "0"
+	}
+	trailers, complete := parseGRPCWebFrames(body)
+	return complete && grpcWebTrailerStatus(trailers) == "0"
+}
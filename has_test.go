@@ -0,0 +1,56 @@
+package selectcache
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTTLCache_HasKeepsEvictionOrder verifies that Has reports entries
+// without counting as an access: the entry checked is still evicted first
+func TestTTLCache_HasKeepsEvictionOrder(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.MaxEntries = 2
+	config.Clock = clock
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	cache.Set("oldest", []byte("a"), nil, time.Hour)
+	clock.Advance(time.Second)
+	cache.Set("newer", []byte("b"), nil, time.Hour)
+	clock.Advance(time.Second)
+
+	if !cache.Has("oldest") {
+		t.Fatal("Expected Has to find the entry")
+	}
+	if cache.Has("missing") {
+		t.Error("Expected Has to report a missing key as absent")
+	}
+	if stats := metrics.GetStats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Expected Has to record no hits or misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+
+	cache.Set("newest", []byte("c"), nil, time.Hour)
+	if cache.Has("oldest") {
+		t.Error("Expected the entry checked with Has to be evicted first")
+	}
+	if !cache.Has("newer") || !cache.Has("newest") {
+		t.Error("Expected the other entries to survive")
+	}
+}
+
+// TestTTLCache_HasExpired verifies that Has reports expired entries as absent
+func TestTTLCache_HasExpired(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.Clock = clock
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.Set("key", []byte("value"), nil, time.Minute)
+	clock.Advance(2 * time.Minute)
+	if cache.Has("key") {
+		t.Error("Expected Has to report an expired entry as absent")
+	}
+}
@@ -54,15 +54,13 @@ func TestHEADRequestBodyCachingFixed(t *testing.T) {
 	// With the fix: GET and HEAD share cache keys, HEAD reuses GET's cache entry
 	// This is correct behavior - the issue was when HEAD-only requests cache body data
 	key := middleware.createCacheKey(reqGET) // Same key for GET and HEAD
-	if cached, found := middleware.GetCacheForTesting().Get(key); found {
-		if cachedResp, ok := cached.(*CachedResponse); ok {
-			bodySize := len(cachedResp.Body)
-			t.Logf("Cache contains %d bytes of body data from GET request", bodySize)
-			if bodySize == 10240 {
-				t.Logf("SUCCESS: This is correct behavior - HEAD reuses GET cache entry which includes body data")
-			} else {
-				t.Errorf("Expected cached body size to be 10240 bytes from GET request, got %d", bodySize)
-			}
+	if cachedResp, found := middleware.GetCacheForTesting().getResponse(key); found {
+		bodySize := len(cachedResp.Body)
+		t.Logf("Cache contains %d bytes of body data from GET request", bodySize)
+		if bodySize == 10240 {
+			t.Logf("SUCCESS: This is correct behavior - HEAD reuses GET cache entry which includes body data")
+		} else {
+			t.Errorf("Expected cached body size to be 10240 bytes from GET request, got %d", bodySize)
 		}
 	} else {
 		t.Error("Expected cache entry to exist from GET request")
@@ -95,26 +93,24 @@ func TestHEADOnlyRequestBodyCachingFixed(t *testing.T) {
 
 	// Check cached entry - this verifies the fix is working
 	key := middleware.createCacheKey(reqHEAD)
-	if cached, found := middleware.GetCacheForTesting().Get(key); found {
-		if cachedResp, ok := cached.(*CachedResponse); ok {
-			bodySize := len(cachedResp.Body)
-			t.Logf("HEAD request cached %d bytes of body data", bodySize)
-
-			// FIXED: HEAD requests should not cache body data
-			if bodySize > 0 {
-				t.Errorf("HEAD REQUEST BODY CACHING BUG DETECTED: HEAD request cached %d bytes of unnecessary body data", bodySize)
-				t.Errorf("Expected: HEAD requests should cache headers only (0 bytes body)")
-				t.Errorf("This indicates the HEAD request fix is not working properly")
-			} else {
-				t.Logf("SUCCESS: HEAD request correctly cached only headers, no body data")
-			}
-
-			// Verify headers are still cached
-			if cachedResp.Headers.Get("Content-Type") != "application/json" {
-				t.Error("Headers should still be cached for HEAD requests")
-			} else {
-				t.Logf("SUCCESS: Headers correctly cached for HEAD request")
-			}
+	if cachedResp, found := middleware.GetCacheForTesting().getResponse(key); found {
+		bodySize := len(cachedResp.Body)
+		t.Logf("HEAD request cached %d bytes of body data", bodySize)
+
+		// FIXED: HEAD requests should not cache body data
+		if bodySize > 0 {
+			t.Errorf("HEAD REQUEST BODY CACHING BUG DETECTED: HEAD request cached %d bytes of unnecessary body data", bodySize)
+			t.Errorf("Expected: HEAD requests should cache headers only (0 bytes body)")
+			t.Errorf("This indicates the HEAD request fix is not working properly")
+		} else {
+			t.Logf("SUCCESS: HEAD request correctly cached only headers, no body data")
+		}
+
+		// Verify headers are still cached
+		if cachedResp.Headers.Get("Content-Type") != "application/json" {
+			t.Error("Headers should still be cached for HEAD requests")
+		} else {
+			t.Logf("SUCCESS: Headers correctly cached for HEAD request")
 		}
 	} else {
 		t.Error("HEAD request should have been cached")
@@ -22,19 +22,17 @@ func TestHEADRequestMemoryOptimization(t *testing.T) {
 
 	// Check that cached entry doesn't contain body data for HEAD requests
 	key := middleware.createCacheKey(req)
-	if cached, found := middleware.GetCacheForTesting().Get(key); found {
-		if cachedResp, ok := cached.(*CachedResponse); ok {
-			if len(cachedResp.Body) > 0 {
-				t.Errorf("HEAD request should not cache body data, got %d bytes", len(cachedResp.Body))
-			}
-
-			// Verify headers are still cached
-			if cachedResp.Headers.Get("Content-Type") != "application/json" {
-				t.Error("HEAD request should still cache headers")
-			}
+	if cachedResp, found := middleware.GetCacheForTesting().getResponse(key); found {
+		if len(cachedResp.Body) > 0 {
+			t.Errorf("HEAD request should not cache body data, got %d bytes", len(cachedResp.Body))
+		}
 
-			t.Logf("✅ HEAD request optimization verified: 0 bytes body cached, headers preserved")
+		// Verify headers are still cached
+		if cachedResp.Headers.Get("Content-Type") != "application/json" {
+			t.Error("HEAD request should still cache headers")
 		}
+
+		t.Logf("✅ HEAD request optimization verified: 0 bytes body cached, headers preserved")
 	} else {
 		t.Error("HEAD request should be cached")
 	}
@@ -0,0 +1,95 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// headerTTLCases are X-Cache-TTL values with the TTL they should store an
+// entry for; zero means the response isn't cached
+var headerTTLCases = []struct {
+	name   string
+	value  string
+	stored time.Duration
+}{
+	{"valid", "120", 2 * time.Minute},
+	{"zero", "0", 0},
+	{"not a number", "soon", 15 * time.Minute},
+	{"negative", "-5", 15 * time.Minute},
+	{"fractional", "1.5", 15 * time.Minute},
+}
+
+// TestMiddleware_HeaderTTL verifies that a handler's X-Cache-TTL sets the
+// entry's TTL, and that the header never reaches clients
+func TestMiddleware_HeaderTTL(t *testing.T) {
+	for _, tt := range headerTTLCases {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := New(DefaultConfig())
+			defer middleware.Close()
+
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(CacheTTLHeader, tt.value)
+				w.Write([]byte(`{"price": 42}`))
+			}))
+			req := httptest.NewRequest("GET", "/price", nil)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			if value := resp.Header().Get(CacheTTLHeader); value != "" {
+				t.Errorf("Expected %s to be withheld from the client, got %q", CacheTTLHeader, value)
+			}
+
+			stored, found := middleware.cache.getResponse(middleware.createCacheKey(req))
+			if found != (tt.stored > 0) {
+				t.Fatalf("Expected cached=%v, got %v", tt.stored > 0, found)
+			}
+			if !found {
+				return
+			}
+			if ttl := stored.ExpiresAt.Sub(stored.StoreTime); ttl != tt.stored {
+				t.Errorf("Expected a TTL of %v, got %v", tt.stored, ttl)
+			}
+			if value := stored.Headers.Get(CacheTTLHeader); value != "" {
+				t.Errorf("Expected %s not to be stored, got %q", CacheTTLHeader, value)
+			}
+		})
+	}
+}
+
+// TestCachingConnection_HeaderTTL verifies X-Cache-TTL on the transport path
+func TestCachingConnection_HeaderTTL(t *testing.T) {
+	for _, tt := range headerTTLCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultCacheConfig()
+			config.DefaultTTL = 15 * time.Minute
+			cache := NewTTLCache(config, nil)
+			defer cache.Close()
+			conn, mockConn := newPooledConnection(config, cache, nil, nil)
+			defer conn.Close()
+
+			body := `{"price": 42}`
+			mockConn.writeToReadBuffer([]byte("GET /price HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+			conn.Read(make([]byte, 1024))
+			conn.Write([]byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n%s: %s\r\nContent-Length: %d\r\n\r\n%s",
+				CacheTTLHeader, tt.value, len(body), body)))
+
+			key := GenerateCacheKey("GET", "/price", "", map[string]string{"Host": "example.com"})
+			entry, found := waitForEntry(cache, key, 100*time.Millisecond)
+			if found != (tt.stored > 0) {
+				t.Fatalf("Expected cached=%v, got %v", tt.stored > 0, found)
+			}
+			if !found {
+				return
+			}
+			if ttl := entry.ExpiresAt.Sub(entry.StoreTime); ttl != tt.stored {
+				t.Errorf("Expected a TTL of %v, got %v", tt.stored, ttl)
+			}
+			if value := entry.Headers.Get(CacheTTLHeader); value != "" {
+				t.Errorf("Expected %s not to be stored, got %q", CacheTTLHeader, value)
+			}
+		})
+	}
+}
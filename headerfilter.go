@@ -0,0 +1,50 @@
+package selectcache
+
+import "net/http"
+
+// defaultStripHeaders are response headers that must never be stored or
+// replayed from cache: per-user state and hop-by-hop headers
+var defaultStripHeaders = []string{
+	"Set-Cookie",
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Upgrade",
+}
+
+// DefaultStripHeaders returns the headers removed from responses before they
+// are cached when no StripHeaders are configured
+func DefaultStripHeaders() []string {
+	return append([]string(nil), defaultStripHeaders...)
+}
+
+// filterHeaders returns a copy of headers suitable for caching. When allow is
+// non-empty only the listed headers are kept; headers in strip are always
+// removed. An empty strip list uses DefaultStripHeaders.
+func filterHeaders(headers http.Header, strip, allow []string) http.Header {
+	if len(strip) == 0 {
+		strip = defaultStripHeaders
+	}
+
+	filtered := make(http.Header, len(headers))
+	if len(allow) > 0 {
+		for _, name := range allow {
+			if values, exists := headers[http.CanonicalHeaderKey(name)]; exists {
+				filtered[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+			}
+		}
+	} else {
+		for name, values := range headers {
+			filtered[name] = append([]string(nil), values...)
+		}
+	}
+
+	for _, name := range strip {
+		filtered.Del(name)
+	}
+	return filtered
+}
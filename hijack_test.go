@@ -0,0 +1,96 @@
+package selectcache
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestUpgradeHandshakePassthrough simulates a WebSocket-style upgrade through
+// both the middleware and the caching listener
+func TestUpgradeHandshakePassthrough(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	// A cached entry that an echoed HTTP-looking frame must not be answered with
+	cachingListener.cache.Set(GenerateCacheKey("GET", "/cached", "", map[string]string{}),
+		[]byte("from cache"), http.Header{"Content-Type": []string{"text/plain"}}, time.Minute)
+
+	middleware := NewDefault()
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		io.Copy(conn, rw)
+	}))
+	server := &http.Server{Handler: handler}
+	go server.Serve(cachingListener)
+	defer server.Close()
+
+	client, err := net.Dial("tcp", baseListener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(client, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+	reader := bufio.NewReader(client)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101 Switching Protocols, got %d", resp.StatusCode)
+	}
+
+	frame := "GET /cached HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	io.WriteString(client, frame)
+	echoed := make([]byte, len(frame))
+	if _, err := io.ReadFull(reader, echoed); err != nil {
+		t.Fatalf("Failed to read echo: %v", err)
+	}
+	if string(echoed) != frame {
+		t.Errorf("Expected upgraded stream to pass through untouched, got %q", echoed)
+	}
+	if strings.Contains(string(echoed), "from cache") {
+		t.Error("Upgraded stream was answered from the cache")
+	}
+
+	cachingListener.activeConns.Range(func(_, value interface{}) bool {
+		if !value.(*CachingConnection).passthrough.Load() {
+			t.Error("Expected the upgraded connection to switch to passthrough")
+		}
+		return true
+	})
+
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected hijacked response not to be cached, got %d entries", items)
+	}
+}
+
+func TestResponseRecorder_HijackUnsupported(t *testing.T) {
+	recorder := NewResponseRecorder(httptest.NewRecorder(), "GET")
+	if _, _, err := recorder.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Expected http.ErrNotSupported, got %v", err)
+	}
+	if recorder.Streamed() {
+		t.Error("Expected a failed hijack not to mark the response streamed")
+	}
+}
@@ -0,0 +1,127 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMiddleware_IncludeHostInKey verifies that virtual hosts with the same
+// path get separate entries, and share one when the host is left out
+func TestMiddleware_IncludeHostInKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		includeHost bool
+		wantItems   int
+	}{
+		{"host in key", true, 2},
+		{"host ignored", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.IncludeHostInKey = tt.includeHost
+			middleware := New(config)
+			defer middleware.Close()
+
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"host": "` + r.Host + `"}`))
+			}))
+
+			for _, host := range []string{"a.example.com", "b.example.com"} {
+				req := httptest.NewRequest("GET", "/x", nil)
+				req.Host = host
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			if items, _, _ := middleware.Stats(); items != tt.wantItems {
+				t.Errorf("Expected %d entries, got %d", tt.wantItems, items)
+			}
+		})
+	}
+}
+
+// TestCachingConnection_IncludeHostInKey verifies that the transport layer
+// keys on the Host header, ignoring its case
+func TestCachingConnection_IncludeHostInKey(t *testing.T) {
+	config := DefaultCacheConfig()
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	keyFor := func(host string) string {
+		conn := newMockConn()
+		cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+		defer cc.Close()
+
+		conn.writeToReadBuffer([]byte("GET /x HTTP/1.1\r\nHost: " + host + "\r\n\r\n"))
+		cc.Read(make([]byte, 1024))
+
+		cc.stateMu.RLock()
+		defer cc.stateMu.RUnlock()
+		return cc.cacheKey
+	}
+
+	if keyFor("a.example.com") == keyFor("b.example.com") {
+		t.Error("Expected different hosts to get different keys")
+	}
+	if keyFor("a.example.com") != keyFor("A.Example.com") {
+		t.Error("Expected host case to be ignored")
+	}
+}
+
+// TestMiddleware_IncludeHostInKeyDelete verifies that deleting and purging an
+// absolute URL only affects its own host, that a bare path affects every host,
+// and that a Vary learned on one host isn't applied to another
+func TestMiddleware_IncludeHostInKeyDelete(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Host == "a.example.com" {
+			w.Header().Set("Vary", "X-Tenant")
+		}
+		w.Write([]byte(`{"host": "` + r.Host + `"}`))
+	}))
+	requestFor := func(host string) *http.Request {
+		req := httptest.NewRequest("GET", "/x", nil)
+		req.Host = host
+		req.Header.Set("X-Tenant", "acme")
+		return req
+	}
+	cached := func(host string) bool {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, requestFor(host))
+		return resp.Header().Get("X-Cache-Status") == "HIT"
+	}
+	prime := func() {
+		for _, host := range []string{"a.example.com", "b.example.com"} {
+			handler.ServeHTTP(httptest.NewRecorder(), requestFor(host))
+		}
+	}
+
+	prime()
+	if vary := middleware.varyFor(middleware.variantResource(requestFor("b.example.com"))); len(vary) != 0 {
+		t.Errorf("Expected no Vary learned for b.example.com, got %v", vary)
+	}
+
+	middleware.Delete("http://a.example.com/x")
+	if cached("a.example.com") || !cached("b.example.com") {
+		t.Error("Expected Delete to remove only a.example.com's entry")
+	}
+
+	prime()
+	resp := httptest.NewRecorder()
+	middleware.PurgeHandler().ServeHTTP(resp, httptest.NewRequest("POST", "/purge?url=http://b.example.com/x", nil))
+	if !cached("a.example.com") || cached("b.example.com") {
+		t.Error("Expected the purge to remove only b.example.com's entry")
+	}
+
+	prime()
+	middleware.Delete("/x")
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected a bare path to be removed on every host, got %d entries", items)
+	}
+}
@@ -0,0 +1,116 @@
+package selectcache
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newIdleTimeoutConn(t *testing.T, timeout time.Duration) (*CachingConnection, net.Conn, *CacheMetrics) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	config := DefaultCacheConfig()
+	config.ConnectionTimeout = timeout
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	t.Cleanup(func() { cache.Close() })
+
+	conn := NewCachingConnection(server, cache, config, metrics, NewContentDetector(config))
+	t.Cleanup(func() { conn.Close() })
+	return conn, client, metrics
+}
+
+func TestCachingConnection_IdleTimeoutClosesConnection(t *testing.T) {
+	conn, _, metrics := newIdleTimeoutConn(t, 50*time.Millisecond)
+
+	// A blocked Read must be released when the idle timeout closes the connection
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 16)
+		_, err := conn.Read(buf)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected Read to fail after idle timeout")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Idle connection was not closed")
+	}
+
+	if !conn.GetStats().Closed {
+		t.Error("Expected connection to be marked closed")
+	}
+	if got := metrics.GetStats().Errors["connection_idle_timeout"]; got != 1 {
+		t.Errorf("Expected connection_idle_timeout to be recorded once, got %d", got)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected io.EOF reading closed connection, got %v", err)
+	}
+}
+
+func TestCachingConnection_ActivityDefersIdleTimeout(t *testing.T) {
+	conn, client, metrics := newIdleTimeoutConn(t, 150*time.Millisecond)
+
+	go io.Copy(io.Discard, client)
+
+	// Keep writing well within the timeout window for longer than the timeout
+	deadline := time.Now().Add(400 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if _, err := conn.Write([]byte("x")); err != nil {
+			t.Fatalf("Active connection was closed: %v", err)
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+
+	if conn.GetStats().Closed {
+		t.Fatal("Active connection should not be closed by the idle timeout")
+	}
+	if got := metrics.GetStats().Errors["connection_idle_timeout"]; got != 0 {
+		t.Errorf("Expected no idle timeouts, got %d", got)
+	}
+}
+
+func TestCachingConnection_PendingRequestDefersIdleTimeout(t *testing.T) {
+	conn, client, metrics := newIdleTimeoutConn(t, 50*time.Millisecond)
+
+	// The request header block arrives split across two reads
+	go func() {
+		client.Write([]byte("GET /slow HTTP/1.1\r\nHost: example.com\r\n"))
+		client.Write([]byte("\r\n"))
+	}()
+	buf := make([]byte, 1024)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Read(buf); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+
+	// A handler slower than the timeout is still working on the response
+	time.Sleep(200 * time.Millisecond)
+	if conn.GetStats().Closed {
+		t.Fatal("Connection was closed while its request awaited a response")
+	}
+
+	go io.Copy(io.Discard, client)
+	if _, err := conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Once answered, the connection is idle again
+	deadline := time.Now().Add(2 * time.Second)
+	for !conn.GetStats().Closed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !conn.GetStats().Closed {
+		t.Fatal("Expected the answered connection to be closed once idle")
+	}
+	if got := metrics.GetStats().Errors["connection_idle_timeout"]; got != 1 {
+		t.Errorf("Expected connection_idle_timeout to be recorded once, got %d", got)
+	}
+}
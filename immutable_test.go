@@ -0,0 +1,119 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const immutableYear = 365 * 24 * time.Hour
+
+// TestMiddleware_ImmutableNotRevalidated verifies that an immutable asset is
+// cached for ImmutableTTL and that a conditional request is answered from
+// cache without reaching the origin
+func TestMiddleware_ImmutableNotRevalidated(t *testing.T) {
+	config := DefaultConfig()
+	config.ImmutableTTL = immutableYear
+	middleware := New(config)
+	defer middleware.Close()
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("Cache-Control", "public, max-age=60, immutable, stale-if-error=60")
+		w.Header().Set("ETag", `"app-3f2a"`)
+		w.Write([]byte("console.log('app')"))
+	}))
+	req := httptest.NewRequest("GET", "/static/app.3f2a.js", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	stored, found := middleware.cache.getResponse(middleware.createCacheKey(req))
+	if !found {
+		t.Fatal("Expected the immutable asset to be cached")
+	}
+	if ttl := stored.ExpiresAt.Sub(stored.StoreTime); ttl != immutableYear {
+		t.Errorf("Expected a TTL of %v in place of max-age, got %v", immutableYear, ttl)
+	}
+	if !stored.Immutable || !stored.FreshUntil.IsZero() {
+		t.Errorf("Expected an immutable entry without a stale window, got Immutable=%v FreshUntil=%v", stored.Immutable, stored.FreshUntil)
+	}
+
+	conditional := httptest.NewRequest("GET", "/static/app.3f2a.js", nil)
+	conditional.Header.Set("If-None-Match", `"app-3f2a"`)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, conditional)
+	if resp.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 Not Modified, got %d", resp.Code)
+	}
+	if status := resp.Header().Get("X-Cache-Status"); status != "HIT" {
+		t.Errorf("Expected the 304 to come from cache, got %q", status)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the origin to be called once, got %d", calls)
+	}
+}
+
+// TestMiddleware_ImmutableIgnoredByDefault verifies that immutable leaves the
+// max-age TTL alone while ImmutableTTL is unset
+func TestMiddleware_ImmutableIgnoredByDefault(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Header().Set("Cache-Control", "max-age=60, immutable")
+		w.Write([]byte("body{}"))
+	}))
+	req := httptest.NewRequest("GET", "/static/site.css", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	stored, found := middleware.cache.getResponse(middleware.createCacheKey(req))
+	if !found {
+		t.Fatal("Expected the response to be cached")
+	}
+	if ttl := stored.ExpiresAt.Sub(stored.StoreTime); ttl != time.Minute || stored.Immutable {
+		t.Errorf("Expected a 1m TTL and no immutable mark, got %v (Immutable=%v)", ttl, stored.Immutable)
+	}
+}
+
+// TestImmutableNeverStale verifies that an immutable entry ignores FreshUntil
+func TestImmutableNeverStale(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	if (&CachedResponse{FreshUntil: past, Immutable: true}).IsStale(time.Now()) {
+		t.Error("Expected an immutable response never to be stale")
+	}
+	if (&CacheEntry{FreshUntil: past, Immutable: true}).IsStale() {
+		t.Error("Expected an immutable entry never to be stale")
+	}
+	if !(&CacheEntry{FreshUntil: past}).IsStale() {
+		t.Error("Expected a mutable entry past FreshUntil to be stale")
+	}
+}
+
+// TestContentDetector_ImmutableTTL verifies the transport's TTL for immutable
+// responses, and that a handler's X-Cache-TTL still wins
+func TestContentDetector_ImmutableTTL(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ImmutableTTL = immutableYear
+	detector := NewContentDetector(config)
+
+	headers := http.Header{
+		"Content-Type":  {"application/javascript"},
+		"Cache-Control": {"max-age=60, immutable"},
+	}
+	if analysis := detector.AnalyzeResponseForPath("/app.js", []byte("x"), headers, 200); analysis.RecommendedTTL != immutableYear {
+		t.Errorf("Expected %v, got %v", immutableYear, analysis.RecommendedTTL)
+	}
+
+	headers.Set(CacheTTLHeader, "300")
+	if analysis := detector.AnalyzeResponseForPath("/app.js", []byte("x"), headers, 200); analysis.RecommendedTTL != 5*time.Minute {
+		t.Errorf("Expected X-Cache-TTL to win, got %v", analysis.RecommendedTTL)
+	}
+
+	config.ImmutableTTL = -time.Second
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative ImmutableTTL to be rejected")
+	}
+}
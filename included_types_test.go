@@ -0,0 +1,90 @@
+package selectcache
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestContentDetector_IncludedTypes(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.IncludedTypes = []string{"application/json", "image/"}
+	config.ExcludedTypes = []string{"image/svg+xml"}
+	detector := NewContentDetector(config)
+
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"image/png", true},
+		{"text/css", false},                 // not in the allowlist
+		{"application/octet-stream", false}, // not in the allowlist
+		{"", false},                         // missing content type is unlisted
+		{"image/svg+xml", false},            // excluded on top of the allowlist
+		{"text/html", false},                // never on the allowlist
+	}
+
+	for _, tt := range tests {
+		headers := http.Header{}
+		if tt.contentType != "" {
+			headers.Set("Content-Type", tt.contentType)
+		}
+		if got := detector.ShouldCache([]byte("data"), headers, 200); got != tt.want {
+			t.Errorf("ShouldCache(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestCachingListener_UnlistedTypeNotCached(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	config := DefaultCacheConfig()
+	config.IncludedTypes = []string{"application/json"}
+	cachingListener := NewCachingListener(baseListener, config)
+	defer cachingListener.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data.json" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":true}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		io.WriteString(w, "a,b\n1,2\n")
+	})
+	server := &http.Server{Handler: handler}
+	go server.Serve(cachingListener)
+	defer server.Close()
+
+	// Fresh connections per request, so each response is analyzed; the CSV
+	// goes first so it has been processed once the JSON entry appears
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for _, path := range []string{"/data.csv", "/data.json"} {
+		resp, err := client.Get("http://" + baseListener.Addr().String() + path)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	headers := map[string]string{"Accept-Encoding": "gzip", "Host": baseListener.Addr().String()}
+	jsonKey := GenerateCacheKey("GET", "/data.json", "", headers)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, found := cachingListener.cache.Get(jsonKey); found {
+			break
+		}
+	}
+	if _, found := cachingListener.cache.Get(jsonKey); !found {
+		t.Fatal("Expected allowlisted JSON response to be cached")
+	}
+	if _, found := cachingListener.cache.Get(GenerateCacheKey("GET", "/data.csv", "", headers)); found {
+		t.Error("Expected unlisted text/csv response not to be cached")
+	}
+}
@@ -0,0 +1,88 @@
+package selectcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMiddleware_HandlerPanicNotCached verifies that a handler panicking
+// mid-body leaves nothing in the cache and that the panic reaches the server
+func TestMiddleware_HandlerPanicNotCached(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	body := `{"items": [1, 2, 3, 4]}`
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body[:len(body)/2]))
+		panic("database went away")
+	}))
+
+	func() {
+		defer func() {
+			if v := recover(); v != "database went away" {
+				t.Errorf("Expected the handler's panic to be passed on, got %v", v)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+	}()
+
+	if size := middleware.cache.Size(); size != 0 {
+		t.Errorf("Expected nothing cached after a panic, got %d entries", size)
+	}
+	if count := middleware.GetMetrics().GetStats().Errors["handler_panicked"]; count != 1 {
+		t.Errorf("Expected 1 handler_panicked error, got %d", count)
+	}
+}
+
+// TestMiddleware_IncompleteResponseNotCached verifies that bodies not
+// matching their Content-Length, and responses to clients that went away,
+// are not cached
+func TestMiddleware_IncompleteResponseNotCached(t *testing.T) {
+	body := `{"items": [1, 2, 3, 4]}`
+	tests := []struct {
+		name          string
+		contentLength string
+		written       string
+		disconnect    bool
+		cached        bool
+	}{
+		{"complete", "23", body, false, true},
+		{"short of Content-Length", "23", body[:10], false, false},
+		{"client went away", "", body[:10], true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := New(DefaultConfig())
+			defer middleware.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.contentLength != "" {
+					w.Header().Set("Content-Length", tt.contentLength)
+				}
+				w.Write([]byte(tt.written))
+				if tt.disconnect {
+					cancel()
+				}
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil).WithContext(ctx))
+
+			if cached := middleware.cache.Size() == 1; cached != tt.cached {
+				t.Errorf("Expected cached=%v, got %v", tt.cached, cached)
+			}
+			want := uint64(0)
+			if !tt.cached {
+				want = 1
+			}
+			if count := middleware.GetMetrics().GetStats().Errors["response_incomplete"]; count != want {
+				t.Errorf("Expected %d response_incomplete errors, got %d", want, count)
+			}
+		})
+	}
+}
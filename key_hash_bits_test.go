@@ -0,0 +1,106 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestGenerateCacheKeyBits verifies key lengths for each hash size, and that
+// shorter keys are prefixes of the full hash rather than different hashes
+func TestGenerateCacheKeyBits(t *testing.T) {
+	headers := map[string]string{"Host": "example.com", "Accept": "application/json"}
+	full := GenerateCacheKeyBits("GET", "/items", "page=2", headers, FullKeyHashBits)
+	if len(full) != 64 {
+		t.Fatalf("Expected the full SHA-256 as 64 hex characters, got %d: %s", len(full), full)
+	}
+
+	tests := []struct {
+		bits   int
+		length int
+	}{
+		{0, 16},
+		{DefaultKeyHashBits, 16},
+		{128, 32},
+		{FullKeyHashBits, 64},
+		{100, 16}, // unsupported sizes fall back to the default
+	}
+	for _, tt := range tests {
+		key := GenerateCacheKeyBits("GET", "/items", "page=2", headers, tt.bits)
+		if len(key) != tt.length || !strings.HasPrefix(full, key) {
+			t.Errorf("%d bits: expected a %d character prefix of %s, got %s", tt.bits, tt.length, full, key)
+		}
+	}
+
+	if key := GenerateCacheKey("GET", "/items", "page=2", headers); key != full[:16] {
+		t.Errorf("Expected GenerateCacheKey to keep its 64-bit keys, got %s", key)
+	}
+}
+
+// TestMiddleware_KeyHashBits verifies that full-length keys are used
+// untruncated and still serve hits
+func TestMiddleware_KeyHashBits(t *testing.T) {
+	config := DefaultConfig()
+	config.KeyHashBits = FullKeyHashBits
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if status := resp.Header().Get("X-Cache-Status"); status != "HIT" {
+		t.Errorf("Expected a hit with full-length keys, got %q", status)
+	}
+
+	key := middleware.createCacheKey(req)
+	if len(key) != 64 {
+		t.Errorf("Expected a 64 character key, got %d: %s", len(key), key)
+	}
+	if _, found := middleware.cache.peek(key); !found {
+		t.Error("Expected the entry under the full-length key")
+	}
+}
+
+// TestCachingConnection_KeyHashBits verifies the transport key length
+func TestCachingConnection_KeyHashBits(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.KeyHashBits = 128
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	conn := newMockConn()
+	cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+	defer cc.Close()
+	conn.writeToReadBuffer([]byte("GET /items HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	cc.Read(make([]byte, 1024))
+
+	cc.stateMu.RLock()
+	key := cc.cacheKey
+	cc.stateMu.RUnlock()
+	if want := GenerateCacheKeyBits("GET", "/items", "", map[string]string{"Host": "example.com"}, 128); key != want || len(key) != 32 {
+		t.Errorf("Expected 128-bit key %s, got %s", want, key)
+	}
+}
+
+// TestValidateKeyHashBits verifies that only supported sizes are accepted
+func TestValidateKeyHashBits(t *testing.T) {
+	for bits, valid := range map[int]bool{0: true, 64: true, 128: true, 256: true, 32: false, 100: false, -1: false} {
+		cacheConfig := DefaultCacheConfig()
+		cacheConfig.KeyHashBits = bits
+		if err := cacheConfig.Validate(); (err == nil) != valid {
+			t.Errorf("CacheConfig.Validate() with %d bits: error = %v, want valid %v", bits, err, valid)
+		}
+
+		config := DefaultConfig()
+		config.KeyHashBits = bits
+		if err := config.Validate(); (err == nil) != valid {
+			t.Errorf("Config.Validate() with %d bits: error = %v, want valid %v", bits, err, valid)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_KeyPrefix(t *testing.T) {
+	newTenant := func(prefix string) *Middleware {
+		config := DefaultConfig()
+		config.KeyPrefix = prefix
+		return New(config)
+	}
+	tenantA := newTenant("tenant-a:")
+	defer tenantA.Close()
+	tenantB := newTenant("tenant-b:")
+	defer tenantB.Close()
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	keyA, keyB := tenantA.createCacheKey(req), tenantB.createCacheKey(req)
+	if !strings.HasPrefix(keyA, "tenant-a:") || !strings.HasPrefix(keyB, "tenant-b:") {
+		t.Fatalf("Expected keys in each tenant's namespace, got %q and %q", keyA, keyB)
+	}
+	if keyA == keyB {
+		t.Fatal("Expected different namespaces to produce different keys")
+	}
+
+	handler := tenantA.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := tenantA.ListEntries()
+	if len(entries) != 1 || entries[0].Key != keyA {
+		t.Fatalf("Expected one entry stored under %q, got %+v", keyA, entries)
+	}
+
+	tenantA.Delete("/api/users")
+	if items, _, _ := tenantA.Stats(); items != 0 {
+		t.Errorf("Expected Delete to remove the namespaced entry, got %d items", items)
+	}
+}
+
+func TestCachingConnection_KeyPrefix(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.KeyPrefix = "svc:"
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	conn := newMockConn()
+	cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+	defer cc.Close()
+
+	conn.writeToReadBuffer([]byte("GET /api/users HTTP/1.1\r\nHost: test\r\n\r\n"))
+	cc.Read(make([]byte, 1024))
+
+	cc.stateMu.RLock()
+	key := cc.cacheKey
+	cc.stateMu.RUnlock()
+
+	want := "svc:" + GenerateCacheKey("GET", "/api/users", "", map[string]string{"Host": "test"})
+	if key != want {
+		t.Errorf("Expected transport key %q, got %q", want, key)
+	}
+}
+
+// TestTTLCache_DeleteByPrefix verifies that prefix deletion is relative to
+// KeyPrefix and never reaches another namespace in the same store
+func TestTTLCache_DeleteByPrefix(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.KeyPrefix = "svc-a:"
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	var evicted []string
+	cache.config.OnEvict = func(key string, _ *CacheEntry) { evicted = append(evicted, key) }
+	for _, key := range []string{"svc-a:user:1", "svc-a:user:2", "svc-a:page:1", "svc-b:user:1"} {
+		cache.Set(key, []byte("data"), nil, time.Minute)
+	}
+
+	if deleted := cache.DeleteByPrefix("user:"); deleted != 2 || len(evicted) != 2 {
+		t.Errorf("Expected 2 user entries deleted and reported, got %d (%d reported)", deleted, len(evicted))
+	}
+	if cache.Has("svc-a:user:1") || !cache.Has("svc-a:page:1") {
+		t.Error("Expected only the matching entries to be removed")
+	}
+
+	if deleted := cache.DeleteByPrefix(""); deleted != 1 {
+		t.Errorf("Expected the rest of the namespace deleted, got %d", deleted)
+	}
+	if !cache.Has("svc-b:user:1") {
+		t.Error("Expected another namespace's entry to survive")
+	}
+}
+
+// TestMiddleware_DeleteByPrefix verifies that the middleware's cache deletes
+// within the middleware's KeyPrefix and keeps its variant index in step
+func TestMiddleware_DeleteByPrefix(t *testing.T) {
+	config := DefaultConfig()
+	config.KeyPrefix = "tenant-a:"
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	middleware.Cache().Set("tenant-b:shared", []byte("data"), nil, time.Minute)
+
+	if deleted := middleware.Cache().DeleteByPrefix(""); deleted != 1 {
+		t.Errorf("Expected the tenant's one entry deleted, got %d", deleted)
+	}
+	if entries := middleware.ListEntries(); len(entries) != 1 || entries[0].Key != "tenant-b:shared" {
+		t.Errorf("Expected only the other tenant's entry left, got %+v", entries)
+	}
+	if len(middleware.variantOf) != 0 {
+		t.Errorf("Expected the variant index emptied, got %v", middleware.variantOf)
+	}
+}
@@ -0,0 +1,82 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestLFUEvictionKeepsHotKeys verifies that frequently accessed keys survive
+// a burst of one-hit cold keys under the LFU policy
+func TestLFUEvictionKeepsHotKeys(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntries = 10
+	config.EvictionPolicy = EvictionPolicyLFU
+
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	hotKeys := []string{"hot-1", "hot-2", "hot-3"}
+	for _, key := range hotKeys {
+		cache.Set(key, []byte("hot"), headers, time.Hour)
+		for i := 0; i < 5; i++ {
+			cache.Get(key)
+		}
+	}
+
+	// Scan through many cold keys, each accessed once
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("cold-%d", i)
+		cache.Set(key, []byte("cold"), headers, time.Hour)
+		cache.Get(key)
+	}
+
+	for _, key := range hotKeys {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("Hot key %s should survive cold scan under LFU", key)
+		}
+	}
+	if size := cache.Size(); size > config.MaxEntries {
+		t.Errorf("Cache size %d exceeds MaxEntries %d", size, config.MaxEntries)
+	}
+}
+
+// TestLRUEvictionEvictsHotKeysDuringScan documents the LRU behavior that LFU avoids
+func TestLRUEvictionEvictsHotKeysDuringScan(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntries = 10
+
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	cache.Set("hot", []byte("hot"), headers, time.Hour)
+	for i := 0; i < 5; i++ {
+		cache.Get("hot")
+	}
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("cold-%d", i)
+		cache.Set(key, []byte("cold"), headers, time.Hour)
+		cache.Get(key)
+	}
+
+	if _, found := cache.Get("hot"); found {
+		t.Errorf("Hot key should be evicted by a cold scan under LRU")
+	}
+}
+
+func TestCacheConfig_ValidateEvictionPolicy(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.EvictionPolicy = "fifo"
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected error for unknown eviction policy")
+	}
+
+	config.EvictionPolicy = EvictionPolicyLFU
+	if err := config.Validate(); err != nil {
+		t.Errorf("Unexpected error for LFU policy: %v", err)
+	}
+}
@@ -1,10 +1,30 @@
 package selectcache
 
 import (
+	"context"
+	"encoding/json"
 	"net"
+	"net/http"
+	"strconv"
 	"sync"
+	"time"
 )
 
+// drainPollInterval is how often Shutdown checks for remaining connections
+const drainPollInterval = 10 * time.Millisecond
+
+// ErrTooManyConnections is returned by CachingListener.Accept when
+// MaxConnections is reached and RejectExcessConnections is set. It is a
+// temporary net.Error, so http.Server keeps serving after a short backoff.
+var ErrTooManyConnections net.Error = tooManyConnectionsError{}
+
+// tooManyConnectionsError is the type of ErrTooManyConnections
+type tooManyConnectionsError struct{}
+
+func (tooManyConnectionsError) Error() string   { return "too many connections" }
+func (tooManyConnectionsError) Timeout() bool   { return false }
+func (tooManyConnectionsError) Temporary() bool { return true }
+
 // CachingListener wraps a net.Listener to provide transparent caching of responses
 type CachingListener struct {
 	wrapped  net.Listener
@@ -16,6 +36,18 @@ type CachingListener struct {
 	// Connection tracking
 	activeConns sync.Map // map[string]*CachingConnection
 	connCounter uint64   // Atomic counter for connection IDs
+
+	// Connection slots when MaxConnections is set; nil means unlimited
+	slots chan struct{}
+
+	// Response analysis workers when AnalysisWorkers is set; nil analyzes inline
+	analysisPool *analysisPool
+
+	// Listener shutdown; the wrapped listener and cache are closed once, and
+	// done is closed to release Accept calls waiting for a slot
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{}
 }
 
 // NewCachingListener creates a new caching listener that wraps the provided listener
@@ -32,24 +64,60 @@ func NewCachingListener(listener net.Listener, config *CacheConfig) *CachingList
 	cache := NewTTLCache(config, metrics)
 	detector := NewContentDetector(config)
 
-	return &CachingListener{
+	cl := &CachingListener{
 		wrapped:  listener,
 		cache:    cache,
 		config:   config,
 		metrics:  metrics,
 		detector: detector,
+		done:     make(chan struct{}),
 	}
+	if config.MaxConnections > 0 {
+		cl.slots = make(chan struct{}, config.MaxConnections)
+	}
+	if config.AnalysisWorkers > 0 {
+		cl.analysisPool = newAnalysisPool(config.AnalysisWorkers)
+	}
+	return cl
 }
 
-// Accept waits for and returns the next connection to the listener
+// Accept waits for and returns the next connection to the listener. With
+// MaxConnections set it first waits for a free slot, or, with
+// RejectExcessConnections, closes excess connections and returns
+// ErrTooManyConnections.
 func (cl *CachingListener) Accept() (net.Conn, error) {
+	reject := cl.config.RejectExcessConnections
+	if cl.slots != nil && !reject {
+		select {
+		case cl.slots <- struct{}{}:
+		case <-cl.done:
+			return nil, net.ErrClosed
+		}
+	}
+
 	conn, err := cl.wrapped.Accept()
 	if err != nil {
+		if cl.slots != nil && !reject {
+			<-cl.slots
+		}
 		return nil, err
 	}
 
+	if cl.slots != nil && reject {
+		select {
+		case cl.slots <- struct{}{}:
+		default:
+			conn.Close()
+			if cl.metrics != nil {
+				cl.metrics.RecordError("connection_rejected")
+			}
+			return nil, ErrTooManyConnections
+		}
+	}
+
 	// Wrap the connection with caching capabilities
 	cachingConn := NewCachingConnection(conn, cl.cache, cl.config, cl.metrics, cl.detector)
+	cachingConn.analysisPool = cl.analysisPool
 
 	// Track the connection
 	connID := cachingConn.ID()
@@ -58,18 +126,80 @@ func (cl *CachingListener) Accept() (net.Conn, error) {
 	// Set up cleanup callback for when connection closes
 	cachingConn.SetCloseCallback(func() {
 		cl.activeConns.Delete(connID)
+		if cl.slots != nil {
+			<-cl.slots
+		}
 	})
 
 	return cachingConn, nil
 }
 
-// Close closes the listener and all active connections
+// Close closes the listener and all active connections. In-flight
+// connections are given up to CacheConfig.DrainTimeout to finish before they
+// are force-closed.
 func (cl *CachingListener) Close() error {
-	// Close cache resources first
+	ctx, cancel := context.WithTimeout(context.Background(), cl.config.DrainTimeout)
+	defer cancel()
+
+	if err := cl.Shutdown(ctx); err != nil && err != context.DeadlineExceeded {
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully shuts down the listener, mirroring http.Server.Shutdown:
+// it stops accepting new connections, then waits for active connections to
+// close until ctx is done, at which point the remaining connections are
+// force-closed and ctx's error is returned.
+func (cl *CachingListener) Shutdown(ctx context.Context) error {
+	cl.closeOnce.Do(func() {
+		close(cl.done)
+		cl.closeErr = cl.wrapped.Close()
+	})
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	var err error
+	for cl.activeConnectionCount() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			cl.closeActiveConnections()
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	if cl.analysisPool != nil {
+		cl.analysisPool.stop()
+	}
 	cl.cache.Close()
+	if err != nil {
+		return err
+	}
+	return cl.closeErr
+}
 
-	// Close wrapped listener
-	return cl.wrapped.Close()
+// activeConnectionCount returns the number of tracked live connections
+func (cl *CachingListener) activeConnectionCount() int {
+	count := 0
+	cl.activeConns.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// closeActiveConnections force-closes every tracked connection
+func (cl *CachingListener) closeActiveConnections() {
+	cl.activeConns.Range(func(key, value interface{}) bool {
+		if conn, ok := value.(*CachingConnection); ok {
+			conn.Close()
+		}
+		return true
+	})
 }
 
 // Addr returns the listener's network address
@@ -96,28 +226,44 @@ func (cl *CachingListener) GetConfig() *CacheConfig {
 func (cl *CachingListener) GetStats() ListenerStats {
 	cacheStats := cl.metrics.GetStats()
 
-	// Count active connections
-	activeConnCount := 0
-	cl.activeConns.Range(func(key, value interface{}) bool {
-		activeConnCount++
-		return true
-	})
-
 	return ListenerStats{
 		CacheStats:        cacheStats,
-		ActiveConnections: activeConnCount,
+		ActiveConnections: cl.activeConnectionCount(),
 		CacheSize:         cl.cache.Size(),
 		CacheMemoryUsage:  cl.cache.MemoryUsage(),
 		ListenerAddress:   cl.wrapped.Addr().String(),
+		StoreCircuit:      cl.cache.StoreCircuitState(),
 	}
 }
 
+// ConnectionSnapshots returns statistics for every live connection.
+// Connections that close while the snapshot is taken may still be included
+// with Closed set.
+func (cl *CachingListener) ConnectionSnapshots() []ConnectionStats {
+	var snapshots []ConnectionStats
+	cl.activeConns.Range(func(key, value interface{}) bool {
+		if conn, ok := value.(*CachingConnection); ok {
+			snapshots = append(snapshots, conn.GetStats())
+		}
+		return true
+	})
+	return snapshots
+}
+
 // ClearCache removes all cached entries
 func (cl *CachingListener) ClearCache() {
 	cl.cache.Clear()
 }
 
-// UpdateConfig updates the cache configuration (note: some changes require restart)
+// UpdateConfig updates the cache configuration. Changes to MaxMemoryMB and
+// MaxEntries apply to the live cache at once, evicting entries if the new
+// limits are lower. Settings read per connection, such as content type rules,
+// TTLs and response analysis limits, apply to connections accepted afterwards.
+// Everything else about the cache and listener (ShardCount, EvictionPolicy,
+// SegmentQuotas, CleanupInterval, DisableAutoCleanup, header filtering, store
+// circuit settings, MaxConnections, AnalysisWorkers and EnableMetrics) is
+// fixed when the listener is created and needs a restart to change. Metrics
+// are kept; call ResetMetrics to start them afresh.
 func (cl *CachingListener) UpdateConfig(newConfig *CacheConfig) error {
 	if err := newConfig.Validate(); err != nil {
 		return err
@@ -125,6 +271,7 @@ func (cl *CachingListener) UpdateConfig(newConfig *CacheConfig) error {
 
 	// Update configuration
 	cl.config = newConfig
+	cl.cache.SetLimits(newConfig.MaxMemoryMB, newConfig.MaxEntries)
 
 	// Update detector with new config
 	cl.detector = NewContentDetector(newConfig)
@@ -132,11 +279,37 @@ func (cl *CachingListener) UpdateConfig(newConfig *CacheConfig) error {
 	return nil
 }
 
+// ResetMetrics clears the collected metrics, keeping the memory gauges, which
+// reflect the cache's current state rather than its history
+func (cl *CachingListener) ResetMetrics() {
+	cl.metrics.Reset()
+	cl.metrics.UpdateMemoryUsage(cl.cache.MemoryUsage(), cl.cache.Size())
+}
+
+// MetricsHandler returns an http.Handler that serves GetStats() as JSON.
+// Passing ?reset=true resets the collected metrics after the snapshot is taken.
+func (cl *CachingListener) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := cl.GetStats()
+
+		if reset, _ := strconv.ParseBool(r.URL.Query().Get("reset")); reset {
+			cl.ResetMetrics()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			http.Error(w, "failed to encode metrics", http.StatusInternalServerError)
+		}
+	})
+}
+
 // ListenerStats contains comprehensive statistics about the caching listener
 type ListenerStats struct {
-	CacheStats        CacheStats `json:"cache_stats"`
-	ActiveConnections int        `json:"active_connections"`
-	CacheSize         int        `json:"cache_size"`
-	CacheMemoryUsage  uint64     `json:"cache_memory_usage"`
-	ListenerAddress   string     `json:"listener_address"`
+	CacheStats        CacheStats   `json:"cache_stats"`
+	ActiveConnections int          `json:"active_connections"`
+	CacheSize         int          `json:"cache_size"`
+	CacheMemoryUsage  uint64       `json:"cache_memory_usage"`
+	ListenerAddress   string       `json:"listener_address"`
+	StoreCircuit      CircuitState `json:"store_circuit"`
 }
@@ -0,0 +1,118 @@
+package selectcache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func newShutdownListener(t *testing.T, drain time.Duration) *CachingListener {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	config := DefaultCacheConfig()
+	config.DrainTimeout = drain
+	return NewCachingListener(inner, config)
+}
+
+// acceptOne dials the listener and returns the accepted server side and the
+// client side of the connection
+func acceptOne(t *testing.T, cl *CachingListener) (net.Conn, net.Conn) {
+	t.Helper()
+	client, err := net.Dial("tcp", cl.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	server, err := cl.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	return server, client
+}
+
+func TestCachingListener_CloseForceClosesActiveConnections(t *testing.T) {
+	cl := newShutdownListener(t, 0)
+	_, client := acceptOne(t, cl)
+
+	if err := cl.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if n := cl.GetStats().ActiveConnections; n != 0 {
+		t.Errorf("Expected no active connections after Close, got %d", n)
+	}
+
+	// The client should observe the server side going away
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected client read to fail after listener Close")
+	}
+}
+
+func TestCachingListener_ShutdownWaitsForConnections(t *testing.T) {
+	cl := newShutdownListener(t, 0)
+	server, _ := acceptOne(t, cl)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := cl.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Shutdown returned after %v, before the connection closed", elapsed)
+	}
+
+	if _, err := cl.Accept(); err == nil {
+		t.Error("Expected Accept to fail after Shutdown")
+	}
+}
+
+func TestCachingListener_ShutdownForceClosesOnDeadline(t *testing.T) {
+	cl := newShutdownListener(t, 0)
+	acceptOne(t, cl)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := cl.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+	if n := cl.GetStats().ActiveConnections; n != 0 {
+		t.Errorf("Expected remaining connections to be force-closed, got %d", n)
+	}
+}
+
+func TestCachingListener_CloseDrainsWithinTimeout(t *testing.T) {
+	cl := newShutdownListener(t, time.Second)
+	server, _ := acceptOne(t, cl)
+
+	closed := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.Close()
+		close(closed)
+	}()
+
+	if err := cl.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	select {
+	case <-closed:
+	default:
+		t.Error("Close returned before the in-flight connection finished")
+	}
+}
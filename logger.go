@@ -0,0 +1,17 @@
+package selectcache
+
+// Logger receives structured cache events for debugging and observability.
+// Implementations can forward events to slog, zap, or any other logger.
+// Methods are always invoked outside of cache locks.
+type Logger interface {
+	// OnHit is called when a request is served from the cache
+	OnHit(key, path string)
+	// OnMiss is called when a lookup finds no usable cache entry
+	OnMiss(key, path string)
+	// OnStore is called after a response is stored in the cache
+	OnStore(key string, size int)
+	// OnEvict is called when an entry is removed by eviction, expiry, or deletion
+	OnEvict(key string)
+	// OnError is called when a cache operation fails
+	OnError(op string, err error)
+}
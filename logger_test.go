@@ -0,0 +1,91 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger captures logger events for assertions
+type recordingLogger struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *recordingLogger) record(event string) {
+	l.mu.Lock()
+	l.events = append(l.events, event)
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) OnHit(key, path string)       { l.record("hit:" + path) }
+func (l *recordingLogger) OnMiss(key, path string)      { l.record("miss:" + path) }
+func (l *recordingLogger) OnStore(key string, size int) { l.record("store") }
+func (l *recordingLogger) OnEvict(key string)           { l.record("evict:" + key) }
+func (l *recordingLogger) OnError(op string, err error) { l.record("error:" + op) }
+
+func (l *recordingLogger) count(event string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, e := range l.events {
+		if e == event {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMiddlewareLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	config := DefaultConfig()
+	config.Logger = logger
+	middleware := New(config)
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+
+	if logger.count("miss:/api") != 1 {
+		t.Errorf("Expected one miss event, got events %v", logger.events)
+	}
+	if logger.count("store") != 1 {
+		t.Errorf("Expected one store event, got events %v", logger.events)
+	}
+	if logger.count("hit:/api") != 1 {
+		t.Errorf("Expected one hit event, got events %v", logger.events)
+	}
+}
+
+func TestTTLCacheLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	config := DefaultCacheConfig()
+	config.MaxEntries = 1
+	config.Logger = logger
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	cache.Set("first", []byte("a"), headers, time.Hour)
+	cache.Get("first")
+	cache.Get("missing")
+
+	// Exceeding MaxEntries evicts the first entry
+	cache.Set("second", []byte("b"), headers, time.Hour)
+
+	if logger.count("store") != 2 {
+		t.Errorf("Expected two store events, got events %v", logger.events)
+	}
+	if logger.count("hit:") != 1 || logger.count("miss:") != 1 {
+		t.Errorf("Expected one hit and one miss event, got events %v", logger.events)
+	}
+	if logger.count("evict:first") != 1 {
+		t.Errorf("Expected eviction of first entry, got events %v", logger.events)
+	}
+}
@@ -0,0 +1,96 @@
+package selectcache
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestTTLCache_DisableAutoCleanup verifies that no cleanup goroutine is
+// started and that CleanupNow reaps on demand
+func TestTTLCache_DisableAutoCleanup(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.Clock = clock
+	config.CleanupInterval = time.Millisecond
+	config.DisableAutoCleanup = true
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	if got := runtime.NumGoroutine(); got > baseline {
+		t.Errorf("Expected no cleanup goroutine, goroutines went from %d to %d", baseline, got)
+	}
+
+	cache.Set("short", []byte("data"), make(http.Header), time.Minute)
+	cache.Set("long", []byte("data"), make(http.Header), time.Hour)
+	clock.Advance(2 * time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if size := cache.Size(); size != 2 {
+		t.Fatalf("Expected expired entries to stay until CleanupNow, got %d entries", size)
+	}
+
+	if removed := cache.CleanupNow(); removed != 1 {
+		t.Errorf("Expected CleanupNow to remove 1 entry, got %d", removed)
+	}
+	if cache.Has("short") || !cache.Has("long") {
+		t.Error("Expected only the expired entry to be removed")
+	}
+	if removed := cache.CleanupNow(); removed != 0 {
+		t.Errorf("Expected nothing left to remove, got %d", removed)
+	}
+}
+
+// TestTTLCache_DisableAutoCleanupScheduler verifies that a disabled cache
+// isn't registered with a CleanupScheduler
+func TestTTLCache_DisableAutoCleanupScheduler(t *testing.T) {
+	scheduler := NewCleanupScheduler(time.Minute)
+	defer scheduler.Stop()
+	config := DefaultCacheConfig()
+	config.CleanupScheduler = scheduler
+	config.DisableAutoCleanup = true
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	if scheduler.Len() != 0 {
+		t.Errorf("Expected no registered caches, got %d", scheduler.Len())
+	}
+}
+
+// TestTTLCache_CleanupNowTrims verifies that CleanupNow counts entries
+// trimmed to the soft memory threshold
+func TestTTLCache_CleanupNowTrims(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.DisableAutoCleanup = true
+	config.MaxMemoryMB = 1
+	config.SoftMemoryThresholdPct = 50
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	body := make([]byte, 200*1024)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Set(key, body, make(http.Header), time.Hour)
+	}
+	if removed := cache.CleanupNow(); removed != 2 {
+		t.Errorf("Expected 2 entries trimmed to the soft threshold, got %d", removed)
+	}
+}
+
+// TestMiddleware_DisableAutoCleanup verifies the middleware passes the
+// setting to its cache
+func TestMiddleware_DisableAutoCleanup(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultConfig()
+	config.DisableAutoCleanup = true
+	middleware := New(config)
+	defer middleware.Close()
+	middleware.cache.clock = clock
+
+	middleware.Cache().Set("key", []byte("data"), make(http.Header), time.Minute)
+	clock.Advance(2 * time.Minute)
+	if removed := middleware.Cache().CleanupNow(); removed != 1 {
+		t.Errorf("Expected CleanupNow to remove 1 entry, got %d", removed)
+	}
+}
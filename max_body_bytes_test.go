@@ -0,0 +1,99 @@
+package selectcache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMaxBodyBytesSkipsLargeResponses verifies that responses larger than
+// MaxBodyBytes are streamed to the client in full but not cached
+func TestMaxBodyBytesSkipsLargeResponses(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBodyBytes = 1024
+	middleware := New(config)
+
+	large := bytes.Repeat([]byte("x"), 4096)
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.URL.Path == "/large" {
+			// Write in chunks to exercise incremental overflow detection
+			for i := 0; i < len(large); i += 512 {
+				w.Write(large[i : i+512])
+			}
+			return
+		}
+		w.Write([]byte("small"))
+	}))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/large", nil))
+	if !bytes.Equal(resp.Body.Bytes(), large) {
+		t.Fatalf("Expected full body to be streamed to client, got %d bytes", resp.Body.Len())
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/small", nil))
+
+	itemCount, _, _ := middleware.Stats()
+	if itemCount != 1 {
+		t.Errorf("Expected only the small response to be cached, got %d items", itemCount)
+	}
+}
+
+// TestResponseRecorderOverflow verifies the recorder drops its buffer on overflow
+func TestResponseRecorderOverflow(t *testing.T) {
+	recorder := NewResponseRecorderWithLimit(httptest.NewRecorder(), "GET", 10)
+	recorder.Write([]byte("12345"))
+	if recorder.Overflowed() {
+		t.Fatalf("Recorder should not overflow below the limit")
+	}
+
+	recorder.Write([]byte("678901"))
+	if !recorder.Overflowed() {
+		t.Fatalf("Recorder should overflow above the limit")
+	}
+	if recorder.Size() != 0 {
+		t.Errorf("Expected buffer to be released on overflow, got %d bytes", recorder.Size())
+	}
+}
+
+// TestMaxBodyBytesOnRevalidation verifies that a stale entry revalidated with
+// a body over MaxBodyBytes isn't replaced by it, while the client still gets
+// the whole body
+func TestMaxBodyBytesOnRevalidation(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBodyBytes = 1024
+	middleware := New(config)
+	defer middleware.Close()
+
+	large := bytes.Repeat([]byte("x"), 4096)
+	body := []byte("small")
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Cache-Control", "max-age=60, stale-if-error=300")
+		w.Write(body)
+	}))
+
+	req := httptest.NewRequest("GET", "/growing", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	key := middleware.createCacheKey(req)
+	cached, found := middleware.GetCacheForTesting().Get(key)
+	if !found {
+		t.Fatal("Expected the small response to be cached")
+	}
+	cached.FreshUntil = time.Now().Add(-time.Second)
+
+	body = large
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if !bytes.Equal(resp.Body.Bytes(), large) {
+		t.Fatalf("Expected the full revalidated body, got %d bytes", resp.Body.Len())
+	}
+
+	stored, found := middleware.GetCacheForTesting().Get(key)
+	if found && len(stored.Data) > int(config.MaxBodyBytes) {
+		t.Errorf("Expected the oversized body not to be stored, got %d bytes", len(stored.Data))
+	}
+}
@@ -0,0 +1,68 @@
+package selectcache
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_SetRejectsOversizedEntry(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntrySizeBytes = 1024
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	if err := cache.Set("small", []byte("ok"), http.Header{}, time.Minute); err != nil {
+		t.Fatalf("Unexpected error storing small entry: %v", err)
+	}
+
+	err := cache.Set("large", bytes.Repeat([]byte("x"), 2048), http.Header{}, time.Minute)
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("Expected ErrEntryTooLarge, got %v", err)
+	}
+	if _, found := cache.Get("large"); found {
+		t.Error("Expected oversized entry not to be stored")
+	}
+	if _, found := cache.Get("small"); !found {
+		t.Error("Expected existing entries not to be evicted for an oversized entry")
+	}
+
+	stats := metrics.GetStats()
+	if stats.Errors["entry_too_large"] != 1 {
+		t.Errorf("Expected entry_too_large to be recorded once, got %d", stats.Errors["entry_too_large"])
+	}
+	if stats.Evictions != 0 {
+		t.Errorf("Expected no evictions, got %d", stats.Evictions)
+	}
+}
+
+func TestCacheConfig_MaxCacheableSizeWithEntryLimit(t *testing.T) {
+	config := DefaultCacheConfig()
+	derived := config.MaxMemoryMB * 1024 * 1024 / 10
+
+	config.MaxEntrySizeBytes = 4096
+	if got := config.MaxCacheableSize(); got != 4096 {
+		t.Errorf("MaxCacheableSize() = %d, want 4096", got)
+	}
+
+	config.MaxEntrySizeBytes = derived * 2
+	if got := config.MaxCacheableSize(); got != derived*2 {
+		t.Errorf("MaxEntrySizeBytes should replace the derived default, got %d", got)
+	}
+
+	config.MaxResponseSize = 1024
+	if got := config.MaxCacheableSize(); got != 1024 {
+		t.Errorf("Expected the smaller explicit limit, got %d", got)
+	}
+
+	detector := NewContentDetector(config)
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	config.MaxResponseSize = 0
+	config.MaxEntrySizeBytes = 100
+	if detector.ShouldCache(bytes.Repeat([]byte("x"), 200), headers, http.StatusOK) {
+		t.Error("Expected detector to reject bodies above MaxEntrySizeBytes")
+	}
+}
@@ -0,0 +1,48 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMiddleware_MaxServeAge verifies that an entry with a long TTL is
+// refused once it is older than MaxServeAge
+func TestMiddleware_MaxServeAge(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultConfig()
+	config.DefaultTTL = time.Hour
+	config.MaxServeAge = 5 * time.Minute
+	middleware := New(config)
+	defer middleware.Close()
+	middleware.cache.clock = clock
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"price": 42}`))
+	}))
+	get := func() string {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/price", nil))
+		return resp.Header().Get("X-Cache-Status")
+	}
+
+	get()
+	clock.Advance(4 * time.Minute)
+	if status := get(); status != "HIT" {
+		t.Errorf("Expected a hit within MaxServeAge, got %q", status)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if status := get(); status != "MISS" || calls != 2 {
+		t.Errorf("Expected a miss past MaxServeAge, got %q after %d origin calls", status, calls)
+	}
+
+	// The refetched entry is served again
+	if status := get(); status != "HIT" {
+		t.Errorf("Expected the refreshed entry to be served, got %q", status)
+	}
+}
@@ -0,0 +1,62 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_PerMethodMetrics(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	serve := func(method string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/page", nil))
+	}
+
+	serve("GET")
+	serve("GET")
+	serve("GET")
+	serve("HEAD")
+
+	stats := middleware.GetMetrics().GetStats()
+	if got := stats.PerMethod["GET"]; got.Hits != 2 || got.Misses != 1 {
+		t.Errorf("Expected 2 GET hits and 1 miss, got %+v", got)
+	}
+	if got := stats.PerMethod["HEAD"]; got.Hits+got.Misses != 1 {
+		t.Errorf("Expected one HEAD lookup, got %+v", got)
+	}
+	if _, ok := stats.PerMethod["POST"]; ok {
+		t.Error("Uncacheable methods should not be counted")
+	}
+}
+
+func TestCacheMetrics_PerMethodReset(t *testing.T) {
+	metrics := NewCacheMetrics(true)
+	metrics.RecordHitMethod("GET")
+	metrics.RecordMissMethod("GET")
+	metrics.RecordHit()
+
+	stats := metrics.GetStats()
+	if stats.Hits != 1 {
+		t.Errorf("Per-method counters should not change the total, got %d hits", stats.Hits)
+	}
+	if got := stats.PerMethod["GET"]; got.Hits != 1 || got.Misses != 1 {
+		t.Errorf("Unexpected GET stats: %+v", got)
+	}
+
+	// The returned map is a copy
+	stats.PerMethod["GET"] = MethodStats{}
+	if metrics.GetStats().PerMethod["GET"].Hits != 1 {
+		t.Error("GetStats should return a copy of the per-method map")
+	}
+
+	metrics.Reset()
+	if len(metrics.GetStats().PerMethod) != 0 {
+		t.Error("Reset should clear per-method counters")
+	}
+}
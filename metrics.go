@@ -29,14 +29,24 @@ type CacheMetrics struct {
 	// Error tracking
 	errors map[string]uint64
 
+	// Hits and misses by request method
+	perMethod map[string]MethodStats
+
 	enabled bool
 }
 
+// MethodStats counts cache hits and misses for one request method
+type MethodStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
 // NewCacheMetrics creates a new metrics collector
 func NewCacheMetrics(enabled bool) *CacheMetrics {
 	return &CacheMetrics{
-		errors:  make(map[string]uint64),
-		enabled: enabled,
+		errors:    make(map[string]uint64),
+		perMethod: make(map[string]MethodStats),
+		enabled:   enabled,
 	}
 }
 
@@ -60,6 +70,32 @@ func (m *CacheMetrics) RecordMiss() {
 	m.mu.Unlock()
 }
 
+// RecordHitMethod counts a cache hit for a request method. It only feeds
+// CacheStats.PerMethod; the total is recorded separately by RecordHit.
+func (m *CacheMetrics) RecordHitMethod(method string) {
+	if !m.enabled {
+		return
+	}
+	m.mu.Lock()
+	stats := m.perMethod[method]
+	stats.Hits++
+	m.perMethod[method] = stats
+	m.mu.Unlock()
+}
+
+// RecordMissMethod counts a cache miss for a request method. It only feeds
+// CacheStats.PerMethod; the total is recorded separately by RecordMiss.
+func (m *CacheMetrics) RecordMissMethod(method string) {
+	if !m.enabled {
+		return
+	}
+	m.mu.Lock()
+	stats := m.perMethod[method]
+	stats.Misses++
+	m.perMethod[method] = stats
+	m.mu.Unlock()
+}
+
 // RecordStore increments the cache store counter
 func (m *CacheMetrics) RecordStore() {
 	if !m.enabled {
@@ -154,13 +190,17 @@ type CacheStats struct {
 
 	// Error counts
 	Errors map[string]uint64 `json:"errors"`
+
+	// Hits and misses by request method
+	PerMethod map[string]MethodStats `json:"per_method"`
 }
 
 // GetStats returns a snapshot of current metrics
 func (m *CacheMetrics) GetStats() CacheStats {
 	if !m.enabled {
 		return CacheStats{
-			Errors: make(map[string]uint64),
+			Errors:    make(map[string]uint64),
+			PerMethod: make(map[string]MethodStats),
 		}
 	}
 
@@ -176,6 +216,7 @@ func (m *CacheMetrics) GetStats() CacheStats {
 		TotalMemoryBytes: m.totalMemoryBytes,
 		EntryCount:       m.entryCount,
 		Errors:           make(map[string]uint64),
+		PerMethod:        make(map[string]MethodStats, len(m.perMethod)),
 	}
 
 	// Calculate hit ratio
@@ -203,6 +244,9 @@ func (m *CacheMetrics) GetStats() CacheStats {
 	for k, v := range m.errors {
 		stats.Errors[k] = v
 	}
+	for k, v := range m.perMethod {
+		stats.PerMethod[k] = v
+	}
 
 	return stats
 }
@@ -228,6 +272,7 @@ func (m *CacheMetrics) Reset() {
 	m.lookupCount = 0
 	m.storeCount = 0
 	m.errors = make(map[string]uint64)
+	m.perMethod = make(map[string]MethodStats)
 }
 
 // IsEnabled returns whether metrics collection is enabled
@@ -0,0 +1,57 @@
+package selectcache
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachingListener_MetricsHandler(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	cachingListener.GetCache().Set("key", []byte("data"), make(http.Header), time.Minute)
+	cachingListener.GetCache().Get("key")
+
+	handler := cachingListener.MetricsHandler()
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/cache/metrics", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.Code)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", ct)
+	}
+
+	var stats ListenerStats
+	if err := json.Unmarshal(resp.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.CacheStats.Hits != 1 || stats.CacheSize != 1 {
+		t.Errorf("Unexpected stats: hits=%d size=%d", stats.CacheStats.Hits, stats.CacheSize)
+	}
+
+	// Reset returns the pre-reset snapshot and clears counters afterwards
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/cache/metrics?reset=true", nil))
+	json.Unmarshal(resp.Body.Bytes(), &stats)
+	if stats.CacheStats.Hits != 1 {
+		t.Errorf("Expected reset response to include pre-reset hits, got %d", stats.CacheStats.Hits)
+	}
+
+	after := cachingListener.GetMetrics().GetStats()
+	if after.Hits != 0 {
+		t.Errorf("Expected hits to be reset, got %d", after.Hits)
+	}
+	if after.EntryCount != 1 {
+		t.Errorf("Expected entry count gauge to survive reset, got %d", after.EntryCount)
+	}
+}
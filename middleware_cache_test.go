@@ -0,0 +1,68 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newHeaderTestHandler wraps, in a middleware built from config, an origin
+// that answers every request with body and the given response headers. The
+// middleware is closed when the test ends.
+func newHeaderTestHandler(t testing.TB, config Config, headers http.Header, body string) http.Handler {
+	middleware := New(config)
+	t.Cleanup(middleware.Close)
+	return middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range headers {
+			w.Header()[name] = values
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+// serveWithHeader sends handler a GET for path, setting the request header
+// name to value unless value is empty
+func serveWithHeader(handler http.Handler, path, name, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if value != "" {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_CacheAccessor(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	req := httptest.NewRequest("GET", "/api", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	cache := middleware.Cache()
+	if cache.Size() != 1 {
+		t.Fatalf("Expected the cached response to be visible through Cache, got %d entries", cache.Size())
+	}
+
+	// Deleting through the cache also drops the entry from the variant index
+	key := middleware.createCacheKey(req)
+	if !cache.Delete(key) {
+		t.Fatal("Expected Delete to find the middleware's entry")
+	}
+	middleware.variantsMu.Lock()
+	_, indexed := middleware.variantOf[key]
+	middleware.variantsMu.Unlock()
+	if indexed {
+		t.Error("Expected the deleted key to be removed from the variant index")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("X-Cache-Status") == "HIT" {
+		t.Error("Expected a miss after deleting through the cache")
+	}
+}
@@ -0,0 +1,54 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMiddleware_MaxEntriesEvictsLRU verifies the middleware inherits the
+// TTLCache entry limit and least recently used eviction
+func TestMiddleware_MaxEntriesEvictsLRU(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxEntries = 2
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	serve := func(path string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Header().Get("X-Cache-Status")
+	}
+
+	serve("/a")
+	serve("/b")
+	serve("/a") // /b is now least recently used
+	serve("/c")
+
+	if items, _, _ := middleware.Stats(); items != 2 {
+		t.Errorf("Expected the entry limit to hold 2 items, got %d", items)
+	}
+	if status := serve("/a"); status != "HIT" {
+		t.Errorf("Expected recently used /a to survive eviction, got %q", status)
+	}
+	if status := serve("/b"); status == "HIT" {
+		t.Error("Expected least recently used /b to be evicted")
+	}
+
+	// Evicted entries leave the variant index
+	middleware.variantsMu.Lock()
+	indexed := len(middleware.variantOf)
+	middleware.variantsMu.Unlock()
+	if indexed != 2 {
+		t.Errorf("Expected 2 indexed variants after eviction, got %d", indexed)
+	}
+
+	stats := middleware.GetMetrics().GetStats()
+	if stats.Evictions == 0 || stats.Hits == 0 {
+		t.Errorf("Expected evictions and hits in the unified metrics, got %+v", stats)
+	}
+}
@@ -0,0 +1,62 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_DetailedStatsTracksMemory(t *testing.T) {
+	middleware := New(DefaultConfig())
+
+	bodies := map[string]string{
+		"/a": strings.Repeat("a", 100),
+		"/b": strings.Repeat("b", 300),
+	}
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	serve := func(path string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	serve("/a")
+	serve("/b")
+	serve("/a")
+
+	stats := middleware.DetailedStats()
+	if stats.Items != 2 || stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	if stats.HitRatio < 0.33 || stats.HitRatio > 0.34 {
+		t.Errorf("Expected hit ratio of 1/3, got %v", stats.HitRatio)
+	}
+
+	// Body plus the Content-Type header, which is all the handler set
+	header := len("Content-Type") + len("text/plain")
+	if want := int64(100 + 300 + 2*header); stats.MemoryBytes != want {
+		t.Errorf("Expected %d bytes of memory, got %d", want, stats.MemoryBytes)
+	}
+
+	// Overwriting an entry replaces its size rather than adding to it
+	req := httptest.NewRequest("GET", "/b", nil)
+	recorder := NewResponseRecorder(httptest.NewRecorder(), req.Method)
+	recorder.Header().Set("Content-Type", "text/plain")
+	recorder.Write([]byte(strings.Repeat("b", 50)))
+	middleware.storeResponseIfCacheable(middleware.createCacheKey(req), req, recorder)
+	if want := int64(100 + 50 + 2*header); middleware.DetailedStats().MemoryBytes != want {
+		t.Errorf("Expected %d bytes after replacing /b, got %d", want, middleware.DetailedStats().MemoryBytes)
+	}
+
+	middleware.Delete("/a")
+	if want := int64(50 + header); middleware.DetailedStats().MemoryBytes != want {
+		t.Errorf("Expected %d bytes after delete, got %d", want, middleware.DetailedStats().MemoryBytes)
+	}
+
+	middleware.Clear()
+	if memory := middleware.DetailedStats().MemoryBytes; memory != 0 {
+		t.Errorf("Expected no memory after Clear, got %d", memory)
+	}
+}
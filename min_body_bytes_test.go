@@ -0,0 +1,100 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMinBodyBytesSkipsTinyResponses verifies that responses below
+// MinBodyBytes are served but deliberately not cached
+func TestMinBodyBytesSkipsTinyResponses(t *testing.T) {
+	config := DefaultConfig()
+	config.MinBodyBytes = 16
+	middleware := New(config)
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/tiny" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"items":[1,2,3,4,5]}`))
+	}))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/tiny", nil))
+	if resp.Body.String() != `{}` {
+		t.Fatalf("Expected tiny body to reach the client, got %q", resp.Body.String())
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/list", nil))
+
+	itemCount, _, _ := middleware.Stats()
+	if itemCount != 1 {
+		t.Errorf("Expected only the larger response to be cached, got %d items", itemCount)
+	}
+}
+
+// TestContentDetector_MinBodyBytes verifies the transport detector applies the
+// same threshold
+func TestContentDetector_MinBodyBytes(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MinBodyBytes = 16
+	detector := NewContentDetector(config)
+
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	if detector.ShouldCache([]byte(`{}`), headers, 200) {
+		t.Error("Expected body below MinBodyBytes not to be cached")
+	}
+	if !detector.ShouldCache([]byte(`{"items":[1,2,3,4,5]}`), headers, 200) {
+		t.Error("Expected body at or above MinBodyBytes to be cached")
+	}
+
+	config.MinBodyBytes = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected negative MinBodyBytes to fail validation")
+	}
+}
+
+// TestContentDetector_MinBodyBytesWithoutBody verifies that responses with no
+// body to measure, such as the probe behind WouldCacheContentType and 304s,
+// aren't rejected as too small
+func TestContentDetector_MinBodyBytesWithoutBody(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MinBodyBytes = 16
+	detector := NewContentDetector(config)
+
+	if cacheable, ttl := config.WouldCacheContentType("application/json", 200); !cacheable || ttl == 0 {
+		t.Errorf("Expected WouldCacheContentType to ignore MinBodyBytes, got cacheable=%v ttl=%v", cacheable, ttl)
+	}
+
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	if !detector.ShouldCache(nil, headers, 200) {
+		t.Error("Expected an unknown body not to be judged by size")
+	}
+	if !detector.ShouldCache([]byte{}, headers, http.StatusNotModified) {
+		t.Error("Expected a bodiless 304 not to be judged by size")
+	}
+}
+
+// TestCachingConnection_MinBodyBytesHEAD verifies that the transport doesn't
+// reject a bodiless response to HEAD as too small
+func TestCachingConnection_MinBodyBytesHEAD(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MinBodyBytes = 16
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+	conn, mockConn := newPooledConnection(config, cache, nil, nil)
+	defer conn.Close()
+
+	mockConn.writeToReadBuffer([]byte("HEAD /items HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	conn.Read(make([]byte, 1024))
+	conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 0\r\n\r\n"))
+
+	key := GenerateCacheKey("GET", "/items", "", map[string]string{"Host": "example.com"})
+	if _, found := waitForEntry(cache, key, 100*time.Millisecond); !found {
+		t.Error("Expected the HEAD response to be cached despite MinBodyBytes")
+	}
+}
@@ -0,0 +1,61 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestNegativeCachingOfErrorStatus verifies that configured error statuses are
+// cached with the negative TTL and replayed with their original status code
+func TestNegativeCachingOfErrorStatus(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultTTL = 15 * time.Minute
+	config.NegativeTTL = 30 * time.Second
+	config.CacheableErrorStatus = []int{404, 410}
+	middleware := New(config)
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(`{"error": true}`))
+	}))
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/missing", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected cached 404, got %d", resp.Code)
+	}
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected X-Cache-Status: HIT for cached 404")
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to be called once, got %d", calls)
+	}
+
+	entry, found := middleware.GetCacheForTesting().Get(middleware.createCacheKey(req))
+	if !found {
+		t.Fatalf("Expected 404 to be cached")
+	}
+	if remaining := time.Until(entry.ExpiresAt); remaining > 30*time.Second {
+		t.Errorf("Expected negative TTL of 30s, got %v", remaining)
+	}
+
+	// Unconfigured error statuses are still not cached
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/broken", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/broken", nil))
+	if calls != 3 {
+		t.Errorf("Expected 500 responses not to be cached, handler called %d times", calls)
+	}
+}
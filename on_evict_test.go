@@ -0,0 +1,85 @@
+package selectcache
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// evictRecorder collects OnEvict callbacks
+type evictRecorder struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *evictRecorder) record(key string, entry *CacheEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry == nil || entry.key != key {
+		r.keys = append(r.keys, "mismatch:"+key)
+		return
+	}
+	r.keys = append(r.keys, key)
+}
+
+func (r *evictRecorder) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.keys...)
+}
+
+func TestTTLCache_OnEvictLRU(t *testing.T) {
+	recorder := &evictRecorder{}
+	config := DefaultCacheConfig()
+	config.MaxEntries = 2
+	config.ShardCount = 1
+
+	var cache *TTLCache
+	config.OnEvict = func(key string, entry *CacheEntry) {
+		// Re-entering the cache must not deadlock
+		cache.Size()
+		recorder.record(key, entry)
+	}
+	cache = NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	cache.Set("a", []byte("1"), http.Header{}, time.Minute)
+	time.Sleep(time.Millisecond)
+	cache.Set("b", []byte("2"), http.Header{}, time.Minute)
+	time.Sleep(time.Millisecond)
+	cache.Set("c", []byte("3"), http.Header{}, time.Minute)
+
+	if got := recorder.snapshot(); len(got) != 1 || got[0] != "a" {
+		t.Errorf("Expected OnEvict for least recently used key a, got %v", got)
+	}
+}
+
+func TestTTLCache_OnEvictExpiry(t *testing.T) {
+	recorder := &evictRecorder{}
+	config := DefaultCacheConfig()
+
+	var cache *TTLCache
+	config.OnEvict = func(key string, entry *CacheEntry) {
+		cache.Get("other")
+		recorder.record(key, entry)
+	}
+	cache = NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	cache.Set("swept", []byte("1"), http.Header{}, 10*time.Millisecond)
+	cache.Set("looked-up", []byte("2"), http.Header{}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// Expired entry found on lookup
+	if _, found := cache.Get("looked-up"); found {
+		t.Fatal("Expected expired entry to be reported missing")
+	}
+	// Expired entry removed by the background sweep
+	cache.cleanupExpired()
+
+	got := recorder.snapshot()
+	if len(got) != 2 || got[0] != "looked-up" || got[1] != "swept" {
+		t.Errorf("Expected OnEvict for looked-up then swept, got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMiddleware_PartialContent verifies that a 206 for part of a resource
+// is passed through uncached, while one covering the whole representation is
+// cached and replayed as a 200
+func TestMiddleware_PartialContent(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	body := `{"items": [1, 2, 3, 4, 5, 6, 7, 8, 9]}`
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/partial" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-9/%d", len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(body[:10]))
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(body)-1, len(body)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(body))
+	}))
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Range", "bytes=0-")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := get("/partial"); resp.Code != http.StatusPartialContent || resp.Header().Get("X-Cache-Status") != "MISS" {
+			t.Errorf("Expected the partial 206 to pass through uncached, got %d %q", resp.Code, resp.Header().Get("X-Cache-Status"))
+		}
+	}
+	if count := middleware.GetMetrics().GetStats().Errors["partial_content_uncached"]; count != 2 {
+		t.Errorf("Expected 2 partial_content_uncached errors, got %d", count)
+	}
+
+	get("/whole")
+	resp := get("/whole")
+	if resp.Header().Get("X-Cache-Status") != "HIT" || calls != 3 {
+		t.Fatalf("Expected the whole-representation 206 to be cached, got %q after %d origin calls", resp.Header().Get("X-Cache-Status"), calls)
+	}
+	if resp.Code != http.StatusOK || resp.Body.String() != body {
+		t.Errorf("Expected the full body as a 200, got %d %q", resp.Code, resp.Body.String())
+	}
+	if contentRange := resp.Header().Get("Content-Range"); contentRange != "" {
+		t.Errorf("Expected no Content-Range on the cached 200, got %q", contentRange)
+	}
+}
+
+// TestCachingConnection_PartialContent verifies the same 206 handling on the
+// transport path
+func TestCachingConnection_PartialContent(t *testing.T) {
+	body := `{"items": [1, 2, 3, 4, 5, 6, 7, 8, 9]}`
+	tests := []struct {
+		name         string
+		contentRange string
+		length       int
+		cached       bool
+	}{
+		{"partial", fmt.Sprintf("bytes 0-9/%d", len(body)), 10, false},
+		{"whole", fmt.Sprintf("bytes 0-%d/%d", len(body)-1, len(body)), len(body), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultCacheConfig()
+			cache := NewTTLCache(config, nil)
+			defer cache.Close()
+			metrics := NewCacheMetrics(true)
+			conn, mockConn := newPooledConnection(config, cache, metrics, nil)
+			defer conn.Close()
+
+			mockConn.writeToReadBuffer([]byte("GET /items HTTP/1.1\r\nHost: example.com\r\nRange: bytes=0-\r\n\r\n"))
+			conn.Read(make([]byte, 1024))
+			conn.Write([]byte(fmt.Sprintf("HTTP/1.1 206 Partial Content\r\nContent-Type: application/json\r\nContent-Range: %s\r\nContent-Length: %d\r\n\r\n%s",
+				tt.contentRange, tt.length, body[:tt.length])))
+
+			key := GenerateCacheKey("GET", "/items", "", map[string]string{"Host": "example.com"})
+			entry, found := waitForEntry(cache, key, 100*time.Millisecond)
+			if found != tt.cached {
+				t.Fatalf("Expected cached=%v, got %v", tt.cached, found)
+			}
+			if !tt.cached {
+				if count := metrics.GetStats().Errors["partial_content_uncached"]; count != 1 {
+					t.Errorf("Expected 1 partial_content_uncached error, got %d", count)
+				}
+				return
+			}
+			if entry.StatusCode != http.StatusOK || entry.Headers.Get("Content-Range") != "" || string(entry.Data) != body {
+				t.Errorf("Expected a full 200 entry, got %d %q %q", entry.StatusCode, entry.Headers.Get("Content-Range"), entry.Data)
+			}
+		})
+	}
+}
+
+// TestCoversWholeRepresentation verifies Content-Range checks for 206 bodies
+func TestCoversWholeRepresentation(t *testing.T) {
+	tests := []struct {
+		contentRange string
+		body         string
+		want         bool
+	}{
+		{"bytes 0-4/5", "hello", true},
+		{"bytes 0-2/5", "hel", false},
+		{"bytes 2-4/5", "llo", false},
+		{"bytes 0-4/*", "hello", false},
+		{"bytes 0-4/6", "hello", false},
+		{"", "hello", false},
+		{"items 0-4/5", "hello", false},
+	}
+
+	for _, tt := range tests {
+		headers := http.Header{"Content-Range": {tt.contentRange}}
+		if got := coversWholeRepresentation(headers, []byte(tt.body)); got != tt.want {
+			t.Errorf("coversWholeRepresentation(%q, %q) = %v, want %v", tt.contentRange, tt.body, got, tt.want)
+		}
+	}
+}
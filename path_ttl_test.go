@@ -0,0 +1,91 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPathTTL_Matches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/api/prices/*", "/api/prices/btc", true},
+		{"/api/prices/*", "/api/prices/btc/usd", true},
+		{"/api/prices/*", "/api/catalog/1", false},
+		{"/api/*/detail", "/api/items/detail", true},
+		{"/api/*/detail", "/api/items/1/detail", false},
+		{"/assets/*.css", "/assets/site.css", true},
+		{"/assets/*.css", "/assets/site.js", false},
+		{"/health", "/health", true},
+		{"/health", "/healthz", false},
+	}
+
+	for _, tt := range tests {
+		if got := (PathTTL{Pattern: tt.pattern}).Matches(tt.path); got != tt.want {
+			t.Errorf("PathTTL{%q}.Matches(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestContentDetector_PathTTLOverridesContentType(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ContentTypeTTLs["application/json"] = 10 * time.Minute
+	config.PathTTLs = []PathTTL{
+		{Pattern: "/api/prices/*", TTL: 30 * time.Second},
+		{Pattern: "/api/*", TTL: time.Hour},
+	}
+	detector := NewContentDetector(config)
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/api/prices/btc", 30 * time.Second}, // first match wins
+		{"/api/catalog", time.Hour},
+		{"/other", 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := detector.AnalyzeResponseForPath(tt.path, []byte(`{}`), headers, 200).RecommendedTTL; got != tt.want {
+			t.Errorf("TTL for %s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware_PathTTLs(t *testing.T) {
+	config := DefaultConfig()
+	config.PathTTLs = []PathTTL{
+		{Pattern: "/api/prices/*", TTL: 30 * time.Second},
+		{Pattern: "/api/catalog/*", TTL: time.Hour},
+	}
+	middleware := New(config)
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/api/prices/btc", 30 * time.Second},
+		{"/api/catalog/42", time.Hour},
+		{"/api/other", config.DefaultTTL},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		entry, found := middleware.GetCacheForTesting().Get(middleware.createCacheKey(req))
+		if !found {
+			t.Fatalf("Expected %s to be cached", tt.path)
+		}
+		if remaining := time.Until(entry.ExpiresAt); remaining > tt.want || remaining < tt.want-time.Second {
+			t.Errorf("TTL for %s = %v, want %v", tt.path, remaining, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package selectcache
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// PathTTL overrides the TTL for request paths matching Pattern. A pattern
+// ending in "*" with no other wildcards is a prefix match ("/api/prices/*"
+// matches everything below /api/prices/); other patterns use path.Match glob
+// syntax, where "*" does not cross "/"; a pattern without wildcards matches
+// that exact path.
+type PathTTL struct {
+	Pattern string        `json:"pattern"`
+	TTL     time.Duration `json:"ttl"`
+}
+
+// Matches reports whether the request path matches the pattern
+func (p PathTTL) Matches(requestPath string) bool {
+	if prefix, isPrefix := strings.CutSuffix(p.Pattern, "*"); isPrefix && !strings.ContainsAny(prefix, `*?[\`) {
+		return strings.HasPrefix(requestPath, prefix)
+	}
+	matched, err := path.Match(p.Pattern, requestPath)
+	return err == nil && matched
+}
+
+// ttlForPath returns the TTL of the first rule matching the request path
+func ttlForPath(rules []PathTTL, requestPath string) (time.Duration, bool) {
+	if requestPath == "" {
+		return 0, false
+	}
+	for _, rule := range rules {
+		if rule.Matches(requestPath) {
+			return rule.TTL, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+package selectcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPurgeHandler(t *testing.T) {
+	middleware := NewDefault()
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	for _, path := range []string{"/a", "/b", "/c"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	purge := middleware.PurgeHandler()
+
+	// Deleting a single URL
+	resp := httptest.NewRecorder()
+	purge.ServeHTTP(resp, httptest.NewRequest("DELETE", "/cache/purge?url=/a", nil))
+	var result PurgeResult
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode purge result: %v", err)
+	}
+	if result.Operation != "delete" || result.URL != "/a" || result.EntriesAffected != 1 {
+		t.Errorf("Unexpected delete result: %+v", result)
+	}
+
+	// Clearing everything
+	resp = httptest.NewRecorder()
+	purge.ServeHTTP(resp, httptest.NewRequest("POST", "/cache/purge", nil))
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Operation != "clear" || result.EntriesAffected != 2 {
+		t.Errorf("Unexpected clear result: %+v", result)
+	}
+	if itemCount, _, _ := middleware.Stats(); itemCount != 0 {
+		t.Errorf("Expected empty cache after clear, got %d items", itemCount)
+	}
+
+	// Other methods are rejected
+	resp = httptest.NewRecorder()
+	purge.ServeHTTP(resp, httptest.NewRequest("GET", "/cache/purge", nil))
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", resp.Code)
+	}
+	if resp.Header().Get("Allow") != "DELETE, POST" {
+		t.Errorf("Expected Allow header, got %q", resp.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,58 @@
+package selectcache
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// NormalizeQuery returns a canonical form of a raw query string for cache
+// keys: parameters are sorted by name (repeated parameters keep their order)
+// and any parameter named in ignore is dropped. An ignore entry ending in "*"
+// drops every parameter with that prefix, e.g. "utm_*". Queries that fail to
+// parse are returned unchanged.
+func NormalizeQuery(rawQuery string, ignore []string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !isIgnoredQueryParam(name, ignore) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, value := range values[name] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(name))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+		}
+	}
+	return b.String()
+}
+
+// isIgnoredQueryParam reports whether a query parameter matches the ignore list
+func isIgnoredQueryParam(name string, ignore []string) bool {
+	for _, pattern := range ignore {
+		if prefix, isPrefix := strings.CutSuffix(pattern, "*"); isPrefix {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
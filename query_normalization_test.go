@@ -0,0 +1,96 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		ignore []string
+		want   string
+	}{
+		{"empty", "", nil, ""},
+		{"sorted", "b=2&a=1", nil, "a=1&b=2"},
+		{"repeated keeps order", "tag=z&a=1&tag=y", nil, "a=1&tag=z&tag=y"},
+		{"ignored exact", "a=1&fbclid=xyz", []string{"fbclid"}, "a=1"},
+		{"ignored prefix", "utm_source=news&a=1&utm_medium=email", []string{"utm_*"}, "a=1"},
+		{"all ignored", "utm_source=news", []string{"utm_*"}, ""},
+		{"malformed unchanged", "a=%zz", nil, "a=%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeQuery(tt.query, tt.ignore); got != tt.want {
+				t.Errorf("NormalizeQuery(%q, %v) = %q, want %q", tt.query, tt.ignore, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ReorderedAndTrackedURLsShareEntry(t *testing.T) {
+	config := DefaultConfig()
+	config.IgnoreQueryParams = []string{"utm_*"}
+	middleware := New(config)
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	for _, target := range []string{
+		"/api/items?a=1&b=2",
+		"/api/items?b=2&a=1",
+		"/api/items?a=1&utm_source=newsletter&b=2&utm_campaign=fall",
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	if calls != 1 {
+		t.Errorf("Expected reordered and utm-tagged URLs to hit one entry, origin called %d times", calls)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/items?a=1&b=3", nil))
+	if calls != 2 {
+		t.Error("Expected a different parameter value to miss")
+	}
+
+	// Delete by any equivalent URL removes the shared entry
+	middleware.Delete("/api/items?utm_source=x&b=2&a=1")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/items?a=1&b=2", nil))
+	if calls != 3 {
+		t.Error("Expected Delete through an equivalent URL to remove the entry")
+	}
+}
+
+func TestQueryNormalizationConsistentAcrossLayers(t *testing.T) {
+	ignore := []string{"utm_*"}
+	middlewareConfig := DefaultConfig()
+	middlewareConfig.IgnoreQueryParams = ignore
+	middleware := New(middlewareConfig)
+
+	config := DefaultCacheConfig()
+	config.IgnoreQueryParams = ignore
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	transportKey := func(target string) string {
+		mockConn := newMockConn()
+		conn := NewCachingConnection(mockConn, cache, config, nil, NewContentDetector(config))
+		request := []byte("GET " + target + " HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		mockConn.writeToReadBuffer(request)
+		conn.Read(make([]byte, len(request)))
+		return conn.cacheKey
+	}
+
+	base := middleware.createCacheKey(httptest.NewRequest("GET", "/api/items?a=1&b=2", nil))
+	for _, target := range []string{"/api/items?b=2&a=1", "/api/items?utm_source=x&a=1&b=2"} {
+		if got := transportKey(target); got != base {
+			t.Errorf("Transport key for %s = %s, want middleware key %s", target, got, base)
+		}
+	}
+}
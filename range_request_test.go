@@ -0,0 +1,124 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// rangeHeaders returns the image response headers for the range tests,
+// advertising byte ranges when acceptRanges is set
+func rangeHeaders(acceptRanges bool) http.Header {
+	headers := http.Header{"Content-Type": {"image/png"}}
+	if acceptRanges {
+		headers.Set("Accept-Ranges", "bytes")
+	}
+	return headers
+}
+
+func TestMiddleware_RangeRequestOnCachedBody(t *testing.T) {
+	handler := newHeaderTestHandler(t, DefaultConfig(), rangeHeaders(true), "0123456789")
+	serveWithHeader(handler, "/image.png", "Range", "") // populate the cache
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"prefix", "bytes=0-3", http.StatusPartialContent, "0123", "bytes 0-3/10"},
+		{"open ended", "bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"suffix", "bytes=-2", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"end clamped", "bytes=5-100", http.StatusPartialContent, "56789", "bytes 5-9/10"},
+		{"out of bounds", "bytes=10-20", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"multi range served whole", "bytes=0-1,4-5", http.StatusOK, "0123456789", ""},
+		{"malformed served whole", "bytes=abc", http.StatusOK, "0123456789", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveWithHeader(handler, "/image.png", "Range", tt.rangeHeader)
+			if rec.Header().Get("X-Cache-Status") != "HIT" {
+				t.Fatalf("Expected response served from cache")
+			}
+			if rec.Code != tt.status {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.status)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("Body = %q, want %q", rec.Body.String(), tt.body)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if tt.status == http.StatusPartialContent {
+				if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(len(tt.body)); got != want {
+					t.Errorf("Content-Length = %q, want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware_RangeRequestRequiresOptIn(t *testing.T) {
+	handler := newHeaderTestHandler(t, DefaultConfig(), rangeHeaders(false), "0123456789")
+	serveWithHeader(handler, "/image.png", "Range", "")
+
+	rec := serveWithHeader(handler, "/image.png", "Range", "bytes=0-3")
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("Expected whole body without Accept-Ranges, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	config := DefaultConfig()
+	config.EnableRangeRequests = true
+	handler = newHeaderTestHandler(t, config, rangeHeaders(false), "0123456789")
+	serveWithHeader(handler, "/image.png", "Range", "")
+
+	rec = serveWithHeader(handler, "/image.png", "Range", "bytes=0-3")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "0123" {
+		t.Errorf("Expected partial content with EnableRangeRequests, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMiddleware_IfRange(t *testing.T) {
+	lastModified := "Wed, 21 Oct 2015 07:28:00 GMT"
+	handler := newHeaderTestHandler(t, DefaultConfig(), http.Header{
+		"Content-Type":  {"application/octet-stream"},
+		"Accept-Ranges": {"bytes"},
+		"Etag":          {`"v2"`},
+		"Last-Modified": {lastModified},
+	}, "0123456789")
+	serveWithHeader(handler, "/image.png", "Range", "") // populate the cache
+
+	tests := []struct {
+		name    string
+		ifRange string
+		status  int
+		body    string
+	}{
+		{"matching etag", `"v2"`, http.StatusPartialContent, "0123"},
+		{"mismatched etag", `"v1"`, http.StatusOK, "0123456789"},
+		{"weak etag", `W/"v2"`, http.StatusOK, "0123456789"},
+		{"matching date", lastModified, http.StatusPartialContent, "0123"},
+		{"mismatched date", "Thu, 22 Oct 2015 07:28:00 GMT", http.StatusOK, "0123456789"},
+		{"malformed", "not-a-validator", http.StatusOK, "0123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/image.png", nil)
+			req.Header.Set("Range", "bytes=0-3")
+			req.Header.Set("If-Range", tt.ifRange)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.status)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("Body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_RecomputeCorrectsDrift(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	for i := 0; i < 20; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte("data"), headers, time.Hour)
+	}
+	want := cache.MemoryUsage()
+
+	// Corrupt the global and per-shard counters
+	cache.totalMemoryBytes.Add(12345)
+	cache.totalEntries.Add(-3)
+	cache.shards[0].memoryBytes += 999
+
+	if drift := cache.Recompute(); drift != -12345 {
+		t.Errorf("Expected a correction of -12345 bytes, got %d", drift)
+	}
+	if got := uint64(cache.totalMemoryBytes.Load()); got != want {
+		t.Errorf("Global memory counter = %d, want %d", got, want)
+	}
+	if got := cache.MemoryUsage(); got != want {
+		t.Errorf("Shard memory total = %d, want %d", got, want)
+	}
+	if got := cache.totalEntries.Load(); got != 20 {
+		t.Errorf("Entry counter = %d, want 20", got)
+	}
+	if stats := cache.metrics.GetStats(); stats.TotalMemoryBytes != want || stats.EntryCount != 20 {
+		t.Errorf("Metrics not updated: memory %d, entries %d", stats.TotalMemoryBytes, stats.EntryCount)
+	}
+
+	if drift := cache.Recompute(); drift != 0 {
+		t.Errorf("Expected no drift on an accurate cache, got %d", drift)
+	}
+}
@@ -1,7 +1,13 @@
 package selectcache
 
 import (
+	"bufio"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // CachedResponse represents a cached HTTP response
@@ -9,6 +15,38 @@ type CachedResponse struct {
 	StatusCode int
 	Headers    http.Header
 	Body       []byte
+	// FreshUntil, when set, marks the end of the response's freshness lifetime.
+	// After it the response is only kept to be served if revalidation fails
+	// with a server error (stale-if-error).
+	FreshUntil time.Time
+	// Immutable marks a response sent with Cache-Control: immutable and
+	// cached for ImmutableTTL. It is never stale, so never revalidated.
+	Immutable bool
+	// StoreTime is when the response was stored in the cache
+	StoreTime time.Time
+	// ExpiresAt is when the cache entry expires; zero when the response
+	// didn't come from the cache
+	ExpiresAt time.Time
+	// Encoding is the compression algorithm Body is stored with; empty when
+	// stored uncompressed
+	Encoding string
+	// Trailers are the HTTP trailers sent after Body; nil when the response
+	// had none. They are only delivered to clients when the underlying
+	// ResponseWriter supports trailers, as net/http's does for chunked
+	// HTTP/1.1 and HTTP/2 responses.
+	Trailers http.Header
+}
+
+// Size returns the approximate memory footprint of the response: its body
+// plus header names and values
+func (c *CachedResponse) Size() int {
+	return len(c.Body) + headerSize(c.Headers) + headerSize(c.Trailers)
+}
+
+// IsStale reports whether the response has outlived its freshness lifetime
+// at now
+func (c *CachedResponse) IsStale(now time.Time) bool {
+	return !c.Immutable && !c.FreshUntil.IsZero() && now.After(c.FreshUntil)
 }
 
 // ResponseRecorder captures HTTP responses for caching
@@ -18,16 +56,27 @@ type ResponseRecorder struct {
 	headers       http.Header
 	body          []byte
 	written       bool
-	requestMethod string // Track request method to handle HEAD requests properly
+	requestMethod string   // Track request method to handle HEAD requests properly
+	maxBodyBytes  int64    // Maximum body bytes to buffer (0 means unlimited)
+	overflowed    bool     // Set once the body exceeds maxBodyBytes
+	streamed      bool     // Set once the handler flushes; streamed responses aren't cached
+	hiddenHeaders []string // Recorded but not sent to the client
 }
 
 // NewResponseRecorder creates a new response recorder
 func NewResponseRecorder(w http.ResponseWriter, requestMethod string) *ResponseRecorder {
+	return NewResponseRecorderWithLimit(w, requestMethod, 0)
+}
+
+// NewResponseRecorderWithLimit creates a new response recorder that stops buffering
+// the body once it exceeds maxBodyBytes. A limit of 0 means unlimited.
+func NewResponseRecorderWithLimit(w http.ResponseWriter, requestMethod string, maxBodyBytes int64) *ResponseRecorder {
 	return &ResponseRecorder{
 		ResponseWriter: w,
 		statusCode:     200, // Default status
 		headers:        make(http.Header),
 		requestMethod:  requestMethod,
+		maxBodyBytes:   maxBodyBytes,
 	}
 }
 
@@ -43,6 +92,9 @@ func (r *ResponseRecorder) WriteHeader(code int) {
 	for k, v := range r.ResponseWriter.Header() {
 		r.headers[k] = v
 	}
+	for _, name := range r.hiddenHeaders {
+		r.ResponseWriter.Header().Del(name)
+	}
 
 	r.ResponseWriter.WriteHeader(code)
 	r.written = true
@@ -56,14 +108,36 @@ func (r *ResponseRecorder) Write(data []byte) (int, error) {
 
 	// For HEAD requests, don't store body data to save memory
 	// HEAD responses should only cache headers
-	if r.requestMethod != "HEAD" {
-		r.body = append(r.body, data...)
+	// Once the body exceeds the size limit, drop the buffer and keep streaming
+	if r.requestMethod != "HEAD" && !r.overflowed && !r.streamed {
+		if r.maxBodyBytes > 0 && int64(len(r.body)+len(data)) > r.maxBodyBytes {
+			r.overflowed = true
+			r.body = nil
+		} else {
+			r.body = append(r.body, data...)
+		}
 	}
 
 	// Write to actual response (this will also be suppressed by HTTP server for HEAD)
 	return r.ResponseWriter.Write(data)
 }
 
+// Flush sends any buffered data to the client, delegating to the underlying
+// writer when it supports flushing. A flushed response is treated as a stream
+// (SSE, long-poll): body buffering stops and the response is not cached.
+func (r *ResponseRecorder) Flush() {
+	if !r.written {
+		r.WriteHeader(r.statusCode)
+	}
+
+	r.streamed = true
+	r.body = nil
+
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // Header returns the header map that will be sent by WriteHeader
 func (r *ResponseRecorder) Header() http.Header {
 	return r.ResponseWriter.Header()
@@ -90,7 +164,78 @@ func (r *ResponseRecorder) Body() []byte {
 	return body
 }
 
+// Trailers returns the trailers the handler set after writing the body: the
+// names declared in its Trailer header, and any set with http.TrailerPrefix.
+// It returns nil when there are none.
+func (r *ResponseRecorder) Trailers() http.Header {
+	var trailers http.Header
+	add := func(name string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		if trailers == nil {
+			trailers = make(http.Header)
+		}
+		trailers[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+
+	header := r.ResponseWriter.Header()
+	for _, declared := range r.headers.Values("Trailer") {
+		for _, name := range strings.Split(declared, ",") {
+			add(name, header.Values(strings.TrimSpace(name)))
+		}
+	}
+	for name, values := range header {
+		if trailer, ok := strings.CutPrefix(name, http.TrailerPrefix); ok {
+			add(trailer, values)
+		}
+	}
+	return trailers
+}
+
 // Size returns the size of the recorded response body
 func (r *ResponseRecorder) Size() int {
 	return len(r.body)
 }
+
+// Hijack lets the handler take over the connection, e.g. for WebSocket
+// upgrades, delegating to the underlying writer when it supports hijacking.
+// A hijacked response is never cached.
+func (r *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("selectcache: underlying ResponseWriter cannot be hijacked: %w", http.ErrNotSupported)
+	}
+
+	r.streamed = true
+	r.body = nil
+	return hijacker.Hijack()
+}
+
+// Streamed reports whether the handler flushed or hijacked the response, in
+// which case it is being streamed and must not be cached
+func (r *ResponseRecorder) Streamed() bool {
+	return r.streamed
+}
+
+// Overflowed reports whether the response body exceeded the buffering limit,
+// in which case the recorded body is incomplete and must not be cached
+func (r *ResponseRecorder) Overflowed() bool {
+	return r.overflowed
+}
+
+// Truncated reports whether the recorded body is shorter or longer than the
+// Content-Length the handler declared, as when it gave up part way through
+// because the client went away. HEAD responses and bodiless statuses are
+// never truncated.
+func (r *ResponseRecorder) Truncated() bool {
+	if r.requestMethod == http.MethodHead || !bodyAllowedForStatus(r.statusCode) || r.overflowed || r.streamed {
+		return false
+	}
+	declared := r.headers.Get("Content-Length")
+	if declared == "" {
+		return false
+	}
+	length, err := strconv.ParseInt(strings.TrimSpace(declared), 10, 64)
+	return err != nil || length != int64(len(r.body))
+}
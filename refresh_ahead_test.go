@@ -0,0 +1,103 @@
+package selectcache
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRefreshAheadRefreshesAccessedEntries verifies that entries close to expiry
+// are refreshed via RefreshFunc only when they have been accessed
+func TestRefreshAheadRefreshesAccessedEntries(t *testing.T) {
+	var mu sync.Mutex
+	refreshed := make(map[string]int)
+
+	config := DefaultCacheConfig()
+	config.CleanupInterval = 20 * time.Millisecond
+	config.RefreshAhead = 800 * time.Millisecond
+	config.RefreshFunc = func(key string) (*CachedResponse, error) {
+		mu.Lock()
+		refreshed[key]++
+		mu.Unlock()
+		headers := make(http.Header)
+		headers.Set("Content-Type", "application/json")
+		return &CachedResponse{StatusCode: 200, Headers: headers, Body: []byte(`{"fresh": true}`)}, nil
+	}
+
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := make(http.Header)
+	cache.Set("hot", []byte(`{"fresh": false}`), headers, time.Second)
+	cache.Set("dead", []byte(`{"fresh": false}`), headers, time.Second)
+	entry, _ := cache.Get("hot")
+	originalExpiry := entry.ExpiresAt
+
+	time.Sleep(300 * time.Millisecond)
+
+	mu.Lock()
+	hotCount, deadCount := refreshed["hot"], refreshed["dead"]
+	mu.Unlock()
+
+	if hotCount == 0 {
+		t.Errorf("Expected accessed entry to be refreshed")
+	}
+	if deadCount != 0 {
+		t.Errorf("Expected unaccessed entry not to be refreshed, got %d refreshes", deadCount)
+	}
+
+	// The refreshed entry carries the new body and a pushed-out expiry
+	entry, found := cache.Get("hot")
+	if !found {
+		t.Fatalf("Expected refreshed entry to still be cached")
+	}
+	if string(entry.Data) != `{"fresh": true}` {
+		t.Errorf("Expected refreshed body, got %s", entry.Data)
+	}
+	if !entry.ExpiresAt.After(originalExpiry) {
+		t.Errorf("Expected refresh to extend expiry beyond %v, got %v", originalExpiry, entry.ExpiresAt)
+	}
+}
+
+// TestRefreshAheadKeepsResponse verifies that a refreshed entry keeps the
+// status code and trailers RefreshFunc returned, and is stored with the
+// original TTL rather than an already jittered one
+func TestRefreshAheadKeepsResponse(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.Clock = clock
+	config.DisableAutoCleanup = true
+	config.RefreshAhead = 30 * time.Second
+	config.TTLJitter = 0.5
+	config.RefreshFunc = func(key string) (*CachedResponse, error) {
+		headers := make(http.Header)
+		headers.Set("Location", "/new")
+		return &CachedResponse{
+			StatusCode: http.StatusMovedPermanently,
+			Headers:    headers,
+			Trailers:   http.Header{"Checksum": {"abc"}},
+		}, nil
+	}
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.setResponse("moved", &CachedResponse{StatusCode: http.StatusMovedPermanently, Headers: make(http.Header)}, time.Minute)
+	entry, _ := cache.Get("moved")
+	clock.Advance(entry.ExpiresAt.Sub(clock.Now()) - time.Second)
+	cache.CleanupNow()
+
+	refreshed, found := cache.Get("moved")
+	if !found || refreshed == entry {
+		t.Fatal("Expected the entry to be refreshed")
+	}
+	if refreshed.StatusCode != http.StatusMovedPermanently || refreshed.Trailers.Get("Checksum") != "abc" {
+		t.Errorf("Expected the refreshed 301 and its trailers, got %d %v", refreshed.StatusCode, refreshed.Trailers)
+	}
+	if refreshed.baseTTL != time.Minute {
+		t.Errorf("Expected the refresh to use the unjittered 1m TTL, got %v", refreshed.baseTTL)
+	}
+	if ttl := refreshed.ExpiresAt.Sub(refreshed.StoreTime); ttl < 30*time.Second || ttl > 90*time.Second {
+		t.Errorf("Expected a TTL within jitter of 1m, got %v", ttl)
+	}
+}
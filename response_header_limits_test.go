@@ -0,0 +1,99 @@
+package selectcache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newHeaderLimitConnection returns a caching connection that has read a GET
+// for /headers.json, with its cache, metrics, underlying connection and the
+// key its response is stored under
+func newHeaderLimitConnection(t *testing.T, config *CacheConfig) (*CachingConnection, *TTLCache, *CacheMetrics, *mockConn, string) {
+	t.Helper()
+
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	t.Cleanup(func() { cache.Close() })
+
+	mockConn := newMockConn()
+	conn := NewCachingConnection(mockConn, cache, config, metrics, NewContentDetector(config))
+	t.Cleanup(func() { conn.Close() })
+
+	request := []byte("GET /headers.json HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	mockConn.writeToReadBuffer(request)
+	if _, err := conn.Read(make([]byte, len(request))); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return conn, cache, metrics, mockConn, GenerateCacheKey("GET", "/headers.json", "", map[string]string{"Host": "example.com"})
+}
+
+// jsonResponseWithHeaders builds a complete JSON response carrying extra
+// headers X-Filler-0 through X-Filler-(count-1), each with a value of size bytes
+func jsonResponseWithHeaders(count, size int) string {
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 11\r\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&b, "X-Filler-%d: %s\r\n", i, strings.Repeat("v", size))
+	}
+	b.WriteString("\r\n{\"ok\":true}")
+	return b.String()
+}
+
+func TestResponseHeaderLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBytes   int
+		maxHeaders int
+		response   string
+		cached     bool
+		errorName  string
+	}{
+		{"within limits", 0, 0, jsonResponseWithHeaders(10, 10), true, ""},
+		{"too many headers", 0, 20, jsonResponseWithHeaders(30, 1), false, "response_headers_too_large"},
+		{"header block too large", 1024, 0, jsonResponseWithHeaders(4, 400), false, "response_headers_too_large"},
+		{"default byte limit", 0, 0, jsonResponseWithHeaders(20, 1000), false, "response_headers_too_large"},
+		{"status line only", 0, 0, "HTTP/1.1 200 OK\r\n\r\n", true, ""},
+		{"missing status line", 0, 0, "Content-Type: application/json\r\n\r\n{\"ok\":true}", false, "response_parse_failed"},
+		{"single garbage line", 0, 0, "garbage\r\n\r\n", false, "response_parse_failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultCacheConfig()
+			config.MaxResponseHeaderBytes = tt.maxBytes
+			config.MaxResponseHeaders = tt.maxHeaders
+			conn, cache, metrics, mockConn, key := newHeaderLimitConnection(t, config)
+
+			n, err := conn.Write([]byte(tt.response))
+			if err != nil || n != len(tt.response) {
+				t.Fatalf("Expected the response to pass through, wrote %d of %d bytes: %v", n, len(tt.response), err)
+			}
+			if mockConn.writeBuffer.String() != tt.response {
+				t.Error("Expected the client to receive the response unchanged")
+			}
+
+			if _, found := cache.Get(key); found != tt.cached {
+				t.Errorf("Expected cached=%v, got %v", tt.cached, found)
+			}
+			if tt.errorName != "" && metrics.GetStats().Errors[tt.errorName] != 1 {
+				t.Errorf("Expected %s to be recorded, got %v", tt.errorName, metrics.GetStats().Errors)
+			}
+
+			conn.writeMu.Lock()
+			buffered := len(conn.responseBuffer)
+			conn.writeMu.Unlock()
+			if !tt.cached && buffered != 0 {
+				t.Errorf("Expected the rejected response not to stay buffered, got %d bytes", buffered)
+			}
+		})
+	}
+}
+
+func TestResponseHeaderLimits_Validate(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxResponseHeaders = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected negative response header limits to be rejected")
+	}
+}
@@ -0,0 +1,90 @@
+package selectcache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newSettleConnection returns a caching connection that has read a GET for
+// /slow.json, with the cache and the key its response is stored under
+func newSettleConnection(t *testing.T, settle time.Duration) (*CachingConnection, *TTLCache, string) {
+	t.Helper()
+
+	config := DefaultCacheConfig()
+	config.ResponseSettleTimeout = settle
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	t.Cleanup(func() { cache.Close() })
+
+	mockConn := newMockConn()
+	conn := NewCachingConnection(mockConn, cache, config, metrics, NewContentDetector(config))
+	t.Cleanup(func() { conn.Close() })
+
+	request := []byte("GET /slow.json HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	mockConn.writeToReadBuffer(request)
+	if _, err := conn.Read(make([]byte, len(request))); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return conn, cache, GenerateCacheKey("GET", "/slow.json", "", map[string]string{"Host": "example.com"})
+}
+
+// waitForEntry polls the cache for key until timeout
+func waitForEntry(cache *TTLCache, key string, timeout time.Duration) (*CacheEntry, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if entry, found := cache.Get(key); found {
+			return entry, true
+		}
+		if time.Now().After(deadline) {
+			return nil, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestResponseSettleTimeout_UnframedResponse verifies that an unframed
+// response written in large pieces is cached whole once writes stop
+func TestResponseSettleTimeout_UnframedResponse(t *testing.T) {
+	conn, cache, key := newSettleConnection(t, 30*time.Millisecond)
+
+	body := []byte(`[` + string(bytes.Repeat([]byte(`{"id":1,"name":"item"},`), 150)) + `{"id":0}]`)
+	conn.Write(append([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n"), body[:1500]...))
+	conn.Write(body[1500:])
+
+	if _, found := cache.Get(key); found {
+		t.Fatal("Expected response not to be cached before it settled")
+	}
+
+	entry, found := waitForEntry(cache, key, time.Second)
+	if !found {
+		t.Fatal("Expected settled response to be cached")
+	}
+	if !bytes.Equal(entry.Data, body) {
+		t.Errorf("Expected the whole body cached, got %d of %d bytes", len(entry.Data), len(body))
+	}
+}
+
+// TestResponseSettleTimeout_IncompleteContentLength verifies that a response
+// short of its Content-Length is not cached when it settles
+func TestResponseSettleTimeout_IncompleteContentLength(t *testing.T) {
+	conn, cache, key := newSettleConnection(t, 10*time.Millisecond)
+
+	body := bytes.Repeat([]byte("x"), 2000)
+	header := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n", len(body))
+	conn.Write(append([]byte(header), body[:1500]...))
+
+	if _, found := waitForEntry(cache, key, 100*time.Millisecond); found {
+		t.Fatal("Expected a response short of its Content-Length not to be cached")
+	}
+}
+
+// TestResponseSettleTimeout_Validate verifies that a negative timeout is rejected
+func TestResponseSettleTimeout_Validate(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.ResponseSettleTimeout = -time.Second
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative response settle timeout to be rejected")
+	}
+}
@@ -0,0 +1,60 @@
+package selectcache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestChunkedResponseAbandonedAtSizeCap verifies that a streaming response with
+// no Content-Length is abandoned as soon as its body crosses the size cap
+func TestChunkedResponseAbandonedAtSizeCap(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxResponseSize = 4096
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	mockConn := newMockConn()
+	conn := NewCachingConnection(mockConn, cache, config, metrics, NewContentDetector(config))
+
+	header := []byte("HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nTransfer-Encoding: chunked\r\n\r\n")
+	chunk := bytes.Repeat([]byte("x"), 2000)
+	chunkFrame := append([]byte(fmt.Sprintf("%x\r\n", len(chunk))), append(chunk, []byte("\r\n")...)...)
+
+	// No request is parsed, so buffering is exercised without early analysis
+	conn.Write(append(header, chunkFrame...))
+	if len(conn.responseBuffer) == 0 {
+		t.Fatalf("Response below the cap should still be buffered")
+	}
+
+	// Crossing the cap releases the buffer immediately
+	conn.Write(chunkFrame)
+	conn.Write(chunkFrame)
+	if size := len(conn.responseBuffer); size != 0 {
+		t.Errorf("Expected buffer to be released after crossing the cap, got %d bytes", size)
+	}
+
+	conn.Write([]byte("0\r\n\r\n"))
+	if size := len(conn.responseBuffer); size != 0 {
+		t.Errorf("Expected buffering to stay abandoned, got %d bytes", size)
+	}
+	if cache.Size() != 0 {
+		t.Errorf("Expected oversized response not to be cached, got %d entries", cache.Size())
+	}
+	if metrics.GetStats().Errors["response_too_large"] != 1 {
+		t.Errorf("Expected response_too_large to be recorded once")
+	}
+}
+
+func TestCacheConfig_MaxCacheableSize(t *testing.T) {
+	config := DefaultCacheConfig()
+	if got, want := config.MaxCacheableSize(), config.MaxMemoryMB*1024*1024/10; got != want {
+		t.Errorf("MaxCacheableSize() = %d, want %d", got, want)
+	}
+
+	config.MaxResponseSize = 1024
+	if got := config.MaxCacheableSize(); got != 1024 {
+		t.Errorf("MaxCacheableSize() = %d, want 1024", got)
+	}
+}
@@ -0,0 +1,101 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_SafeMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		cacheControl  string
+		setCookie     bool
+		wantCached    bool
+	}{
+		{name: "plain response", method: "GET", wantCached: true},
+		{name: "set-cookie", method: "GET", setCookie: true},
+		{name: "authorization", method: "GET", authorization: "Bearer alice"},
+		{name: "authorization public", method: "GET", authorization: "Bearer alice", cacheControl: "public, max-age=60", wantCached: true},
+		{name: "no-store", method: "GET", cacheControl: "no-store"},
+		{name: "no-cache", method: "GET", cacheControl: "no-cache"},
+		{name: "private", method: "GET", cacheControl: "private, max-age=60"},
+		{name: "post", method: "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// SafeMode overrides the looser settings below
+			config := DefaultConfig()
+			config.SafeMode = true
+			config.NoCacheOnSetCookie = false
+			config.CachePrivateResponses = true
+			config.StripHeaders = []string{"Connection"}
+			middleware := New(config)
+			defer middleware.Close()
+
+			calls := 0
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				if tt.cacheControl != "" {
+					w.Header().Set("Cache-Control", tt.cacheControl)
+				}
+				if tt.setCookie {
+					w.Header().Set("Set-Cookie", "session=alice")
+				}
+				w.Write([]byte(`{"ok":true}`))
+			}))
+
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(tt.method, "/resource", nil)
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			if cached := calls == 1; cached != tt.wantCached {
+				t.Errorf("Cached = %v, want %v (%d origin calls)", cached, tt.wantCached, calls)
+			}
+		})
+	}
+}
+
+func TestMiddleware_SafeModeOffIgnoresStorageDirectives(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/resource", nil))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/resource", nil))
+	if rec.Header().Get("X-Cache-Status") != "HIT" {
+		t.Error("Without SafeMode, no-cache responses keep the existing behavior of being cached")
+	}
+}
+
+func TestWithSafeModeStripsSetCookie(t *testing.T) {
+	config := withSafeMode(Config{StripHeaders: []string{"Connection"}})
+	found := false
+	for _, name := range config.StripHeaders {
+		if name == "Set-Cookie" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected Set-Cookie to be added to StripHeaders, got %v", config.StripHeaders)
+	}
+
+	config = withSafeMode(Config{StripHeaders: []string{"set-cookie"}})
+	if len(config.StripHeaders) != 1 {
+		t.Errorf("Set-Cookie should not be added twice, got %v", config.StripHeaders)
+	}
+}
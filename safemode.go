@@ -0,0 +1,21 @@
+package selectcache
+
+import "net/http"
+
+// withSafeMode returns config with the conservative rules enabled by
+// Config.SafeMode: responses carrying Set-Cookie are not cached and the cookie
+// is always stripped, and responses to authenticated requests stay private
+// unless marked public. Cache-Control storage directives are checked in
+// shouldCache, and only GET and HEAD are ever cached regardless of mode.
+func withSafeMode(config Config) Config {
+	config.NoCacheOnSetCookie = true
+	config.CachePrivateResponses = false
+
+	for _, name := range config.StripHeaders {
+		if http.CanonicalHeaderKey(name) == "Set-Cookie" {
+			return config
+		}
+	}
+	config.StripHeaders = append(append([]string(nil), config.StripHeaders...), "Set-Cookie")
+	return config
+}
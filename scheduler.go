@@ -0,0 +1,98 @@
+package selectcache
+
+import (
+	"sync"
+	"time"
+)
+
+// CleanupScheduler runs the periodic cleanup of many TTLCaches from a single
+// goroutine and ticker. Without one, every TTLCache starts its own cleanup
+// goroutine, which adds up for applications creating many short-lived or
+// per-tenant caches. Caches using a scheduler are cleaned on its interval
+// rather than their own CleanupInterval.
+type CleanupScheduler struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	caches map[*TTLCache]struct{}
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewCleanupScheduler creates a scheduler that cleans its registered caches
+// every interval, starting its goroutine immediately. Call Stop once no cache
+// uses it any more.
+func NewCleanupScheduler(interval time.Duration) *CleanupScheduler {
+	s := &CleanupScheduler{
+		interval: interval,
+		caches:   make(map[*TTLCache]struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// run cleans every registered cache on each tick until the scheduler stops
+func (s *CleanupScheduler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Clean outside the lock so caches can register and close meanwhile
+			for _, cache := range s.registered() {
+				cache.runCleanup()
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// registered returns a snapshot of the registered caches
+func (s *CleanupScheduler) registered() []*TTLCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	caches := make([]*TTLCache, 0, len(s.caches))
+	for cache := range s.caches {
+		caches = append(caches, cache)
+	}
+	return caches
+}
+
+// register adds a cache to the cleanup rotation
+func (s *CleanupScheduler) register(cache *TTLCache) {
+	s.mu.Lock()
+	s.caches[cache] = struct{}{}
+	s.mu.Unlock()
+}
+
+// deregister removes a cache from the cleanup rotation
+func (s *CleanupScheduler) deregister(cache *TTLCache) {
+	s.mu.Lock()
+	delete(s.caches, cache)
+	s.mu.Unlock()
+}
+
+// Len returns the number of caches registered with the scheduler
+func (s *CleanupScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.caches)
+}
+
+// Stop stops the scheduler's goroutine and waits for it to exit. Registered
+// caches are no longer cleaned. It is safe to call more than once.
+func (s *CleanupScheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
@@ -0,0 +1,88 @@
+package selectcache
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitForGoroutines polls until the goroutine count drops to at most want
+func waitForGoroutines(want int) int {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := runtime.NumGoroutine()
+		if got <= want || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCleanupScheduler_SharedGoroutine(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	scheduler := NewCleanupScheduler(time.Minute)
+	caches := make([]*TTLCache, 100)
+	for i := range caches {
+		config := DefaultCacheConfig()
+		config.CleanupScheduler = scheduler
+		caches[i] = NewTTLCache(config, nil)
+	}
+
+	if got := runtime.NumGoroutine(); got > baseline+1 {
+		t.Errorf("Expected one scheduler goroutine for 100 caches, goroutines went from %d to %d", baseline, got)
+	}
+	if scheduler.Len() != 100 {
+		t.Errorf("Expected 100 registered caches, got %d", scheduler.Len())
+	}
+
+	for _, cache := range caches {
+		cache.Close()
+		cache.Close() // Close is idempotent
+	}
+	if scheduler.Len() != 0 {
+		t.Errorf("Expected Close to deregister every cache, %d remain", scheduler.Len())
+	}
+
+	scheduler.Stop()
+	scheduler.Stop()
+	if got := waitForGoroutines(baseline); got > baseline {
+		t.Errorf("Goroutines leaked: %d before, %d after", baseline, got)
+	}
+}
+
+func TestTTLCache_CloseStopsOwnGoroutine(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		NewTTLCache(DefaultCacheConfig(), nil).Close()
+	}
+
+	if got := waitForGoroutines(baseline); got > baseline {
+		t.Errorf("Goroutines leaked: %d before, %d after", baseline, got)
+	}
+}
+
+func TestCleanupScheduler_CleansRegisteredCaches(t *testing.T) {
+	scheduler := NewCleanupScheduler(10 * time.Millisecond)
+	defer scheduler.Stop()
+
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.CleanupScheduler = scheduler
+	config.Clock = clock
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.Set("key", []byte("data"), make(http.Header), time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	deadline := time.Now().Add(time.Second)
+	for cache.Size() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if cache.Size() != 0 {
+		t.Error("Expected the scheduler to remove the expired entry")
+	}
+}
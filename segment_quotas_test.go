@@ -0,0 +1,122 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestTTLCache_SegmentQuotas verifies that image churn evicts images within
+// their segment rather than small JSON entries, which plain LRU would evict
+// first as the least recently used
+func TestTTLCache_SegmentQuotas(t *testing.T) {
+	for _, segmented := range []bool{true, false} {
+		config := DefaultCacheConfig()
+		config.MaxMemoryMB = 1
+		if segmented {
+			config.SegmentQuotas = map[string]int{"image/*": 50, "application/json": 25}
+		}
+		cache := NewTTLCache(config, nil)
+
+		jsonHeaders := http.Header{"Content-Type": {"application/json"}}
+		for i := 0; i < 50; i++ {
+			cache.Set(fmt.Sprintf("json-%d", i), make([]byte, 1024), jsonHeaders, time.Hour)
+		}
+		imageHeaders := http.Header{"Content-Type": {"image/png"}}
+		for i := 0; i < 500; i++ {
+			cache.Set(fmt.Sprintf("image-%d", i), make([]byte, 20*1024), imageHeaders, time.Hour)
+		}
+
+		kept := 0
+		for i := 0; i < 50; i++ {
+			if _, found := cache.Get(fmt.Sprintf("json-%d", i)); found {
+				kept++
+			}
+		}
+		if segmented && kept != 50 {
+			t.Errorf("Expected image churn to leave all 50 JSON entries, %d left", kept)
+		}
+		if !segmented && kept != 0 {
+			t.Errorf("Expected unsegmented LRU to evict the JSON entries, %d left", kept)
+		}
+
+		if segmented {
+			segment := cache.segmentFor("image/png")
+			if used, quota := segment.memoryBytes.Load(), cache.segmentQuotaBytes(segment); used > quota {
+				t.Errorf("Expected image segment within its %d byte quota, using %d", quota, used)
+			}
+			if _, found := cache.Get("image-499"); !found {
+				t.Error("Expected the newest image to be cached")
+			}
+		}
+		cache.Close()
+	}
+}
+
+// TestTTLCache_SegmentQuotasAccounting verifies that segment memory follows
+// deletes, clears and recomputes
+func TestTTLCache_SegmentQuotasAccounting(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.SegmentQuotas = map[string]int{"application/json": 50}
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	headers := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+	cache.Set("a", make([]byte, 100), headers, time.Hour)
+	cache.Set("b", make([]byte, 100), headers, time.Hour)
+	cache.Set("c", make([]byte, 100), http.Header{"Content-Type": {"text/plain"}}, time.Hour)
+
+	segment := cache.segmentFor("application/json")
+	entry, _ := cache.Get("a")
+	if used := segment.memoryBytes.Load(); used != int64(2*entry.Size) {
+		t.Errorf("Expected segment to hold 2 entries of %d bytes, got %d", entry.Size, used)
+	}
+
+	cache.Delete("a")
+	if used := segment.memoryBytes.Load(); used != int64(entry.Size) {
+		t.Errorf("Expected %d bytes after delete, got %d", entry.Size, used)
+	}
+
+	segment.memoryBytes.Store(12345)
+	cache.Recompute()
+	if used := segment.memoryBytes.Load(); used != int64(entry.Size) {
+		t.Errorf("Expected Recompute to restore %d bytes, got %d", entry.Size, used)
+	}
+
+	cache.Clear()
+	if used := segment.memoryBytes.Load(); used != 0 {
+		t.Errorf("Expected empty segment after Clear, got %d bytes", used)
+	}
+}
+
+// TestValidateSegmentQuotas verifies quota validation for both configurations
+func TestValidateSegmentQuotas(t *testing.T) {
+	tests := []struct {
+		name    string
+		quotas  map[string]int
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]int{"image/*": 60, "application/json": 40}, false},
+		{"over 100 total", map[string]int{"image/*": 60, "application/json": 50}, true},
+		{"zero", map[string]int{"image/*": 0}, true},
+		{"empty class", map[string]int{" ": 10}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheConfig := DefaultCacheConfig()
+			cacheConfig.SegmentQuotas = tt.quotas
+			if err := cacheConfig.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("CacheConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			config := DefaultConfig()
+			config.SegmentQuotas = tt.quotas
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
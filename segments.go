@@ -0,0 +1,127 @@
+package selectcache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// cacheSegment is a content-type class limited to a share of the cache's
+// memory (see CacheConfig.SegmentQuotas). Each shard keeps a separate
+// eviction heap per segment so eviction can be confined to one segment.
+type cacheSegment struct {
+	index       int
+	quotaPct    int64
+	memoryBytes atomic.Int64
+}
+
+// newCacheSegments creates the segments for SegmentQuotas, keyed by
+// normalized content-type class and indexed in sorted class order
+func newCacheSegments(quotas map[string]int) map[string]*cacheSegment {
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	classes := make([]string, 0, len(quotas))
+	for class := range quotas {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	segments := make(map[string]*cacheSegment, len(classes))
+	for i, class := range classes {
+		segments[strings.ToLower(strings.TrimSpace(class))] = &cacheSegment{index: i, quotaPct: int64(quotas[class])}
+	}
+	return segments
+}
+
+// validateSegmentQuotas checks that every quota is a percentage and that
+// together they don't exceed the whole cache
+func validateSegmentQuotas(quotas map[string]int) error {
+	total := 0
+	for class, pct := range quotas {
+		if strings.TrimSpace(class) == "" {
+			return fmt.Errorf("segment quota content type must not be empty")
+		}
+		if pct <= 0 || pct > 100 {
+			return fmt.Errorf("segment quota for %s must be between 1 and 100 percent, got %d", class, pct)
+		}
+		total += pct
+	}
+	if total > 100 {
+		return fmt.Errorf("segment quotas must not total more than 100 percent, got %d", total)
+	}
+	return nil
+}
+
+// segmentFor returns the segment for a content type, trying its media type
+// before a "type/*" class, or nil when it belongs to no segment
+func (c *TTLCache) segmentFor(contentType string) *cacheSegment {
+	if len(c.segments) == 0 {
+		return nil
+	}
+
+	mediaType := normalizeMediaType(contentType)
+	if segment, exists := c.segments[mediaType]; exists {
+		return segment
+	}
+	if major, _, found := strings.Cut(mediaType, "/"); found {
+		return c.segments[major+"/*"]
+	}
+	return nil
+}
+
+// segmentQuotaBytes returns the memory a segment may hold
+func (c *TTLCache) segmentQuotaBytes(segment *cacheSegment) int64 {
+	return c.maxMemoryBytes.Load() * segment.quotaPct / 100
+}
+
+// overQuotaSegment returns a segment holding more than its quota, if any
+func (c *TTLCache) overQuotaSegment() *cacheSegment {
+	for _, segment := range c.segments {
+		if segment.memoryBytes.Load() > c.segmentQuotaBytes(segment) {
+			return segment
+		}
+	}
+	return nil
+}
+
+// makeSegmentRoom evicts entries from the segments of the entries about to be
+// stored until each segment has room for its new entries within its quota,
+// returning the evicted entries. Must be called without holding any shard lock.
+func (c *TTLCache) makeSegmentRoom(entries []*CacheEntry) []*CacheEntry {
+	if len(c.segments) == 0 {
+		return nil
+	}
+
+	incoming := make(map[*cacheSegment]int64)
+	for _, entry := range entries {
+		if entry.segment != nil {
+			incoming[entry.segment] += int64(entry.Size)
+		}
+	}
+
+	var evicted []*CacheEntry
+	for segment, size := range incoming {
+		evicted = append(evicted, c.trimSegment(segment, size)...)
+	}
+	return evicted
+}
+
+// trimSegment evicts entries from a segment, least valuable first, until it
+// can take incoming more bytes within its quota or is empty
+func (c *TTLCache) trimSegment(segment *cacheSegment, incoming int64) []*CacheEntry {
+	var evicted []*CacheEntry
+	for segment.memoryBytes.Load()+incoming > c.segmentQuotaBytes(segment) {
+		entry, ok := c.evictNext(segment)
+		if !ok {
+			break
+		}
+		evicted = append(evicted, entry)
+		if c.metrics != nil {
+			c.metrics.RecordEviction()
+		}
+	}
+	return evicted
+}
@@ -1,68 +1,531 @@
 // Package selectcache provides HTTP middleware for selective response caching
-// using go-cache, with content-type based filtering to exclude HTML responses.
+// backed by TTLCache, with content-type based filtering to exclude HTML responses.
 //
-// License: MIT (matches go-cache dependency)
+// License: MIT
 package selectcache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/patrickmn/go-cache"
 )
 
 // Middleware provides selective HTTP response caching
 type Middleware struct {
-	cache         *cache.Cache
-	excludeTypes  []string
-	includeStatus []int
-	hitCount      uint64 // Atomic counter for cache hits
-	missCount     uint64 // Atomic counter for cache misses
+	cache             *TTLCache
+	metrics           *CacheMetrics
+	excludeTypes      []string
+	includeStatus     []int
+	defaultTTL        time.Duration
+	cacheBuckets      map[string]time.Duration
+	maxBodyBytes      int64
+	minBodyBytes      int
+	hitMarker         string
+	statusHeader      string
+	negativeTTL       time.Duration
+	errorStatus       []int
+	logger            Logger
+	warmConcurrency   int
+	rangeRequests     bool
+	generateETag      bool
+	cachePrivate      bool
+	stripHeaders      []string
+	allowHeaders      []string
+	noCacheSetCookie  bool
+	safeMode          bool
+	requireExplicit   bool
+	compression       string
+	pathTTLs          []PathTTL
+	contentTypeTTLs   map[string]time.Duration
+	ignoreQueryParams []string
+	keyPrefix         string
+	keyHashBits       int
+	includeHost       bool
+	trimSlash         bool
+	bypass            func(*http.Request) bool
+	bypassPrefixes    []string
+	excludedRegexps   []*regexp.Regexp
+	beforeStore       func(string, *CachedResponse) bool
+	storeTransform    func(string, []byte) []byte
+	serveTransform    func(string, []byte) []byte
+	staleWhilePending bool
+	storeLimiter      *storeLimiter
+	cacheOnlyStatus   int
+	maxServeAge       time.Duration
+	exposeTTL         bool
+	immutableTTL      time.Duration
+
+	// Keys with a stale entry being revalidated, for ServeStaleWhilePending
+	revalidatingMu sync.Mutex
+	revalidating   map[string]struct{}
+
+	// Variant index so Delete can remove every header-dependent variant of a URL
+	variantsMu sync.Mutex
+	variants   map[string]map[string]struct{} // resource -> cache keys
+	variantOf  map[string]string              // cache key -> resource
+	vary       map[string][]string            // resource -> response Vary headers
+
+	// Surrogate-Key index so InvalidateTag can find tagged entries
+	tagsMu sync.Mutex
+	tagged map[string]map[string]struct{} // tag -> cache keys
+	tagsOf map[string][]string            // cache key -> tags
+
+	hitCount  uint64 // Atomic counter for cache hits
+	missCount uint64 // Atomic counter for cache misses
+}
+
+// MiddlewareStats is a snapshot of middleware cache statistics
+type MiddlewareStats struct {
+	Items       int     `json:"items"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hit_ratio"`
+	MemoryBytes int64   `json:"memory_bytes"`
 }
 
 // Config holds configuration for the caching middleware
 type Config struct {
 	// DefaultTTL is the default time-to-live for cached responses
+	// Default: 15 minutes
 	DefaultTTL time.Duration
 	// CleanupInterval is how often expired items are removed
+	// Default: 5 minutes
 	CleanupInterval time.Duration
-	// ExcludeContentTypes are MIME types that should not be cached
+	// CleanupScheduler, when set, cleans the cache from a scheduler shared
+	// with other caches instead of a goroutine per middleware, and its
+	// interval replaces CleanupInterval
+	// Default: nil (own cleanup goroutine)
+	CleanupScheduler *CleanupScheduler
+	// DisableAutoCleanup turns off background cleanup entirely, leaving
+	// expired entries in place until they are read or Cache().CleanupNow()
+	// is called
+	// Default: false
+	DisableAutoCleanup bool
+	// MaxMemoryMB caps the memory used by cached responses (bodies and
+	// headers); the least recently used entries are evicted to stay under it
+	// Default: 512
+	MaxMemoryMB int64
+	// MaxEntries caps the number of cached responses
+	// Default: 10000
+	MaxEntries int
+	// MaxEvictionBatch caps how many entries storing one response may evict.
+	// A response needing more room evicts that many and is served uncached,
+	// keeping any one request from stalling on a long run of evictions.
+	// Default: 0 (evict as many as needed)
+	MaxEvictionBatch int
+	// EvictionPolicy selects which entries are evicted when a limit is
+	// reached: EvictionPolicyLRU or EvictionPolicyLFU
+	// Default: EvictionPolicyLRU
+	EvictionPolicy string
+	// SegmentQuotas gives content-type classes ("application/json" or
+	// "image/*") a percentage of MaxMemoryMB each. A class over its quota
+	// evicts its own entries first, so large images can't push out small
+	// JSON responses. Entries in no class share what's left. New panics if
+	// the quotas total more than 100.
+	// Default: nil (one LRU/LFU order across all entries)
+	SegmentQuotas map[string]int
+	// ExcludeContentTypes are MIME types that should not be cached, matched
+	// as substrings or by major type ("image/*")
 	// Default: ["text/html", "application/xhtml+xml"]
 	ExcludeContentTypes []string
 	// IncludeStatusCodes are HTTP status codes that should be cached
 	// Default: [200]
 	IncludeStatusCodes []int
+	// CacheBuckets maps named TTL tiers (e.g. "hot", "warm", "cold") to their
+	// TTLs. Handlers select a tier per response via the X-Cache-Bucket header;
+	// absent or unknown buckets fall back to DefaultTTL.
+	CacheBuckets map[string]time.Duration
+	// MaxBodyBytes is the largest response body that will be buffered for caching.
+	// Larger responses are still streamed to the client but not cached.
+	// Default: 0 (unlimited)
+	MaxBodyBytes int64
+	// MinBodyBytes is the smallest response body that will be cached; tinier
+	// responses such as "{}" aren't worth an entry. HEAD responses, which
+	// carry no body, are exempt.
+	// Default: 0 (any size)
+	MinBodyBytes int
+	// CacheHitMarkerHeader is the response header that marks a response as
+	// already served from a cache. Responses carrying it with a HIT value are
+	// not re-cached, preventing cache-of-a-cache artifacts.
+	// Default: "X-Cache-Status"
+	CacheHitMarkerHeader string
+	// CacheStatusHeader names the response header reporting how a cacheable
+	// request was handled: HIT, MISS, STALE, STALE-ERROR or BYPASS. Requests
+	// with uncacheable methods get no header. When chaining caches, set
+	// CacheHitMarkerHeader to match.
+	// Default: "X-Cache-Status"
+	CacheStatusHeader string
+	// CacheableErrorStatus are error status codes (e.g. 404, 410) that should be
+	// negatively cached using NegativeTTL instead of the normal TTL
+	// Default: [] (negative caching disabled)
+	CacheableErrorStatus []int
+	// NegativeTTL is the time-to-live for cached error responses
+	// Default: 1 minute
+	NegativeTTL time.Duration
+	// Logger receives structured cache events such as hits, misses, and stores
+	// Default: nil (no logging)
+	Logger Logger
+	// WarmConcurrency is the number of concurrent requests issued by Warm
+	// Default: 4
+	WarmConcurrency int
+	// EnableRangeRequests serves single-range Range requests from every cached
+	// 200 response. When false, ranges are only honoured for cached responses
+	// that advertise Accept-Ranges: bytes.
+	// Default: false
+	EnableRangeRequests bool
+	// GenerateETag adds a strong ETag derived from the body to cached
+	// responses that don't already carry one, so later requests can be
+	// answered with 304 Not Modified via If-None-Match.
+	// Default: false
+	GenerateETag bool
+	// CachePrivateResponses caches responses to requests carrying an
+	// Authorization header (keyed per credential) even without
+	// Cache-Control: public. When false, such responses are treated as
+	// private and only cached when explicitly marked public.
+	// Default: false
+	CachePrivateResponses bool
+	// StripHeaders are response headers removed before a response is cached,
+	// so per-user state such as Set-Cookie is never replayed to other clients
+	// Default: DefaultStripHeaders() (Set-Cookie and hop-by-hop headers)
+	StripHeaders []string
+	// AllowHeaders, when non-empty, caches only the listed response headers.
+	// StripHeaders still applies on top of the allowlist.
+	// Default: [] (all headers not stripped are cached)
+	AllowHeaders []string
+	// NoCacheOnSetCookie treats responses carrying Set-Cookie as
+	// non-cacheable. Such responses usually start or refresh a session, and
+	// caching them would replay one user's page to others even with the
+	// cookie itself stripped.
+	// Default: true
+	NoCacheOnSetCookie bool
+	// SafeMode is a single switch for conservative caching. It enables
+	// NoCacheOnSetCookie and always strips Set-Cookie, disables
+	// CachePrivateResponses so responses to requests with Authorization are
+	// only cached when marked Cache-Control: public, and skips responses
+	// marked Cache-Control: no-store, no-cache or private. Only GET and HEAD
+	// requests are cached in any mode.
+	// Default: false
+	SafeMode bool
+	// RequireExplicitCacheControl caches only responses the origin marked
+	// cacheable with Cache-Control public or a positive s-maxage or max-age
+	// (or a positive Surrogate-Control max-age), on top of the other rules
+	// Default: false (responses without directives are cached)
+	RequireExplicitCacheControl bool
+	// CompressionAlgorithm compresses text-like response bodies before they
+	// are stored: CompressionNone, CompressionGzip, CompressionBrotli or
+	// CompressionZstd. Clients accepting the algorithm are served the stored
+	// bytes with a matching Content-Encoding; others get them decompressed.
+	// Responses already carrying a Content-Encoding are stored as they are.
+	// Default: CompressionNone
+	CompressionAlgorithm string
+	// PathTTLs override DefaultTTL for matching request paths, e.g.
+	// {Pattern: "/api/prices/*", TTL: 30 * time.Second}. The first matching
+	// pattern wins; cache buckets and Cache-Control still take precedence.
+	// Default: [] (no path overrides)
+	PathTTLs []PathTTL
+	// ContentTypeTTLs override DefaultTTL by response Content-Type, such as
+	// "application/grpc-web+proto" or "application/x-protobuf". Keys match
+	// exactly, then by media type without parameters, then as "type/*".
+	// PathTTLs take precedence.
+	// Default: nil (no content type overrides)
+	ContentTypeTTLs map[string]time.Duration
+	// IgnoreQueryParams are query parameters left out of cache keys, such as
+	// tracking parameters. A trailing "*" matches by prefix ("utm_*").
+	// Query parameters are always sorted, so reordered URLs share entries.
+	// Default: [] (all parameters are significant)
+	IgnoreQueryParams []string
+	// KeyHashBits is how many bits of the SHA-256 request hash cache keys
+	// keep: 64, 128, or 256 for the full hash. By the birthday bound, n keys
+	// collide with probability about n²/2^(bits+1): at 64 bits that is ~3%
+	// for a billion keys and ~39% for 2^32, a wrong response served for the
+	// losing URL; at 128 bits it stays negligible at any practical scale.
+	// New panics on other values.
+	// Default: 64
+	KeyHashBits int
+	// KeyPrefix is prepended to every cache key, namespacing entries when
+	// several services or tenants share a store. Delete and
+	// Cache().DeleteByPrefix only ever remove keys in the middleware's own
+	// namespace.
+	// Default: "" (no namespace)
+	KeyPrefix string
+	// IncludeHostInKey adds the request's Host to cache keys, so virtual
+	// hosts served by one handler don't share entries for the same path
+	// Default: true
+	IncludeHostInKey bool
+	// NormalizeTrailingSlash strips a trailing slash from paths before
+	// building cache keys, so "/api/users/" and "/api/users" share entries.
+	// The root path "/" is left alone.
+	// Default: false
+	NormalizeTrailingSlash bool
+	// BypassFunc, when it returns true for a request, skips the cache
+	// entirely: nothing is served from or stored in it, and the response is
+	// marked X-Cache-Status: BYPASS. It runs before the cache key is computed,
+	// so it takes precedence over any key customization.
+	// Default: nil (never bypass)
+	BypassFunc func(*http.Request) bool
+	// BypassPathPrefixes are request path prefixes, such as "/api/write/",
+	// that are never cached. Matching requests go straight to the handler
+	// without computing a cache key or recording the response, making this a
+	// cheaper BypassFunc for routes known to be uncacheable.
+	// Default: [] (no bypassed paths)
+	BypassPathPrefixes []string
+	// ExcludedPaths are request paths that are never cached, whatever their
+	// content type. Patterns beginning with "/" match as path prefixes
+	// ("/admin/"); others are regular expressions matched against the path
+	// (`\.php$`). Like BypassPathPrefixes, matching requests skip the cache
+	// before any key or recorder is built. New panics on an invalid regular
+	// expression; NewWithError returns it as an error.
+	// Default: [] (no excluded paths)
+	ExcludedPaths []string
+	// BeforeStore is called with each response about to be cached, after
+	// header filtering. Returning false vetoes caching; changes to resp, such
+	// as removing a generated_at field from the body, are what gets stored.
+	// resp is a copy, so the response already sent to the client is
+	// unaffected.
+	// Default: nil (store responses unchanged)
+	BeforeStore func(key string, resp *CachedResponse) bool
+	// StoreTransform rewrites response bodies before they are stored, e.g.
+	// minifying JSON to save memory. It runs after BeforeStore and before
+	// compression, gets a copy of the body, and is skipped for bodies the
+	// origin sent with a Content-Encoding. The response already sent to the
+	// client is unaffected. A strong ETag is weakened when the body changes.
+	// Default: nil (store bodies unchanged)
+	StoreTransform func(contentType string, body []byte) []byte
+	// ServeTransform rewrites cached bodies each time they are served
+	// without a Content-Encoding, gets a copy of the body, and leaves the
+	// stored entry unchanged
+	// Default: nil (serve bodies as stored)
+	ServeTransform func(contentType string, body []byte) []byte
+	// ServeStaleWhilePending answers requests for a stale entry with that
+	// entry, marked X-Cache-Status: STALE, while another request is already
+	// revalidating it, rather than sending every such request to the origin.
+	// Unlike a stale-while-revalidate window this only applies while a
+	// revalidation is in flight. Entries are only kept past their freshness
+	// lifetime when responses carry Cache-Control stale-if-error.
+	// Default: false
+	ServeStaleWhilePending bool
+	// MaxStoresPerSecond caps how many responses are stored each second, so
+	// a burst of unique URLs such as a crawl can't evict the hot set. Over
+	// the limit, responses are served normally but not cached, and
+	// cache_store_throttled is recorded in the metrics. Bursts of up to a
+	// second's worth of stores are allowed.
+	// Default: 0 (unlimited)
+	MaxStoresPerSecond int
+	// CacheOnlyMissStatus is the status CacheOnlyHandler answers misses with
+	// Default: 504 (Gateway Timeout, as for Cache-Control: only-if-cached)
+	CacheOnlyMissStatus int
+	// MaxServeAge caps how long after storing an entry may be served,
+	// whatever TTL it was stored with. Older entries are treated as misses,
+	// and are never served stale either.
+	// Default: 0 (no cap)
+	MaxServeAge time.Duration
+	// ExposeTTLHeader adds an X-Cache-TTL header to responses served from
+	// cache, giving the whole seconds left until the entry expires
+	// Default: false
+	ExposeTTLHeader bool
+	// ImmutableTTL is how long responses marked Cache-Control: immutable are
+	// cached, in place of their max-age; a handler's X-Cache-TTL, a cache
+	// bucket or Surrogate-Control still win. Such entries are never
+	// revalidated: they don't go stale, so stale-if-error doesn't apply.
+	// Default: 0 (immutable is ignored)
+	ImmutableTTL time.Duration
 }
 
+// CacheBucketHeader is the response header handlers use to select a named TTL bucket
+const CacheBucketHeader = "X-Cache-Bucket"
+
+// CacheTTLHeader is the response header handlers set to cache one response
+// for that many seconds, overriding the configured TTLs; zero keeps it out of
+// the cache. It is never stored or passed on. With ExposeTTLHeader, the cache
+// sends its own on hits, carrying the seconds left until the entry expires.
+const CacheTTLHeader = "X-Cache-TTL"
+
 // DefaultConfig returns sensible defaults for the middleware
 func DefaultConfig() Config {
 	return Config{
 		DefaultTTL:      15 * time.Minute,
 		CleanupInterval: 5 * time.Minute,
+		MaxMemoryMB:     512,
+		MaxEntries:      10000,
+		EvictionPolicy:  EvictionPolicyLRU,
 		ExcludeContentTypes: []string{
 			"text/html",
 			"application/xhtml+xml",
 		},
-		IncludeStatusCodes: []int{200},
+		IncludeStatusCodes:   []int{200},
+		CacheHitMarkerHeader: "X-Cache-Status",
+		CacheStatusHeader:    "X-Cache-Status",
+		NegativeTTL:          1 * time.Minute,
+		WarmConcurrency:      4,
+		StripHeaders:         DefaultStripHeaders(),
+		NoCacheOnSetCookie:   true,
+		CompressionAlgorithm: CompressionNone,
+		CacheOnlyMissStatus:  http.StatusGatewayTimeout,
+		IncludeHostInKey:     true,
+		KeyHashBits:          DefaultKeyHashBits,
 	}
 }
 
-// New creates a new selective cache middleware with the given configuration
+// Validate checks the configuration for values New can't use
+func (c Config) Validate() error {
+	if _, _, err := compileExcludedPaths(c.ExcludedPaths); err != nil {
+		return err
+	}
+	if err := validateKeyHashBits(c.KeyHashBits); err != nil {
+		return err
+	}
+	return validateSegmentQuotas(c.SegmentQuotas)
+}
+
+// New creates a new selective cache middleware with the given configuration.
+// Call Close when the middleware is no longer needed to stop the cache's
+// cleanup goroutine. New panics on an invalid configuration; use NewWithError
+// to handle it instead.
 func New(config Config) *Middleware {
+	m, err := NewWithError(config)
+	if err != nil {
+		panic(err.Error())
+	}
+	return m
+}
+
+// NewWithError is New for configurations built at runtime: it returns the
+// error from Validate instead of panicking.
+func NewWithError(config Config) (*Middleware, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid middleware configuration: %w", err)
+	}
+	excludedPrefixes, excludedRegexps, _ := compileExcludedPaths(config.ExcludedPaths)
+
+	if config.DefaultTTL <= 0 {
+		config.DefaultTTL = DefaultConfig().DefaultTTL
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = DefaultConfig().CleanupInterval
+	}
+	if config.MaxMemoryMB <= 0 {
+		config.MaxMemoryMB = DefaultConfig().MaxMemoryMB
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultConfig().MaxEntries
+	}
+	if config.EvictionPolicy == "" {
+		config.EvictionPolicy = DefaultConfig().EvictionPolicy
+	}
 	if len(config.ExcludeContentTypes) == 0 {
 		config.ExcludeContentTypes = DefaultConfig().ExcludeContentTypes
 	}
 	if len(config.IncludeStatusCodes) == 0 {
 		config.IncludeStatusCodes = DefaultConfig().IncludeStatusCodes
 	}
+	if config.CacheHitMarkerHeader == "" {
+		config.CacheHitMarkerHeader = DefaultConfig().CacheHitMarkerHeader
+	}
+	if config.CacheStatusHeader == "" {
+		config.CacheStatusHeader = DefaultConfig().CacheStatusHeader
+	}
+	if config.NegativeTTL <= 0 {
+		config.NegativeTTL = DefaultConfig().NegativeTTL
+	}
+	if config.CacheOnlyMissStatus == 0 {
+		config.CacheOnlyMissStatus = DefaultConfig().CacheOnlyMissStatus
+	}
+	if len(config.StripHeaders) == 0 {
+		config.StripHeaders = DefaultConfig().StripHeaders
+	}
+	if config.SafeMode {
+		config = withSafeMode(config)
+	}
 
-	return &Middleware{
-		cache:         cache.New(config.DefaultTTL, config.CleanupInterval),
-		excludeTypes:  config.ExcludeContentTypes,
-		includeStatus: config.IncludeStatusCodes,
+	m := &Middleware{
+		metrics:           NewCacheMetrics(true),
+		excludeTypes:      config.ExcludeContentTypes,
+		includeStatus:     config.IncludeStatusCodes,
+		defaultTTL:        config.DefaultTTL,
+		cacheBuckets:      config.CacheBuckets,
+		maxBodyBytes:      config.MaxBodyBytes,
+		minBodyBytes:      config.MinBodyBytes,
+		hitMarker:         config.CacheHitMarkerHeader,
+		statusHeader:      http.CanonicalHeaderKey(config.CacheStatusHeader),
+		negativeTTL:       config.NegativeTTL,
+		errorStatus:       config.CacheableErrorStatus,
+		logger:            config.Logger,
+		warmConcurrency:   config.WarmConcurrency,
+		rangeRequests:     config.EnableRangeRequests,
+		generateETag:      config.GenerateETag,
+		cachePrivate:      config.CachePrivateResponses,
+		stripHeaders:      config.StripHeaders,
+		allowHeaders:      config.AllowHeaders,
+		noCacheSetCookie:  config.NoCacheOnSetCookie,
+		safeMode:          config.SafeMode,
+		requireExplicit:   config.RequireExplicitCacheControl,
+		compression:       compressionAlgorithm(config.CompressionAlgorithm),
+		pathTTLs:          config.PathTTLs,
+		contentTypeTTLs:   config.ContentTypeTTLs,
+		ignoreQueryParams: config.IgnoreQueryParams,
+		keyPrefix:         config.KeyPrefix,
+		keyHashBits:       config.KeyHashBits,
+		includeHost:       config.IncludeHostInKey,
+		trimSlash:         config.NormalizeTrailingSlash,
+		bypass:            config.BypassFunc,
+		bypassPrefixes:    append(append([]string(nil), config.BypassPathPrefixes...), excludedPrefixes...),
+		excludedRegexps:   excludedRegexps,
+		beforeStore:       config.BeforeStore,
+		storeTransform:    config.StoreTransform,
+		serveTransform:    config.ServeTransform,
+		staleWhilePending: config.ServeStaleWhilePending,
+		storeLimiter:      newStoreLimiter(config.MaxStoresPerSecond),
+		cacheOnlyStatus:   config.CacheOnlyMissStatus,
+		maxServeAge:       config.MaxServeAge,
+		exposeTTL:         config.ExposeTTLHeader,
+		immutableTTL:      config.ImmutableTTL,
+		revalidating:      make(map[string]struct{}),
+		variants:          make(map[string]map[string]struct{}),
+		variantOf:         make(map[string]string),
+		vary:              make(map[string][]string),
+		tagged:            make(map[string]map[string]struct{}),
+		tagsOf:            make(map[string][]string),
 	}
+
+	// Responses arrive already filtered by the middleware; the cache only
+	// re-applies the strip list, which leaves them unchanged
+	cacheConfig := DefaultCacheConfig()
+	cacheConfig.DefaultTTL = config.DefaultTTL
+	cacheConfig.CleanupInterval = config.CleanupInterval
+	cacheConfig.CleanupScheduler = config.CleanupScheduler
+	cacheConfig.DisableAutoCleanup = config.DisableAutoCleanup
+	cacheConfig.MaxMemoryMB = config.MaxMemoryMB
+	cacheConfig.MaxEntries = config.MaxEntries
+	cacheConfig.EvictionPolicy = config.EvictionPolicy
+	cacheConfig.MaxEvictionBatch = config.MaxEvictionBatch
+	cacheConfig.SegmentQuotas = config.SegmentQuotas
+	cacheConfig.KeyPrefix = config.KeyPrefix
+	cacheConfig.StripHeaders = config.StripHeaders
+	cacheConfig.OnEvict = func(key string, _ *CacheEntry) {
+		m.unindexVariant(key)
+		m.unindexTags(key)
+		if m.logger != nil {
+			m.logger.OnEvict(key)
+		}
+	}
+	m.cache = NewTTLCache(cacheConfig, m.metrics)
+
+	return m, nil
+}
+
+// Close stops the cache's background cleanup. The middleware must not be
+// used afterwards.
+func (m *Middleware) Close() {
+	m.cache.Close()
 }
 
 // NewDefault creates a middleware with default settings:
@@ -77,8 +540,16 @@ func NewDefault() *Middleware {
 // Handler wraps an http.Handler with selective caching
 func (m *Middleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only cache GET and HEAD requests
-		if !m.isCacheableMethod(r.Method) {
+		// Only cache GET and HEAD requests, never protocol upgrades. These
+		// checks come before any key or recorder is built, so uncacheable
+		// traffic passes through without allocating.
+		if !m.isCacheableMethod(r.Method) || r.Header.Get("Upgrade") != "" || m.isBypassedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.bypass != nil && m.bypass(r) {
+			w.Header().Set(m.statusHeader, "BYPASS")
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -86,7 +557,26 @@ func (m *Middleware) Handler(next http.Handler) http.Handler {
 		key := m.createCacheKey(r)
 
 		// Try to serve from cache first
-		if m.tryServeFromCache(w, r, key) {
+		served, stale := m.tryServeFromCache(w, r, key)
+		if served {
+			return
+		}
+
+		// The client went away during the lookup; don't bother the origin
+		if r.Context().Err() != nil {
+			return
+		}
+
+		// Revalidate stale entries, keeping them as a fallback on origin errors
+		if stale != nil {
+			if m.staleWhilePending {
+				if !m.claimRevalidation(key) {
+					m.serveStale(w, r, key, stale)
+					return
+				}
+				defer m.releaseRevalidation(key)
+			}
+			m.revalidateStale(w, r, key, next, stale)
 			return
 		}
 
@@ -106,45 +596,95 @@ func (m *Middleware) createCacheKey(r *http.Request) string {
 	// but treat GET and HEAD as the same for caching purposes (HEAD reuses GET cache)
 	headers := make(map[string]string)
 
-	// Include caching-relevant headers
-	for _, header := range []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization"} {
+	// Include caching-relevant headers, plus any the resource's responses
+	// declared in Vary
+	for _, header := range keyHeaders {
 		if value := r.Header.Get(header); value != "" {
 			headers[header] = value
 		}
 	}
-
-	query := ""
-	if r.URL.RawQuery != "" {
-		query = r.URL.RawQuery
+	for _, header := range m.varyFor(m.variantResource(r)) {
+		if values := r.Header.Values(header); len(values) > 0 {
+			headers[header] = strings.Join(values, ", ")
+		}
+	}
+	if m.includeHost {
+		addHostKeyPart(headers, r.Host)
 	}
 
+	query := NormalizeQuery(r.URL.RawQuery, m.ignoreQueryParams)
+
 	// For HEAD requests, use GET method in cache key so they share cache entries
 	method := r.Method
 	if method == "HEAD" {
 		method = "GET"
 	}
 
-	return GenerateCacheKey(method, r.URL.Path, query, headers)
+	return m.keyPrefix + GenerateCacheKeyBits(method, keyPath(r.URL.Path, m.trimSlash), query, headers, m.keyHashBits)
 }
 
 // shouldCache determines if a response should be cached
 func (m *Middleware) shouldCache(recorder *ResponseRecorder) bool {
-	// Check status code
-	statusOK := false
-	for _, code := range m.includeStatus {
-		if recorder.StatusCode() == code {
-			statusOK = true
-			break
+	// Responses that exceeded the body limit were only partially buffered,
+	// and flushed responses are streams
+	if recorder.Overflowed() || recorder.Streamed() {
+		return false
+	}
+
+	// Tiny bodies aren't worth an entry
+	if recorder.requestMethod != http.MethodHead && len(recorder.Body()) < m.minBodyBytes {
+		return false
+	}
+
+	// Check status code, allowing negatively cacheable error statuses. A 206
+	// holding the whole representation is judged, and stored, as a 200.
+	status := recorder.StatusCode()
+	if status == http.StatusPartialContent && coversWholeRepresentation(recorder.Headers(), recorder.Body()) {
+		status = http.StatusOK
+	}
+	if !containsStatus(m.includeStatus, status) && !m.isNegativeStatus(status) {
+		return false
+	}
+
+	// Don't re-cache responses already served from an upstream cache
+	if isMarkedCacheHit(recorder.Headers(), m.hitMarker) {
+		return false
+	}
+
+	// A zero freshness lifetime means the response must not be reused.
+	// X-Cache-TTL and Surrogate-Control address this cache ahead of
+	// Cache-Control.
+	cc := parseCacheControl(recorder.Headers())
+	handlerTTL, hasHandlerTTL := headerTTL(recorder.Headers())
+	if hasHandlerTTL {
+		if handlerTTL == 0 {
+			return false
 		}
+	} else if ttl, ok := surrogateMaxAge(recorder.Headers()); ok {
+		if ttl == 0 {
+			return false
+		}
+	} else if ttl, ok := remainingSharedMaxAge(recorder.Headers()); ok && ttl == 0 {
+		return false
 	}
-	if !statusOK {
+	if m.safeMode && cc.forbidsSharedStorage() {
+		return false
+	}
+	if m.requireExplicit && !cc.explicitlyCacheable() && !hasHandlerTTL {
+		if ttl, ok := surrogateMaxAge(recorder.Headers()); !ok || ttl == 0 {
+			return false
+		}
+	}
+
+	// Cookies are per-user state
+	if m.noCacheSetCookie && recorder.Headers().Get("Set-Cookie") != "" {
 		return false
 	}
 
 	// Check content type exclusions
-	contentType := strings.ToLower(recorder.Headers().Get("Content-Type"))
+	contentType := recorder.Headers().Get("Content-Type")
 	for _, excludeType := range m.excludeTypes {
-		if strings.Contains(contentType, strings.ToLower(excludeType)) {
+		if contentTypeMatches(contentType, excludeType) {
 			return false
 		}
 	}
@@ -152,15 +692,52 @@ func (m *Middleware) shouldCache(recorder *ResponseRecorder) bool {
 	return true
 }
 
+// isPrivateResponse reports whether a response to an authenticated request must
+// stay private. Unless CachePrivateResponses is set, responses to requests with
+// an Authorization header are only cached when marked Cache-Control: public.
+func (m *Middleware) isPrivateResponse(r *http.Request, headers http.Header) bool {
+	if m.cachePrivate || r.Header.Get("Authorization") == "" {
+		return false
+	}
+	return !parseCacheControl(headers).has("public")
+}
+
 // writeCachedResponse writes a cached response to the ResponseWriter
 func (m *Middleware) writeCachedResponse(w http.ResponseWriter, r *http.Request, cached *CachedResponse) {
+	m.writeCachedResponseWithStatus(w, r, cached, "HIT")
+}
+
+// writeCachedResponseWithStatus writes a cached response with the given cache status value
+func (m *Middleware) writeCachedResponseWithStatus(w http.ResponseWriter, r *http.Request, cached *CachedResponse, cacheStatus string) {
+	cached = m.transformServedBody(cached)
+
 	// Set headers
 	for k, v := range cached.Headers {
 		w.Header()[k] = v
 	}
 
-	// Add cache hit header for debugging
-	w.Header().Set("X-Cache-Status", "HIT")
+	// Add cache status header for debugging
+	w.Header().Set(m.statusHeader, cacheStatus)
+	m.setAgeHeaders(w.Header(), cached)
+	m.setTTLHeader(w.Header(), cached)
+
+	if m.writeNotModified(w, r, cached) {
+		return
+	}
+	if m.writeRangeResponse(w, r, cached) {
+		return
+	}
+
+	// The stored Content-Length may not match the cached body; HEAD responses
+	// cached without a body keep theirs since it describes the GET body.
+	// Trailers need a chunked response, so they go without one.
+	withTrailers := len(cached.Trailers) > 0 && r.Method != http.MethodHead
+	if withTrailers {
+		w.Header().Del("Content-Length")
+		declareTrailers(w.Header(), cached.Trailers)
+	} else if bodyAllowedForStatus(cached.StatusCode) && (r.Method != http.MethodHead || len(cached.Body) > 0) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(cached.Body)))
+	}
 
 	w.WriteHeader(cached.StatusCode)
 
@@ -168,37 +745,296 @@ func (m *Middleware) writeCachedResponse(w http.ResponseWriter, r *http.Request,
 	if r.Method != http.MethodHead {
 		w.Write(cached.Body)
 	}
+	if withTrailers {
+		writeTrailers(w.Header(), cached.Trailers)
+	}
+}
+
+// declareTrailers announces trailer names in the Trailer header, which must
+// happen before the header is written
+func declareTrailers(header, trailers http.Header) {
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	header.Set("Trailer", strings.Join(names, ", "))
+}
+
+// writeTrailers sets declared trailer values once the body has been written
+func writeTrailers(header, trailers http.Header) {
+	for name, values := range trailers {
+		header[name] = append([]string(nil), values...)
+	}
+}
+
+// bodyAllowedForStatus reports whether a response with the given status may
+// carry a body, and so a Content-Length (RFC 9110 8.6)
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status < 200:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// setAgeHeaders sets the standard Age header from the time the response has
+// spent in the cache (plus any upstream Age) and reduces Cache-Control
+// max-age and s-maxage by the same amount
+func (m *Middleware) setAgeHeaders(headers http.Header, cached *CachedResponse) {
+	if cached.StoreTime.IsZero() {
+		return
+	}
+
+	age := m.cache.clock.Now().Sub(cached.StoreTime) + upstreamAge(cached.Headers)
+	headers.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+
+	if values := cached.Headers.Values("Cache-Control"); len(values) > 0 {
+		reduced := make([]string, len(values))
+		for i, value := range values {
+			reduced[i] = reduceMaxAge(value, age)
+		}
+		headers["Cache-Control"] = reduced
+	}
+}
+
+// setTTLHeader reports the seconds left until the cached entry expires, when
+// ExposeTTLHeader is enabled
+func (m *Middleware) setTTLHeader(headers http.Header, cached *CachedResponse) {
+	if !m.exposeTTL || cached.ExpiresAt.IsZero() {
+		return
+	}
+
+	remaining := cached.ExpiresAt.Sub(m.cache.clock.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	headers.Set(CacheTTLHeader, strconv.FormatInt(int64(remaining/time.Second), 10))
 }
 
 // Stats returns cache statistics
 func (m *Middleware) Stats() (itemCount int, hitCount, missCount uint64) {
-	return m.cache.ItemCount(), atomic.LoadUint64(&m.hitCount), atomic.LoadUint64(&m.missCount)
+	return m.cache.Size(), atomic.LoadUint64(&m.hitCount), atomic.LoadUint64(&m.missCount)
+}
+
+// DetailedStats returns cache statistics including approximate memory usage,
+// the sum of cached body and header sizes
+func (m *Middleware) DetailedStats() MiddlewareStats {
+	stats := MiddlewareStats{
+		Items:       m.cache.Size(),
+		Hits:        atomic.LoadUint64(&m.hitCount),
+		Misses:      atomic.LoadUint64(&m.missCount),
+		MemoryBytes: int64(m.cache.MemoryUsage()),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
 }
 
 // Clear removes all cached responses
 func (m *Middleware) Clear() {
-	m.cache.Flush()
+	m.variantsMu.Lock()
+	m.cache.Clear()
+	m.variants = make(map[string]map[string]struct{})
+	m.variantOf = make(map[string]string)
+	m.vary = make(map[string][]string)
+	m.variantsMu.Unlock()
+
+	m.tagsMu.Lock()
+	m.tagged = make(map[string]map[string]struct{})
+	m.tagsOf = make(map[string][]string)
+	m.tagsMu.Unlock()
+}
+
+// GetMetrics returns the cache's performance metrics
+func (m *Middleware) GetMetrics() *CacheMetrics {
+	return m.metrics
+}
+
+// Cache returns the TTLCache backing the middleware, for management
+// operations such as Entries, Delete, DeleteByPrefix, Touch or Recompute. Mutating it directly
+// is supported for advanced invalidation: entries removed through Delete or
+// expiry are also dropped from the middleware's variant index. Use the
+// middleware's Clear rather than the cache's, which bypasses that index.
+// Keys are the middleware's hashed cache keys, including any KeyPrefix.
+func (m *Middleware) Cache() *TTLCache {
+	return m.cache
 }
 
 // GetCacheForTesting returns the underlying cache for testing purposes
-// This method should only be used in tests
-func (m *Middleware) GetCacheForTesting() *cache.Cache {
+//
+// Deprecated: Use Cache.
+func (m *Middleware) GetCacheForTesting() *TTLCache {
 	return m.cache
 }
 
-// Delete removes a specific cached response by URL
-// It reconstructs the cache key using the same logic as requests
+// Delete removes all cached variants of a URL, including entries keyed on
+// request headers such as Accept-Encoding. With IncludeHostInKey an absolute
+// URL only affects its own host, while a bare path is removed on every host.
 func (m *Middleware) Delete(url string) {
-	// Create a minimal request to generate the cache key
+	m.deleteURL(url)
+}
+
+// deleteURL removes every cached variant of a URL and reports how many
+// entries were removed
+func (m *Middleware) deleteURL(url string) int {
+	// Create a minimal request to identify the resource
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		// Invalid URL - nothing to delete
+		return 0
+	}
+
+	resource := m.variantResource(req)
+	anyHost := m.includeHost && req.Host == ""
+
+	// Collect keys first: deleting fires OnEvict, which takes variantsMu
+	m.variantsMu.Lock()
+	keys := make([]string, 0, len(m.variants[resource]))
+	for key := range m.variants[resource] {
+		keys = append(keys, key)
+	}
+	if anyHost {
+		for indexed, variants := range m.variants {
+			if host, path := splitResourceHost(indexed); host == "" || path != resource {
+				continue
+			}
+			for key := range variants {
+				keys = append(keys, key)
+			}
+		}
+	}
+	m.variantsMu.Unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		if m.cache.Delete(key) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// variantResource identifies the resource a request refers to, ignoring the
+// headers that distinguish cache variants. The query is normalized the same
+// way as in cache keys, and with IncludeHostInKey the host is part of it, so
+// each virtual host's variants and learned Vary headers are kept apart.
+func (m *Middleware) variantResource(r *http.Request) string {
+	resource := keyPath(r.URL.Path, m.trimSlash)
+	if query := NormalizeQuery(r.URL.RawQuery, m.ignoreQueryParams); query != "" {
+		resource += "?" + query
+	}
+	if m.includeHost {
+		resource = strings.ToLower(r.Host) + resource
+	}
+	return resource
+}
+
+// splitResourceHost splits a resource from variantResource into its host,
+// empty when it has none, and its path and query. Hosts never contain a
+// slash, so the path starts at the first one.
+func splitResourceHost(resource string) (host, path string) {
+	if i := strings.IndexByte(resource, '/'); i > 0 {
+		return resource[:i], resource[i:]
+	}
+	return "", resource
+}
+
+// indexVariant records a cache key as a variant of a resource
+func (m *Middleware) indexVariant(resource, key string) {
+	m.variantsMu.Lock()
+	defer m.variantsMu.Unlock()
+
+	keys, exists := m.variants[resource]
+	if !exists {
+		keys = make(map[string]struct{})
+		m.variants[resource] = keys
+	}
+	keys[key] = struct{}{}
+	m.variantOf[key] = resource
+}
+
+// indexStored adds a just-stored key to the variant and tag indexes. If the
+// entry was evicted before the indexes were written, OnEvict has already run
+// and left nothing to undo them, so the key is checked again afterwards.
+func (m *Middleware) indexStored(resource, key string, tags []string) {
+	m.indexVariant(resource, key)
+	m.indexTags(key, tags)
+	if _, found := m.cache.peek(key); !found {
+		m.unindexVariant(key)
+		m.unindexTags(key)
+	}
+}
+
+// unindexVariant removes a cache key from the variant index
+func (m *Middleware) unindexVariant(key string) {
+	m.variantsMu.Lock()
+	defer m.variantsMu.Unlock()
+
+	resource, exists := m.variantOf[key]
+	if !exists {
 		return
 	}
+	delete(m.variantOf, key)
+	delete(m.variants[resource], key)
+	if len(m.variants[resource]) == 0 {
+		delete(m.variants, resource)
+		delete(m.vary, resource)
+	}
+}
 
-	// Generate the cache key using the same logic as requests
-	key := m.createCacheKey(req)
-	m.cache.Delete(key)
+// PurgeResult describes the outcome of a purge request
+type PurgeResult struct {
+	Operation       string `json:"operation"`
+	URL             string `json:"url,omitempty"`
+	Tag             string `json:"tag,omitempty"`
+	EntriesAffected int    `json:"entries_affected"`
+}
+
+// PurgeHandler returns an http.Handler for purging the cache. It accepts
+// DELETE and POST requests: with a url query parameter it deletes that URL,
+// with a tag parameter it invalidates that Surrogate-Key tag, otherwise it
+// clears the entire cache. The result is returned as JSON.
+func (m *Middleware) PurgeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "DELETE, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var result PurgeResult
+		if url := r.URL.Query().Get("url"); url != "" {
+			result = PurgeResult{Operation: "delete", URL: url, EntriesAffected: m.deleteURL(url)}
+		} else if tag := r.URL.Query().Get("tag"); tag != "" {
+			result = PurgeResult{Operation: "invalidate_tag", Tag: tag, EntriesAffected: m.InvalidateTag(tag)}
+		} else {
+			result = PurgeResult{Operation: "clear", EntriesAffected: m.cache.Size()}
+			m.Clear()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// isBypassedPath reports whether a request path is under a
+// BypassPathPrefixes entry or matches an ExcludedPaths pattern
+func (m *Middleware) isBypassedPath(path string) bool {
+	for _, prefix := range m.bypassPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	for _, re := range m.excludedRegexps {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
 }
 
 // isCacheableMethod checks if the HTTP method is cacheable
@@ -206,45 +1042,313 @@ func (m *Middleware) isCacheableMethod(method string) bool {
 	return method == http.MethodGet || method == http.MethodHead
 }
 
-// tryServeFromCache attempts to serve a response from cache
-func (m *Middleware) tryServeFromCache(w http.ResponseWriter, r *http.Request, key string) bool {
-	cached, found := m.cache.Get(key)
+// tryServeFromCache attempts to serve a response from cache. If the cached
+// response is stale it is not served but returned for revalidation.
+func (m *Middleware) tryServeFromCache(w http.ResponseWriter, r *http.Request, key string) (bool, *CachedResponse) {
+	cachedResponse, found := m.lookup(r.Context(), key)
 	if !found {
-		return false
+		return false, nil
 	}
-
-	cachedResponse, ok := cached.(*CachedResponse)
-	if !ok {
-		// Invalid cached data - remove it
+	cachedResponse, err := negotiateEncoding(r, cachedResponse)
+	if err != nil {
+		// An entry that can't be decoded is useless; fetch a fresh copy
+		m.metrics.RecordError("decompress_failed")
+		if m.logger != nil {
+			m.logger.OnError("decompress", err)
+		}
 		m.cache.Delete(key)
-		return false
+		return false, nil
+	}
+
+	if cachedResponse.IsStale(m.cache.clock.Now()) {
+		return false, cachedResponse
 	}
 
 	atomic.AddUint64(&m.hitCount, 1)
+	m.metrics.RecordHitMethod(r.Method)
+	if m.logger != nil {
+		m.logger.OnHit(key, r.URL.Path)
+	}
 	m.writeCachedResponse(w, r, cachedResponse)
-	return true
+	return true, nil
+}
+
+// lookup retrieves a cached response through TTLCache.GetContext, so the
+// request context reaches the store and a client disconnect or deadline
+// aborts the lookup.
+func (m *Middleware) lookup(ctx context.Context, key string) (*CachedResponse, bool) {
+	cached, found := m.cache.getResponseContext(ctx, key)
+	if !found {
+		// Tell an aborted lookup apart from a miss for the logger
+		if err := ctx.Err(); err != nil && m.logger != nil {
+			m.logger.OnError("get", err)
+		}
+		return nil, false
+	}
+	if m.exceedsMaxServeAge(cached.StoreTime) {
+		return nil, false
+	}
+	return cached, found
+}
+
+// exceedsMaxServeAge reports whether an entry stored at storeTime is older
+// than MaxServeAge allows serving
+func (m *Middleware) exceedsMaxServeAge(storeTime time.Time) bool {
+	if m.maxServeAge <= 0 || storeTime.IsZero() {
+		return false
+	}
+	return m.cache.clock.Now().Sub(storeTime) > m.maxServeAge
 }
 
 // handleCacheMiss processes a cache miss by recording the response and storing if appropriate
 func (m *Middleware) handleCacheMiss(w http.ResponseWriter, r *http.Request, key string, next http.Handler) {
 	atomic.AddUint64(&m.missCount, 1)
+	m.metrics.RecordMissMethod(r.Method)
+	if m.logger != nil {
+		m.logger.OnMiss(key, r.URL.Path)
+	}
 
-	recorder := NewResponseRecorder(w, r.Method)
+	w.Header().Set(m.statusHeader, "MISS")
+	recorder := NewResponseRecorderWithLimit(w, r.Method, m.maxBodyBytes)
+	recorder.hiddenHeaders = surrogateHeaders
+	m.serveRecorded(next, recorder, r)
+
+	m.storeResponseIfCacheable(key, r, recorder)
+}
+
+// serveRecorded runs the handler with a recorder. A handler that panics may
+// have written part of its body, so nothing is cached: the panic is counted
+// as handler_panicked and passed on to the server.
+func (m *Middleware) serveRecorded(next http.Handler, recorder *ResponseRecorder, r *http.Request) {
+	defer func() {
+		if v := recover(); v != nil {
+			m.metrics.RecordError("handler_panicked")
+			panic(v)
+		}
+	}()
 	next.ServeHTTP(recorder, r)
+}
+
+// revalidateStale fetches a fresh response for a stale entry. The response is
+// buffered so that a server error can be replaced by the stale entry, as
+// permitted by stale-if-error. Only MaxBodyBytes of it is recorded for the
+// cache; the client gets the whole body.
+func (m *Middleware) revalidateStale(w http.ResponseWriter, r *http.Request, key string, next http.Handler, stale *CachedResponse) {
+	atomic.AddUint64(&m.missCount, 1)
+	m.metrics.RecordMissMethod(r.Method)
+	if m.logger != nil {
+		m.logger.OnMiss(key, r.URL.Path)
+	}
+
+	buffered := &bufferedResponseWriter{header: make(http.Header)}
+	recorder := NewResponseRecorderWithLimit(buffered, r.Method, m.maxBodyBytes)
+	m.serveRecorded(next, recorder, r)
+
+	if recorder.StatusCode() >= 500 {
+		m.writeCachedResponseWithStatus(w, r, stale, "STALE-ERROR")
+		return
+	}
 
-	m.storeResponseIfCacheable(key, recorder)
+	for k, v := range recorder.Headers() {
+		w.Header()[k] = v
+	}
+	removeSurrogateHeaders(w.Header())
+	w.Header().Set(m.statusHeader, "MISS")
+	trailers := recorder.Trailers()
+	if len(trailers) > 0 {
+		declareTrailers(w.Header(), trailers)
+	}
+	w.WriteHeader(recorder.StatusCode())
+	if r.Method != http.MethodHead {
+		w.Write(buffered.body.Bytes())
+	}
+	writeTrailers(w.Header(), trailers)
+
+	m.storeResponseIfCacheable(key, r, recorder)
+}
+
+// bufferedResponseWriter holds a whole response body for sending later
+type bufferedResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header         { return w.header }
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *bufferedResponseWriter) WriteHeader(statusCode int)  {}
+
+// claimRevalidation marks key as being revalidated, reporting false if
+// another request already is
+func (m *Middleware) claimRevalidation(key string) bool {
+	m.revalidatingMu.Lock()
+	defer m.revalidatingMu.Unlock()
+
+	if _, pending := m.revalidating[key]; pending {
+		return false
+	}
+	m.revalidating[key] = struct{}{}
+	return true
+}
+
+// releaseRevalidation clears a claim made by claimRevalidation
+func (m *Middleware) releaseRevalidation(key string) {
+	m.revalidatingMu.Lock()
+	delete(m.revalidating, key)
+	m.revalidatingMu.Unlock()
+}
+
+// serveStale answers a request with a stale entry while another request
+// revalidates it
+func (m *Middleware) serveStale(w http.ResponseWriter, r *http.Request, key string, stale *CachedResponse) {
+	atomic.AddUint64(&m.hitCount, 1)
+	m.metrics.RecordHitMethod(r.Method)
+	if m.logger != nil {
+		m.logger.OnHit(key, r.URL.Path)
+	}
+	m.writeCachedResponseWithStatus(w, r, stale, "STALE")
 }
 
 // storeResponseIfCacheable stores the response in cache if it meets caching criteria
-func (m *Middleware) storeResponseIfCacheable(key string, recorder *ResponseRecorder) {
-	if !m.shouldCache(recorder) {
+func (m *Middleware) storeResponseIfCacheable(key string, r *http.Request, recorder *ResponseRecorder) {
+	// A body cut short, by the handler giving up or by the client leaving
+	// mid-response, must not be served to everyone else
+	if recorder.Truncated() || r.Context().Err() != nil {
+		m.metrics.RecordError("response_incomplete")
+		return
+	}
+
+	// gRPC-Web errors arrive as 200s; only whole, successful calls are kept
+	if !grpcWebCacheable(recorder.Headers(), recorder.Trailers(), recorder.Body()) {
+		m.metrics.RecordError("grpc_web_uncached")
+		return
+	}
+
+	// A range of the representation can't stand in for the whole of it
+	partial := recorder.StatusCode() == http.StatusPartialContent
+	if partial && !coversWholeRepresentation(recorder.Headers(), recorder.Body()) {
+		m.metrics.RecordError("partial_content_uncached")
+		return
+	}
+
+	if !m.shouldCache(recorder) || m.isPrivateResponse(r, recorder.Headers()) {
+		return
+	}
+
+	// Vary: * means no later request can be shown to match. Otherwise key
+	// the response on the request headers it varies on.
+	varyNames, varyAny := parseVary(recorder.Headers())
+	if varyAny {
+		return
+	}
+	resource := m.variantResource(r)
+	if m.recordVary(resource, varyNames) {
+		key = m.createCacheKey(r)
+	}
+
+	if !m.storeLimiter.Allow() {
+		m.metrics.RecordError("cache_store_throttled")
 		return
 	}
 
 	cachedResp := &CachedResponse{
 		StatusCode: recorder.StatusCode(),
-		Headers:    recorder.Headers(),
+		Headers:    filterHeaders(recorder.Headers(), m.stripHeaders, m.allowHeaders),
 		Body:       recorder.Body(),
+		Trailers:   recorder.Trailers(),
+		StoreTime:  m.cache.clock.Now(),
+	}
+	removeSurrogateHeaders(cachedResp.Headers)
+	cachedResp.Headers.Del(m.statusHeader)
+	if partial {
+		cachedResp.StatusCode = http.StatusOK
+		cachedResp.Headers.Del("Content-Range")
+	}
+	if m.beforeStore != nil {
+		cachedResp.Body = append([]byte(nil), cachedResp.Body...)
+		if !m.beforeStore(key, cachedResp) {
+			return
+		}
+	}
+	m.transformStoredBody(cachedResp)
+	if m.generateETag && cachedResp.StatusCode == http.StatusOK && cachedResp.Headers.Get("ETag") == "" {
+		cachedResp.Headers.Set("ETag", generateETag(cachedResp.Body))
+	}
+	m.compressResponse(cachedResp)
+
+	// With stale-if-error, keep the entry past its freshness lifetime so it
+	// can stand in for a failed revalidation. Immutable entries are never
+	// revalidated, so they don't need the fallback.
+	ttl := m.ttlForResponse(r, recorder)
+	cachedResp.Immutable = m.isImmutable(recorder.Headers())
+	if window, ok := parseCacheControl(cachedResp.Headers).staleIfError(); ok && window > 0 && !cachedResp.Immutable {
+		cachedResp.FreshUntil = m.cache.clock.Now().Add(ttl)
+		ttl += window
+	}
+	if err := m.cache.setResponse(key, cachedResp, ttl); err != nil {
+		if m.logger != nil {
+			m.logger.OnError("set", err)
+		}
+		return
+	}
+	m.indexStored(resource, key, surrogateKeys(recorder.Headers()))
+	if m.logger != nil {
+		m.logger.OnStore(key, len(cachedResp.Body))
+	}
+}
+
+// ttlForResponse selects the TTL for a response, using the negative TTL for
+// cacheable error statuses, then the X-Cache-TTL header, then the cache
+// bucket header, then the Surrogate-Control max-age, then ImmutableTTL for
+// Cache-Control: immutable, then the Cache-Control s-maxage or max-age less
+// any upstream Age, then any path override, then any content type override,
+// falling back to the default TTL
+func (m *Middleware) ttlForResponse(r *http.Request, recorder *ResponseRecorder) time.Duration {
+	if m.isNegativeStatus(recorder.StatusCode()) {
+		return m.negativeTTL
+	}
+
+	headers := recorder.Headers()
+	if ttl, ok := headerTTL(headers); ok && ttl > 0 {
+		return ttl
+	}
+	if ttl, exists := m.cacheBuckets[headers.Get(CacheBucketHeader)]; exists && ttl > 0 {
+		return ttl
+	}
+	if ttl, ok := surrogateMaxAge(headers); ok && ttl > 0 {
+		return ttl
+	}
+	if m.isImmutable(headers) {
+		return m.immutableTTL
+	}
+	if ttl, ok := remainingSharedMaxAge(headers); ok && ttl > 0 {
+		return ttl
+	}
+	if ttl, matched := ttlForPath(m.pathTTLs, r.URL.Path); matched && ttl > 0 {
+		return ttl
+	}
+	if ttl, exists := ttlForContentType(m.contentTypeTTLs, headers.Get("Content-Type")); exists && ttl > 0 {
+		return ttl
+	}
+	return m.defaultTTL
+}
+
+// isImmutable reports whether a response is marked Cache-Control: immutable
+// and ImmutableTTL is set
+func (m *Middleware) isImmutable(headers http.Header) bool {
+	return m.immutableTTL > 0 && parseCacheControl(headers).immutable()
+}
+
+// isNegativeStatus checks if the status code is a negatively cacheable error status
+func (m *Middleware) isNegativeStatus(statusCode int) bool {
+	return !containsStatus(m.includeStatus, statusCode) && containsStatus(m.errorStatus, statusCode)
+}
+
+// containsStatus checks if the status code is present in the list
+func containsStatus(codes []int, statusCode int) bool {
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
 	}
-	m.cache.Set(key, cachedResp, cache.DefaultExpiration)
+	return false
 }
@@ -5,95 +5,35 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-
-	"github.com/patrickmn/go-cache"
+	"time"
 )
 
-// TestTypeAssertionPanic tests the critical bug where type assertion can panic
-func TestTypeAssertionPanic(t *testing.T) {
-	// Create middleware
-	middleware := NewDefault()
-
-	// Create a test request
-	req := httptest.NewRequest("GET", "/test", nil)
-
-	// Get the cache key that will be used
-	key := middleware.createCacheKey(req)
-
-	// Manually corrupt the cache with invalid data
-	middleware.GetCacheForTesting().Set(key, "invalid-data-type", cache.DefaultExpiration)
-
-	// Verify the corrupted data is in the cache
-	if _, found := middleware.GetCacheForTesting().Get(key); !found {
-		t.Fatal("Test setup failed: corrupted data not found in cache")
-	}
-
-	// Create a recorder for the test
-	recorder := httptest.NewRecorder()
-	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"message": "test"}`))
-	}))
-
-	// This should no longer panic with the fix
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("Unexpected panic occurred: %v", r)
-		}
-	}()
-	// Make the request that should hit the corrupted cache
-	handler.ServeHTTP(recorder, req)
-
-	// Verify the response is the original response (cache miss due to invalid data)
-	if recorder.Body.String() != `{"message": "test"}` {
-		t.Errorf("Expected original response, got '%s'", recorder.Body.String())
-	}
-
-	// Note: After the request, the cache will have a new valid entry,
-	// but the important thing is that no panic occurred and the request succeeded
-}
-
-// TestInvalidCacheDataRemoval specifically tests that invalid cache data is removed
-func TestInvalidCacheDataRemoval(t *testing.T) {
+// TestRawCacheEntryServed verifies that an entry stored directly in the cache,
+// as the transport layer does, is served even though it carries no status code
+func TestRawCacheEntryServed(t *testing.T) {
 	middleware := NewDefault()
 	req := httptest.NewRequest("GET", "/test", nil)
 
 	// Calculate the cache key using the same method as the middleware
 	key := middleware.createCacheKey(req)
 
-	// Insert invalid data
-	middleware.GetCacheForTesting().Set(key, "corrupted-data", cache.DefaultExpiration)
-
-	// Verify invalid data is present
-	if _, found := middleware.GetCacheForTesting().Get(key); !found {
-		t.Fatal("Setup failed: corrupted data not in cache")
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	if err := middleware.GetCacheForTesting().Set(key, []byte(`{"message": "cached"}`), headers, time.Minute); err != nil {
+		t.Fatalf("Test setup failed: %v", err)
 	}
 
-	// Make request that should encounter and remove invalid data
 	recorder := httptest.NewRecorder()
 	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"data": "valid"}`))
+		w.Write([]byte(`{"message": "test"}`))
 	}))
-
 	handler.ServeHTTP(recorder, req)
 
-	// Verify no panic occurred and response is correct
-	if recorder.Body.String() != `{"data": "valid"}` {
-		t.Errorf("Expected valid response, got: %s", recorder.Body.String())
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected raw entry to be served as 200, got %d", recorder.Code)
 	}
-
-	// Verify that after the request, the cache now contains valid data
-	if cached, found := middleware.GetCacheForTesting().Get(key); found {
-		if cachedResp, ok := cached.(*CachedResponse); ok {
-			if string(cachedResp.Body) != `{"data": "valid"}` {
-				t.Errorf("Cache contains unexpected data: %s", string(cachedResp.Body))
-			}
-		} else {
-			t.Error("Cache should contain valid CachedResponse after fixing corruption")
-		}
-	} else {
-		t.Error("Cache should contain new valid entry after removing corrupted data")
+	if recorder.Header().Get("X-Cache-Status") != "HIT" || recorder.Body.String() != `{"message": "cached"}` {
+		t.Errorf("Expected cached response, got %q %q", recorder.Header().Get("X-Cache-Status"), recorder.Body.String())
 	}
 }
 
@@ -245,7 +185,7 @@ func TestHEADRequestSupport(t *testing.T) {
 
 	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Content-Length", "15")
+		w.Header().Set("Content-Length", "16")
 		if r.Method == "GET" {
 			w.Write([]byte(`{"data": "test"}`))
 		}
@@ -0,0 +1,101 @@
+// Package selectcachetest provides utilities for testing transport-layer
+// caching end to end without binding a real port.
+//
+// License: MIT
+package selectcachetest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	selectcache "github.com/go-i2p/go-select-cache"
+)
+
+// DialFunc dials a connection to an in-memory listener. Its signature matches
+// http.Transport.DialContext; network and address are ignored.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// InMemoryListener is a net.Listener whose connections are in-memory pipes
+// created by its Dial method
+type InMemoryListener struct {
+	conns chan net.Conn
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewInMemoryListener creates an in-memory listener
+func NewInMemoryListener() *InMemoryListener {
+	return &InMemoryListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept waits for and returns the server end of the next dialed connection
+func (l *InMemoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops the listener. Connections already accepted stay open.
+func (l *InMemoryListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the listener's placeholder address
+func (l *InMemoryListener) Addr() net.Addr {
+	return inMemoryAddr{}
+}
+
+// Dial connects to the listener, returning the client end of a pipe once the
+// server end has been accepted
+func (l *InMemoryListener) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		client.Close()
+		server.Close()
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		client.Close()
+		server.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// inMemoryAddr is the address of every InMemoryListener
+type inMemoryAddr struct{}
+
+func (inMemoryAddr) Network() string { return "memory" }
+func (inMemoryAddr) String() string  { return "memory" }
+
+// NewInMemoryCachingListener returns a CachingListener over an in-memory
+// listener, with the function that dials it. Serve the listener with an
+// http.Server and make requests with NewClient(dial). A nil config uses
+// selectcache.DefaultCacheConfig.
+func NewInMemoryCachingListener(config *selectcache.CacheConfig) (*selectcache.CachingListener, DialFunc) {
+	listener := NewInMemoryListener()
+	return selectcache.NewCachingListener(listener, config), listener.Dial
+}
+
+// NewClient returns an HTTP client whose connections are made with dial.
+// Keep-alives are disabled because the transport layer only caches the first
+// request on each connection.
+func NewClient(dial DialFunc) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:       dial,
+			DisableKeepAlives: true,
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package selectcachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestInMemoryCachingListener verifies that responses are cached end to end
+// over the in-memory listener
+func TestInMemoryCachingListener(t *testing.T) {
+	listener, dial := NewInMemoryCachingListener(nil)
+	defer listener.Close()
+
+	var requests int32
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"request": %d}`, n)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := NewClient(dial)
+	get := func() (*http.Response, string) {
+		resp, err := client.Get("http://memory/api/data")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+		return resp, string(body)
+	}
+
+	_, first := get()
+	if first != `{"request": 1}` {
+		t.Fatalf("Expected first response from the origin, got %s", first)
+	}
+
+	resp, second := get()
+	if resp.Header.Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected X-Cache-Status: HIT, got %q", resp.Header.Get("X-Cache-Status"))
+	}
+	if second != first {
+		t.Errorf("Expected cached body %s, got %s", first, second)
+	}
+	if listener.GetCache().Size() != 1 {
+		t.Errorf("Expected 1 cached entry, got %d", listener.GetCache().Size())
+	}
+}
+
+// TestInMemoryListener_Close verifies that closing the listener releases
+// Accept and fails later dials
+func TestInMemoryListener_Close(t *testing.T) {
+	listener := NewInMemoryListener()
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		accepted <- err
+	}()
+	listener.Close()
+
+	select {
+	case err := <-accepted:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Expected net.ErrClosed from Accept, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept did not return after Close")
+	}
+
+	if _, err := listener.Dial(context.Background(), "tcp", "memory"); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed from Dial, got %v", err)
+	}
+}
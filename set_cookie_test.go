@@ -0,0 +1,71 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_NoCacheOnSetCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		wantCached bool
+	}{
+		{"enabled by default", true, false},
+		{"disabled", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NoCacheOnSetCookie = tt.enabled
+			middleware := New(config)
+			defer middleware.Close()
+
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/login" {
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: "alice"})
+				}
+				w.Write([]byte(`{"ok":true}`))
+			}))
+
+			for _, path := range []string{"/login", "/login", "/public", "/public"} {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+			if cached := rec.Header().Get("X-Cache-Status") == "HIT"; cached != tt.wantCached {
+				t.Errorf("Set-Cookie response cached = %v, want %v", cached, tt.wantCached)
+			}
+			if tt.wantCached && rec.Header().Get("Set-Cookie") != "" {
+				t.Error("A cached response must never replay the cookie")
+			}
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/public", nil))
+			if rec.Header().Get("X-Cache-Status") != "HIT" {
+				t.Error("Responses without Set-Cookie should still be cached")
+			}
+		})
+	}
+}
+
+func TestContentDetector_NoCacheOnSetCookie(t *testing.T) {
+	config := DefaultCacheConfig()
+	detector := NewContentDetector(config)
+
+	headers := http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"session=alice"}}
+	body := []byte(`{"ok":true}`)
+
+	if detector.ShouldCache(body, headers, http.StatusOK) {
+		t.Error("Responses with Set-Cookie should not be cached by default")
+	}
+
+	config.NoCacheOnSetCookie = false
+	if !detector.ShouldCache(body, headers, http.StatusOK) {
+		t.Error("Responses with Set-Cookie should be cached when the rule is disabled")
+	}
+}
@@ -0,0 +1,80 @@
+package selectcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache_Touch(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.Clock = clock
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	if cache.Touch("missing", time.Minute) {
+		t.Error("Expected Touch to report a missing key")
+	}
+
+	cache.Set("key", []byte("data"), nil, 50*time.Millisecond)
+	memory := cache.MemoryUsage()
+
+	if !cache.Touch("key", time.Hour) {
+		t.Fatal("Expected Touch to find the entry")
+	}
+	clock.Advance(100 * time.Millisecond)
+
+	entry, found := cache.Get("key")
+	if !found {
+		t.Fatal("Expected touched entry to outlive its original TTL")
+	}
+	if remaining := entry.RemainingTTL(); remaining < 50*time.Minute {
+		t.Errorf("Expected expiry about an hour out, got %v", remaining)
+	}
+	if cache.MemoryUsage() != memory {
+		t.Errorf("Expected memory usage unchanged at %d, got %d", memory, cache.MemoryUsage())
+	}
+}
+
+func TestTTLCache_SlidingExpiration(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.SlidingExpiration = true
+	config.Clock = clock
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.Set("session", []byte("data"), nil, 200*time.Millisecond)
+
+	// Accessed every 100ms, the entry must outlive its 200ms TTL many times over
+	for i := 0; i < 10; i++ {
+		clock.Advance(100 * time.Millisecond)
+		if _, found := cache.Get("session"); !found {
+			t.Fatalf("Entry expired despite access after %v", time.Duration(i+1)*100*time.Millisecond)
+		}
+	}
+
+	// Left alone, it expires after a full TTL
+	clock.Advance(300 * time.Millisecond)
+	if _, found := cache.Get("session"); found {
+		t.Error("Expected entry to expire once it stopped being accessed")
+	}
+}
+
+func TestTTLCache_FixedExpirationByDefault(t *testing.T) {
+	clock := newFakeClock()
+	config := DefaultCacheConfig()
+	config.Clock = clock
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	cache.Set("key", []byte("data"), nil, 200*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		clock.Advance(100 * time.Millisecond)
+		cache.Get("key")
+	}
+
+	if _, found := cache.Get("key"); found {
+		t.Error("Expected access not to extend expiry without SlidingExpiration")
+	}
+}
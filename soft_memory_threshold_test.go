@@ -0,0 +1,65 @@
+package selectcache
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_TrimToSoftThreshold(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxMemoryMB = 1
+	config.SoftMemoryThresholdPct = 50
+	config.ShardCount = 1
+	metrics := NewCacheMetrics(true)
+
+	var evicted []string
+	config.OnEvict = func(key string, _ *CacheEntry) { evicted = append(evicted, key) }
+
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	body := bytes.Repeat([]byte("x"), 100*1024)
+	for i := 0; i < 8; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), body, http.Header{}, time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("Expected no synchronous evictions below the hard limit, got %v", evicted)
+	}
+
+	cache.trimToSoftThreshold()
+
+	softLimit := uint64(config.MaxMemoryMB * 1024 * 1024 / 2)
+	if usage := cache.MemoryUsage(); usage > softLimit {
+		t.Errorf("Expected memory usage at or below %d bytes after trim, got %d", softLimit, usage)
+	}
+	if len(evicted) != 3 || evicted[0] != "key-0" || evicted[2] != "key-2" {
+		t.Errorf("Expected the three least recently used entries to be trimmed, got %v", evicted)
+	}
+	if got := metrics.GetStats().Evictions; got != 3 {
+		t.Errorf("Expected 3 evictions recorded, got %d", got)
+	}
+	if _, found := cache.Get("key-7"); !found {
+		t.Error("Expected most recent entry to survive the trim")
+	}
+}
+
+func TestTTLCache_TrimDisabledByDefault(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxMemoryMB = 1
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	body := bytes.Repeat([]byte("x"), 100*1024)
+	for i := 0; i < 8; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), body, http.Header{}, time.Minute)
+	}
+
+	cache.trimToSoftThreshold()
+	if size := cache.Size(); size != 8 {
+		t.Errorf("Expected no trimming without SoftMemoryThresholdPct, got %d entries", size)
+	}
+}
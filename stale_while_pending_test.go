@@ -0,0 +1,147 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// staleWhilePendingHandler caches a stale-if-error response, then ages it so
+// the next request revalidates. Once primed, origin requests block until
+// release is closed.
+func staleWhilePendingHandler(t *testing.T, serveStale bool) (http.Handler, *int32, chan struct{}, chan struct{}) {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.ServeStaleWhilePending = serveStale
+	middleware := New(config)
+	t.Cleanup(middleware.Close)
+
+	var originCalls int32
+	var primed atomic.Bool
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&originCalls, 1)
+		body := `{"version": 1}`
+		if primed.Load() {
+			entered <- struct{}{}
+			<-release
+			body = `{"version": 2}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60, stale-if-error=300")
+		w.Write([]byte(body))
+	}))
+
+	req := httptest.NewRequest("GET", "/pending", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	cached, found := middleware.GetCacheForTesting().Get(middleware.createCacheKey(req))
+	if !found {
+		t.Fatal("Expected response to be cached")
+	}
+	cached.FreshUntil = time.Now().Add(-time.Second)
+	primed.Store(true)
+
+	return handler, &originCalls, entered, release
+}
+
+// TestServeStaleWhilePending_WaitersGetStale verifies that requests arriving
+// while a stale entry is being revalidated are answered with the stale entry
+// immediately instead of waiting on or duplicating the origin request
+func TestServeStaleWhilePending_WaitersGetStale(t *testing.T) {
+	handler, originCalls, entered, release := staleWhilePendingHandler(t, true)
+
+	leader := httptest.NewRecorder()
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		handler.ServeHTTP(leader, httptest.NewRequest("GET", "/pending", nil))
+	}()
+	<-entered
+
+	// The leader is blocked in the origin; waiters must not block behind it
+	const waiters = 5
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, waiters)
+	for i := range responses {
+		responses[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(resp *httptest.ResponseRecorder) {
+			defer wg.Done()
+			handler.ServeHTTP(resp, httptest.NewRequest("GET", "/pending", nil))
+		}(responses[i])
+	}
+
+	waitersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(waitersDone)
+	}()
+	select {
+	case <-waitersDone:
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("Waiters blocked on the in-flight revalidation")
+	}
+
+	for i, resp := range responses {
+		if resp.Header().Get("X-Cache-Status") != "STALE" {
+			t.Errorf("Waiter %d: expected X-Cache-Status: STALE, got %q", i, resp.Header().Get("X-Cache-Status"))
+		}
+		if resp.Body.String() != `{"version": 1}` {
+			t.Errorf("Waiter %d: expected stale body, got %s", i, resp.Body.String())
+		}
+	}
+
+	close(release)
+	<-leaderDone
+	if leader.Body.String() != `{"version": 2}` {
+		t.Errorf("Expected leader to get the fresh response, got %s", leader.Body.String())
+	}
+	if calls := atomic.LoadInt32(originCalls); calls != 2 {
+		t.Errorf("Expected the priming and revalidation origin calls only, got %d", calls)
+	}
+
+	// The revalidated entry is now served fresh
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/pending", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" || resp.Body.String() != `{"version": 2}` {
+		t.Errorf("Expected revalidated HIT, got %q with %s", resp.Header().Get("X-Cache-Status"), resp.Body.String())
+	}
+}
+
+// TestServeStaleWhilePending_Disabled verifies that without the option every
+// request for a stale entry revalidates against the origin
+func TestServeStaleWhilePending_Disabled(t *testing.T) {
+	handler, originCalls, entered, release := staleWhilePendingHandler(t, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/pending", nil))
+		}()
+	}
+
+	// Both requests reach the origin
+	for i := 0; i < 2; i++ {
+		select {
+		case <-entered:
+		case <-time.After(2 * time.Second):
+			close(release)
+			t.Fatal("Expected every request to revalidate")
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(originCalls); calls != 3 {
+		t.Errorf("Expected 3 origin calls, got %d", calls)
+	}
+}
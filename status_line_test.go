@@ -0,0 +1,118 @@
+package selectcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCachingListener_ReplaysOriginalStatusLine verifies that a cached 301 is
+// replayed with its original status code and reason phrase, not 200 OK
+func TestCachingListener_ReplaysOriginalStatusLine(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	// A raw backend, so the reason phrase isn't normalized by net/http
+	const response = "HTTP/1.1 301 Gone Fishing\r\n" +
+		"Location: /lake\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Length: 5\r\n" +
+		"\r\n" +
+		"moved"
+	go func() {
+		for {
+			conn, err := cachingListener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				io.WriteString(conn, response)
+			}()
+		}
+	}()
+
+	get := func() string {
+		conn, err := net.Dial("tcp", baseListener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "GET /pond HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		raw, _ := io.ReadAll(conn)
+		return string(raw)
+	}
+
+	if first := get(); !strings.HasPrefix(first, "HTTP/1.1 301 Gone Fishing\r\n") {
+		t.Fatalf("Unexpected origin response %q", first)
+	}
+	for deadline := time.Now().Add(time.Second); cachingListener.cache.Size() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	second := get()
+	if !strings.Contains(second, "X-Cache-Status: HIT\r\n") {
+		t.Fatalf("Expected second response from cache, got %q", second)
+	}
+	if !strings.HasPrefix(second, "HTTP/1.1 301 Gone Fishing\r\n") {
+		t.Errorf("Expected replayed status line to match the original, got %q", second[:strings.Index(second, "\r\n")])
+	}
+	if !strings.Contains(second, "Location: /lake\r\n") || !strings.HasSuffix(second, "moved") {
+		t.Errorf("Expected headers and body replayed, got %q", second)
+	}
+}
+
+// TestBuildHTTPResponse_ReasonPhrase verifies that replayed status lines use
+// the standard reason phrase unless a custom one was stored
+func TestBuildHTTPResponse_ReasonPhrase(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      *CacheEntry
+		statusLine string
+	}{
+		{"404 Not Found", &CacheEntry{StatusCode: http.StatusNotFound}, "HTTP/1.1 404 Not Found"},
+		{"no status", &CacheEntry{}, "HTTP/1.1 200 OK"},
+		{"custom phrase", &CacheEntry{StatusCode: 301, StatusText: "Gone Fishing"}, "HTTP/1.1 301 Gone Fishing"},
+	}
+
+	conn := newMockConn()
+	config := DefaultCacheConfig()
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+	cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+	defer cc.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.entry.Data = []byte("body")
+			response := string(cc.buildHTTPResponse(tt.entry))
+			if line, _, _ := strings.Cut(response, "\r\n"); line != tt.statusLine {
+				t.Errorf("Expected status line %q, got %q", tt.statusLine, line)
+			}
+		})
+	}
+}
+
+// TestCustomReasonPhrase verifies that only non-standard reason phrases are
+// stored
+func TestCustomReasonPhrase(t *testing.T) {
+	if got := customReasonPhrase(http.StatusNotFound, "Not Found"); got != "" {
+		t.Errorf("Expected standard phrase to be dropped, got %q", got)
+	}
+	if got := customReasonPhrase(http.StatusNotFound, "Nothing Here"); got != "Nothing Here" {
+		t.Errorf("Expected custom phrase to be kept, got %q", got)
+	}
+}
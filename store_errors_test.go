@@ -0,0 +1,59 @@
+package selectcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestStoreErrorMetric verifies that each store error maps to its own
+// counter, including when wrapped
+func TestStoreErrorMetric(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrEntryTooLarge, "entry_too_large"},
+		{ErrCircuitOpen, "cache_store_circuit_open"},
+		{ErrEvictionBatchExceeded, "eviction_batch_exceeded"},
+		{ErrStoreUnavailable, "cache_store_unavailable"},
+		{fmt.Errorf("encode entry: %w", ErrSerialization), "cache_store_serialization"},
+		{errors.New("disk full"), "cache_store_failed"},
+	}
+
+	for _, tt := range tests {
+		if got := storeErrorMetric(tt.err); got != tt.want {
+			t.Errorf("storeErrorMetric(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestCachingConnection_StoreErrorCategory verifies that a failed transport
+// store is counted once, under its category
+func TestCachingConnection_StoreErrorCategory(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntrySizeBytes = 64
+	metrics := NewCacheMetrics(true)
+	cache := NewTTLCache(config, metrics)
+	defer cache.Close()
+
+	conn := newMockConn()
+	cc := NewCachingConnection(conn, cache, config, metrics, NewContentDetector(config))
+	defer cc.Close()
+
+	conn.writeToReadBuffer([]byte("GET /large.json HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	cc.Read(make([]byte, 1024))
+
+	// The body fits the size cap, but not with its headers
+	body := bytes.Repeat([]byte("x"), 50)
+	cc.Write([]byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)))
+
+	errs := metrics.GetStats().Errors
+	if errs["entry_too_large"] != 1 {
+		t.Errorf("Expected entry_too_large to be recorded once, got %d", errs["entry_too_large"])
+	}
+	if errs["cache_store_failed"] != 0 {
+		t.Errorf("Expected no generic cache_store_failed, got %d", errs["cache_store_failed"])
+	}
+}
@@ -0,0 +1,90 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMiddleware_MaxStoresPerSecond verifies that stores beyond the rate
+// limit are skipped while the responses are still served
+func TestMiddleware_MaxStoresPerSecond(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxStoresPerSecond = 2
+	middleware := New(config)
+	defer middleware.Close()
+
+	now := time.Now()
+	middleware.storeLimiter.now = func() time.Time { return now }
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+	}))
+
+	// A scan of unique URLs only stores up to the limit
+	for i := 0; i < 5; i++ {
+		path := fmt.Sprintf("/crawl/%d", i)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+		if resp.Code != http.StatusOK || resp.Body.String() != fmt.Sprintf(`{"path": %q}`, path) {
+			t.Errorf("Expected %s to be served normally, got %d %s", path, resp.Code, resp.Body.String())
+		}
+	}
+
+	if items, _, _ := middleware.Stats(); items != 2 {
+		t.Errorf("Expected 2 cached responses, got %d", items)
+	}
+	if throttled := middleware.GetMetrics().GetStats().Errors["cache_store_throttled"]; throttled != 3 {
+		t.Errorf("Expected 3 throttled stores, got %d", throttled)
+	}
+
+	// Throttled responses are stored once the limit allows again
+	now = now.Add(500 * time.Millisecond)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/crawl/4", nil))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/crawl/4", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected store to succeed after the bucket refilled, got %q", resp.Header().Get("X-Cache-Status"))
+	}
+}
+
+// TestStoreLimiter_Unlimited verifies that a zero rate admits every store
+func TestStoreLimiter_Unlimited(t *testing.T) {
+	limiter := newStoreLimiter(0)
+	for i := 0; i < 1000; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("Expected unlimited limiter to allow store %d", i)
+		}
+	}
+}
+
+// TestStoreLimiter_RefillCapped verifies that an idle limiter refills to at
+// most a second's worth of stores
+func TestStoreLimiter_RefillCapped(t *testing.T) {
+	now := time.Now()
+	limiter := newStoreLimiter(3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("Expected initial burst store %d to be allowed", i)
+		}
+	}
+	if limiter.Allow() {
+		t.Fatal("Expected store beyond the burst to be throttled")
+	}
+
+	now = now.Add(time.Minute)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if limiter.Allow() {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Expected refill capped at 3 stores, got %d", allowed)
+	}
+}
@@ -0,0 +1,51 @@
+package selectcache
+
+import (
+	"sync"
+	"time"
+)
+
+// storeLimiter is a token bucket admitting up to perSecond stores a second,
+// with bursts of up to a second's worth. A zero rate admits every store.
+type storeLimiter struct {
+	perSecond int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newStoreLimiter creates a limiter with a full bucket
+func newStoreLimiter(perSecond int) *storeLimiter {
+	return &storeLimiter{
+		perSecond: perSecond,
+		now:       time.Now,
+		tokens:    float64(perSecond),
+	}
+}
+
+// Allow reports whether a store may proceed, consuming a token if so
+func (l *storeLimiter) Allow() bool {
+	if l.perSecond <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.perSecond)
+		if l.tokens > float64(l.perSecond) {
+			l.tokens = float64(l.perSecond)
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,83 @@
+package selectcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// stripHeaders are the origin response headers for the strip tests: one
+// per-user, one hop-by-hop and one ordinary header
+var stripHeaders = http.Header{
+	"Content-Type": {"application/json"},
+	"Set-Cookie":   {"session=alice"},
+	"Keep-Alive":   {"timeout=5"},
+	"X-Request-Id": {"abc"},
+}
+
+func TestMiddleware_StripsSetCookieFromCache(t *testing.T) {
+	// With the Set-Cookie rule off the response is cached, minus the cookie
+	config := DefaultConfig()
+	config.NoCacheOnSetCookie = false
+	handler := newHeaderTestHandler(t, config, stripHeaders, `{}`)
+
+	first := serveWithHeader(handler, "/api", "", "")
+	if first.Header().Get("Set-Cookie") == "" {
+		t.Fatal("Expected the origin response to reach its own client untouched")
+	}
+
+	hit := serveWithHeader(handler, "/api", "", "")
+	if hit.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatal("Expected second request to be served from cache")
+	}
+	for _, name := range []string{"Set-Cookie", "Keep-Alive"} {
+		if got := hit.Header().Get(name); got != "" {
+			t.Errorf("Expected %s not to be replayed from cache, got %q", name, got)
+		}
+	}
+	if hit.Header().Get("X-Request-Id") != "abc" {
+		t.Error("Expected other headers to be kept")
+	}
+}
+
+func TestMiddleware_AllowHeaders(t *testing.T) {
+	config := DefaultConfig()
+	config.AllowHeaders = []string{"content-type", "Set-Cookie"}
+	config.NoCacheOnSetCookie = false
+	handler := newHeaderTestHandler(t, config, stripHeaders, `{}`)
+
+	serveWithHeader(handler, "/api", "", "")
+	hit := serveWithHeader(handler, "/api", "", "")
+
+	if hit.Header().Get("Content-Type") != "application/json" {
+		t.Error("Expected allowlisted header to be cached")
+	}
+	if hit.Header().Get("X-Request-Id") != "" {
+		t.Error("Expected headers outside the allowlist to be dropped")
+	}
+	if hit.Header().Get("Set-Cookie") != "" {
+		t.Error("Expected strip list to apply on top of the allowlist")
+	}
+}
+
+func TestTTLCache_StripsHeaders(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "text/plain")
+	headers.Set("Set-Cookie", "session=alice")
+	headers.Set("Connection", "keep-alive")
+	cache.Set("key", []byte("v"), headers, time.Minute)
+
+	entry, _ := cache.Get("key")
+	if entry.Headers.Get("Set-Cookie") != "" || entry.Headers.Get("Connection") != "" {
+		t.Errorf("Expected per-user and hop-by-hop headers to be stripped, got %v", entry.Headers)
+	}
+	if entry.Headers.Get("Content-Type") != "text/plain" {
+		t.Error("Expected Content-Type to be kept")
+	}
+	if headers.Get("Set-Cookie") == "" {
+		t.Error("Expected the caller's headers not to be modified")
+	}
+}
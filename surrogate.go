@@ -0,0 +1,111 @@
+package selectcache
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Surrogate headers address intermediary caches such as this one rather than
+// clients (Fastly/Varnish style), so they are never sent on to clients
+const (
+	SurrogateControlHeader = "Surrogate-Control"
+	SurrogateKeyHeader     = "Surrogate-Key"
+)
+
+// surrogateHeaders are withheld from clients and never stored, along with
+// the X-Cache-TTL header handlers use to set a response's TTL
+var surrogateHeaders = []string{SurrogateControlHeader, SurrogateKeyHeader, CacheTTLHeader}
+
+// surrogateMaxAge returns the Surrogate-Control max-age, which takes
+// precedence over Cache-Control for this cache
+func surrogateMaxAge(headers http.Header) (time.Duration, bool) {
+	return parseDirectives(headers.Values(SurrogateControlHeader)).seconds("max-age")
+}
+
+// surrogateKeys returns the space-separated Surrogate-Key tags of a response
+func surrogateKeys(headers http.Header) []string {
+	var tags []string
+	for _, value := range headers.Values(SurrogateKeyHeader) {
+		tags = append(tags, strings.Fields(value)...)
+	}
+	return tags
+}
+
+// headerTTL returns the TTL a handler set with the X-Cache-TTL header, in
+// whole seconds. Malformed and negative values are ignored.
+func headerTTL(headers http.Header) (time.Duration, bool) {
+	value := strings.TrimSpace(headers.Get(CacheTTLHeader))
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 || seconds > int64(math.MaxInt64/time.Second) {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// removeSurrogateHeaders deletes the surrogate headers from headers
+func removeSurrogateHeaders(headers http.Header) {
+	for _, name := range surrogateHeaders {
+		headers.Del(name)
+	}
+}
+
+// InvalidateTag removes every cached response tagged with tag through its
+// Surrogate-Key header and reports how many entries were removed
+func (m *Middleware) InvalidateTag(tag string) int {
+	// Collect keys first: deleting fires OnEvict, which takes tagsMu
+	m.tagsMu.Lock()
+	keys := make([]string, 0, len(m.tagged[tag]))
+	for key := range m.tagged[tag] {
+		keys = append(keys, key)
+	}
+	m.tagsMu.Unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		if m.cache.Delete(key) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// indexTags records the tags of a cache key, replacing any previous ones
+func (m *Middleware) indexTags(key string, tags []string) {
+	m.unindexTags(key)
+	if len(tags) == 0 {
+		return
+	}
+
+	m.tagsMu.Lock()
+	defer m.tagsMu.Unlock()
+
+	for _, tag := range tags {
+		keys, exists := m.tagged[tag]
+		if !exists {
+			keys = make(map[string]struct{})
+			m.tagged[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	m.tagsOf[key] = tags
+}
+
+// unindexTags removes a cache key from the tag index
+func (m *Middleware) unindexTags(key string) {
+	m.tagsMu.Lock()
+	defer m.tagsMu.Unlock()
+
+	for _, tag := range m.tagsOf[key] {
+		delete(m.tagged[tag], key)
+		if len(m.tagged[tag]) == 0 {
+			delete(m.tagged, tag)
+		}
+	}
+	delete(m.tagsOf, key)
+}
@@ -0,0 +1,149 @@
+package selectcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// surrogateHandler serves JSON tagged with the Surrogate-Key for its path
+func surrogateHandler(middleware *Middleware, keys map[string]string) http.Handler {
+	return middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set(SurrogateControlHeader, "max-age=3600")
+		w.Header().Set(SurrogateKeyHeader, keys[r.URL.Path])
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+}
+
+// TestSurrogateControl_TTL verifies that Surrogate-Control max-age sets the
+// TTL ahead of Cache-Control, and that both surrogate headers are withheld
+// from clients on misses and hits
+func TestSurrogateControl_TTL(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+	handler := surrogateHandler(middleware, map[string]string{"/product/1": "product-1"})
+
+	for _, expectedStatus := range []string{"MISS", "HIT"} {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/product/1", nil))
+		if got := resp.Header().Get("X-Cache-Status"); got != expectedStatus {
+			t.Errorf("Expected X-Cache-Status %q, got %q", expectedStatus, got)
+		}
+		for _, name := range []string{SurrogateControlHeader, SurrogateKeyHeader} {
+			if resp.Header().Get(name) != "" {
+				t.Errorf("%q response: expected %s to be stripped, got %q", expectedStatus, name, resp.Header().Get(name))
+			}
+		}
+		if resp.Header().Get("Cache-Control") != "max-age=0" {
+			t.Errorf("Expected client Cache-Control to be kept, got %q", resp.Header().Get("Cache-Control"))
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/product/1", nil)
+	entry, found := middleware.Cache().Get(middleware.createCacheKey(req))
+	if !found {
+		t.Fatal("Expected response to be cached")
+	}
+	if ttl := time.Until(entry.ExpiresAt); ttl < 59*time.Minute || ttl > time.Hour {
+		t.Errorf("Expected Surrogate-Control TTL of about 1h, got %v", ttl)
+	}
+}
+
+// TestSurrogateControl_ZeroMaxAge verifies that Surrogate-Control max-age=0
+// keeps a response out of the cache even when Cache-Control allows it
+func TestSurrogateControl_ZeroMaxAge(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=600")
+		w.Header().Set(SurrogateControlHeader, "max-age=0")
+		w.Write([]byte(`{"live": true}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/live", nil))
+
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected nothing cached, got %d items", items)
+	}
+}
+
+// TestSurrogateKey_InvalidateTag verifies that Surrogate-Key values tag
+// entries so InvalidateTag removes exactly the tagged ones
+func TestSurrogateKey_InvalidateTag(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+	handler := surrogateHandler(middleware, map[string]string{
+		"/product/1": "product-1 catalog",
+		"/product/2": "product-2 catalog",
+		"/about":     "static",
+	})
+
+	for _, path := range []string{"/product/1", "/product/2", "/about"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if removed := middleware.InvalidateTag("product-1"); removed != 1 {
+		t.Errorf("Expected 1 entry removed for product-1, got %d", removed)
+	}
+	if removed := middleware.InvalidateTag("catalog"); removed != 1 {
+		t.Errorf("Expected the remaining catalog entry removed, got %d", removed)
+	}
+	if removed := middleware.InvalidateTag("unknown"); removed != 0 {
+		t.Errorf("Expected nothing removed for an unknown tag, got %d", removed)
+	}
+
+	if items, _, _ := middleware.Stats(); items != 1 {
+		t.Errorf("Expected only the untagged entry to remain, got %d items", items)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/about", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Error("Expected /about to still be cached")
+	}
+}
+
+// TestPurgeHandler_Tag verifies tag invalidation through the purge endpoint
+func TestPurgeHandler_Tag(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+	handler := surrogateHandler(middleware, map[string]string{"/a": "group", "/b": "group"})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+
+	resp := httptest.NewRecorder()
+	middleware.PurgeHandler().ServeHTTP(resp, httptest.NewRequest("POST", "/purge?tag=group", nil))
+
+	var result PurgeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode purge result: %v", err)
+	}
+	if result.Operation != "invalidate_tag" || result.Tag != "group" || result.EntriesAffected != 2 {
+		t.Errorf("Unexpected purge result: %+v", result)
+	}
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected tagged entries purged, got %d items", items)
+	}
+}
+
+// TestMiddleware_TagIndexEvictedBeforeIndexing verifies that a key evicted
+// before it was indexed doesn't stay in the tag index
+func TestMiddleware_TagIndexEvictedBeforeIndexing(t *testing.T) {
+	middleware := New(DefaultConfig())
+	defer middleware.Close()
+	middleware.cache.config.Logger = &evictOnStoreLogger{cache: middleware.cache}
+
+	handler := surrogateHandler(middleware, map[string]string{"/products/1": "product-1 products"})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/products/1", nil))
+
+	middleware.tagsMu.Lock()
+	defer middleware.tagsMu.Unlock()
+	if len(middleware.tagged) != 0 || len(middleware.tagsOf) != 0 {
+		t.Errorf("Expected the evicted key not to be tagged, got %v", middleware.tagged)
+	}
+}
@@ -0,0 +1,84 @@
+package selectcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getWithTrailers fetches url, reading the body fully so trailers arrive
+func getWithTrailers(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	return resp, string(body)
+}
+
+// TestMiddleware_ReplaysTrailers verifies that trailers declared in the
+// Trailer header, and those set with http.TrailerPrefix, are cached and sent
+// again with cache hits
+func TestMiddleware_ReplaysTrailers(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	server := httptest.NewServer(middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("message"))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "OK")
+	})))
+	defer server.Close()
+
+	for _, expectedStatus := range []string{"MISS", "HIT"} {
+		resp, body := getWithTrailers(t, server.URL+"/rpc")
+		if got := resp.Header.Get("X-Cache-Status"); got != expectedStatus {
+			t.Errorf("Expected X-Cache-Status %q, got %q", expectedStatus, got)
+		}
+		if body != "message" {
+			t.Errorf("Expected body %q, got %q", "message", body)
+		}
+		if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+			t.Errorf("%q response: expected Grpc-Status trailer 0, got %q", expectedStatus, got)
+		}
+		if got := resp.Trailer.Get("Grpc-Message"); got != "OK" {
+			t.Errorf("%q response: expected Grpc-Message trailer OK, got %q", expectedStatus, got)
+		}
+	}
+}
+
+// TestMiddleware_NoTrailers verifies that responses without trailers are
+// replayed unchanged, with a Content-Length and no Trailer header
+func TestMiddleware_NoTrailers(t *testing.T) {
+	middleware := NewDefault()
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"plain": true}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/plain", nil))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/plain", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("Expected cache hit, got %q", resp.Header().Get("X-Cache-Status"))
+	}
+	if resp.Header().Get("Trailer") != "" {
+		t.Errorf("Expected no Trailer header, got %q", resp.Header().Get("Trailer"))
+	}
+	if resp.Header().Get("Content-Length") != "15" {
+		t.Errorf("Expected Content-Length 15, got %q", resp.Header().Get("Content-Length"))
+	}
+	if resp.Result().Trailer != nil {
+		t.Errorf("Expected no trailers, got %v", resp.Result().Trailer)
+	}
+}
@@ -0,0 +1,84 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMiddleware_NormalizeTrailingSlash verifies that paths with and without
+// a trailing slash share one entry when normalization is on
+func TestMiddleware_NormalizeTrailingSlash(t *testing.T) {
+	config := DefaultConfig()
+	config.NormalizeTrailingSlash = true
+	middleware := New(config)
+	defer middleware.Close()
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"users": []}`))
+	}))
+
+	for _, path := range []string{"/api/users", "/api/users/"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if calls != 1 {
+		t.Errorf("Expected both paths to share one entry, got %d origin calls", calls)
+	}
+
+	middleware.Delete("/api/users/")
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected Delete with a trailing slash to remove the entry, got %d items", items)
+	}
+}
+
+// TestKeyPath verifies trailing slash handling, including the root path
+func TestKeyPath(t *testing.T) {
+	tests := []struct {
+		path      string
+		trimSlash bool
+		want      string
+	}{
+		{"/api/users/", true, "/api/users"},
+		{"/api/users", true, "/api/users"},
+		{"/", true, "/"},
+		{"/api/users/", false, "/api/users/"},
+	}
+
+	for _, tt := range tests {
+		if got := keyPath(tt.path, tt.trimSlash); got != tt.want {
+			t.Errorf("keyPath(%q, %v) = %q, want %q", tt.path, tt.trimSlash, got, tt.want)
+		}
+	}
+}
+
+// TestCachingConnection_NormalizeTrailingSlash verifies that the transport
+// layer gives both variants of a path the same key
+func TestCachingConnection_NormalizeTrailingSlash(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.NormalizeTrailingSlash = true
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+
+	keyFor := func(path string) string {
+		conn := newMockConn()
+		cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+		defer cc.Close()
+
+		conn.writeToReadBuffer([]byte("GET " + path + " HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+		cc.Read(make([]byte, 1024))
+
+		cc.stateMu.RLock()
+		defer cc.stateMu.RUnlock()
+		return cc.cacheKey
+	}
+
+	if keyFor("/api/users") != keyFor("/api/users/") {
+		t.Error("Expected both paths to get the same key")
+	}
+	if keyFor("/") == keyFor("/api/users") {
+		t.Error("Expected the root path to keep its own key")
+	}
+}
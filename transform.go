@@ -0,0 +1,38 @@
+package selectcache
+
+import "bytes"
+
+// transformStoredBody applies StoreTransform to a response about to be
+// cached. Bodies the origin encoded itself are left alone, since the
+// transform would see compressed bytes.
+func (m *Middleware) transformStoredBody(resp *CachedResponse) {
+	if m.storeTransform == nil || len(resp.Body) == 0 || resp.Headers.Get("Content-Encoding") != "" {
+		return
+	}
+
+	transformed := m.storeTransform(resp.Headers.Get("Content-Type"), append([]byte(nil), resp.Body...))
+	if bytes.Equal(transformed, resp.Body) {
+		return
+	}
+	resp.Body = transformed
+	weakenETag(resp.Headers)
+}
+
+// transformServedBody applies ServeTransform to a cached response about to
+// be sent without a Content-Encoding, returning a copy so the cache entry is
+// unchanged
+func (m *Middleware) transformServedBody(cached *CachedResponse) *CachedResponse {
+	if m.serveTransform == nil || len(cached.Body) == 0 || cached.Headers.Get("Content-Encoding") != "" {
+		return cached
+	}
+
+	transformed := m.serveTransform(cached.Headers.Get("Content-Type"), append([]byte(nil), cached.Body...))
+	if bytes.Equal(transformed, cached.Body) {
+		return cached
+	}
+	served := *cached
+	served.Body = transformed
+	served.Headers = cached.Headers.Clone()
+	weakenETag(served.Headers)
+	return &served
+}
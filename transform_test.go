@@ -0,0 +1,84 @@
+package selectcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// minifyJSON is a StoreTransform compacting JSON bodies
+func minifyJSON(contentType string, body []byte) []byte {
+	var compact bytes.Buffer
+	if !strings.Contains(contentType, "json") || json.Compact(&compact, body) != nil {
+		return body
+	}
+	return compact.Bytes()
+}
+
+// TestMiddleware_StoreTransform verifies that the stored body is transformed
+// while the first response goes out as the handler wrote it
+func TestMiddleware_StoreTransform(t *testing.T) {
+	config := DefaultConfig()
+	config.StoreTransform = minifyJSON
+	middleware := New(config)
+	defer middleware.Close()
+
+	original := "{\n  \"id\": 1,\n  \"name\": \"widget\"\n}"
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(original))
+	}))
+	get := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/widget", nil))
+		return resp
+	}
+
+	if first := get(); first.Body.String() != original {
+		t.Errorf("Expected the first response unchanged, got %q", first.Body.String())
+	}
+
+	key := middleware.createCacheKey(httptest.NewRequest("GET", "/widget", nil))
+	stored, found := middleware.cache.getResponse(key)
+	if !found {
+		t.Fatal("Expected the response to be cached")
+	}
+	if want := `{"id":1,"name":"widget"}`; string(stored.Body) != want {
+		t.Errorf("Expected stored body %q, got %q", want, stored.Body)
+	}
+
+	hit := get()
+	if hit.Body.String() != string(stored.Body) || hit.Header().Get("Content-Length") != "24" {
+		t.Errorf("Expected the minified body on a hit, got %q (Content-Length %s)", hit.Body.String(), hit.Header().Get("Content-Length"))
+	}
+	if etag := hit.Header().Get("ETag"); etag != `W/"v1"` {
+		t.Errorf("Expected the transformed body's ETag to be weakened, got %q", etag)
+	}
+}
+
+// TestMiddleware_ServeTransform verifies that cached bodies are transformed
+// on the way out without changing the stored entry
+func TestMiddleware_ServeTransform(t *testing.T) {
+	config := DefaultConfig()
+	config.ServeTransform = func(contentType string, body []byte) []byte {
+		return bytes.ToUpper(body)
+	}
+	middleware := New(config)
+	defer middleware.Close()
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name": "widget"}`))
+	}))
+	for i, want := range []string{`{"name": "widget"}`, `{"NAME": "WIDGET"}`, `{"NAME": "WIDGET"}`} {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/widget", nil))
+		if resp.Body.String() != want {
+			t.Errorf("Request %d: expected %q, got %q", i+1, want, resp.Body.String())
+		}
+	}
+}
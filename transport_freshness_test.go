@@ -0,0 +1,123 @@
+package selectcache
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCachingListener_ServesOriginalStatusAndRemainingTTL verifies that a
+// cached redirect is replayed with its own status and a max-age counting down
+// from the entry's remaining TTL
+func TestCachingListener_ServesOriginalStatusAndRemainingTTL(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		w.Header().Set("Location", "/new")
+		w.WriteHeader(http.StatusMovedPermanently)
+		io.WriteString(w, "moved")
+	})
+	server := &http.Server{Handler: handler}
+	go server.Serve(cachingListener)
+	defer server.Close()
+
+	client := &http.Client{
+		Transport:     &http.Transport{DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	get := func() *http.Response {
+		resp, err := client.Get("http://" + baseListener.Addr().String() + "/old")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	get()
+	for deadline := time.Now().Add(time.Second); cachingListener.cache.Size() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cachingListener.cache.Size() == 0 {
+		t.Fatal("Expected redirect to be cached")
+	}
+
+	resp := get()
+	if resp.Header.Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("Expected second response from cache, got X-Cache-Status %q", resp.Header.Get("X-Cache-Status"))
+	}
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected cached 301 status, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Location") != "/new" {
+		t.Errorf("Expected Location header preserved, got %q", resp.Header.Get("Location"))
+	}
+
+	cacheControl := resp.Header.Get("Cache-Control")
+	maxAge, err := strconv.Atoi(strings.TrimPrefix(cacheControl, "public, max-age="))
+	if err != nil || maxAge <= 590 || maxAge > 600 {
+		t.Errorf("Expected max-age of the remaining TTL, got %q", cacheControl)
+	}
+}
+
+func TestCachingConnection_BuildHTTPResponse(t *testing.T) {
+	conn := newMockConn()
+	config := DefaultCacheConfig()
+	cache := NewTTLCache(config, nil)
+	defer cache.Close()
+	cc := NewCachingConnection(conn, cache, config, nil, NewContentDetector(config))
+	defer cc.Close()
+
+	headers := http.Header{
+		"Content-Type":  []string{"application/json"},
+		"Cache-Control": []string{"max-age=3600, must-revalidate"},
+		"Age":           []string{"100"},
+	}
+	cache.setResponse("key", &CachedResponse{StatusCode: http.StatusGone, Headers: headers, Body: []byte("{}")}, time.Minute)
+	entry, _ := cache.Get("key")
+
+	if remaining := entry.RemainingTTL(); remaining <= 59*time.Second || remaining > time.Minute {
+		t.Errorf("Expected about a minute remaining, got %v", remaining)
+	}
+
+	raw := string(cc.buildHTTPResponse(entry))
+	if !strings.HasPrefix(raw, "HTTP/1.1 410 Gone\r\n") {
+		t.Errorf("Expected stored status in the status line, got %q", raw[:strings.Index(raw, "\r\n")])
+	}
+	if !strings.Contains(raw, "Cache-Control: must-revalidate, max-age=59\r\n") && !strings.Contains(raw, "Cache-Control: must-revalidate, max-age=60\r\n") {
+		t.Errorf("Expected Cache-Control rewritten to the remaining TTL, got %q", raw)
+	}
+	if strings.Contains(raw, "Age: 100") {
+		t.Error("Expected the stored Age header to be dropped")
+	}
+
+	// Serving the entry records its freshness in the connection stats
+	cc.stateMu.Lock()
+	cc.cacheKey = "key"
+	cc.stateMu.Unlock()
+	if served, _ := cc.tryServeCachedResponse([]byte("response")); !served {
+		t.Fatal("Expected the entry to be served from cache")
+	}
+	if stats := cc.GetStats(); stats.ServedTTL <= 59*time.Second || stats.ServedStale {
+		t.Errorf("Expected fresh served TTL of about a minute, got %v (stale %v)", stats.ServedTTL, stats.ServedStale)
+	}
+
+	// Entries stored without a status code are replayed as 200 OK
+	cache.Set("raw", []byte("data"), nil, time.Minute)
+	entry, _ = cache.Get("raw")
+	if raw := string(cc.buildHTTPResponse(entry)); !strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n") {
+		t.Errorf("Expected 200 OK for an entry without status, got %q", raw[:strings.Index(raw, "\r\n")])
+	}
+}
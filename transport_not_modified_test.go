@@ -0,0 +1,76 @@
+package selectcache
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestCachingListener_NotModified verifies that a conditional request whose
+// If-None-Match matches a cached entry's ETag is answered from the cache with
+// a bodiless 304
+func TestCachingListener_NotModified(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		io.WriteString(w, `{"version":1}`)
+	})
+	server := &http.Server{Handler: handler}
+	go server.Serve(cachingListener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(ifNoneMatch string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", "http://"+baseListener.Addr().String()+"/data", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	get("")
+	for deadline := time.Now().Add(time.Second); cachingListener.cache.Size() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cachingListener.cache.Size() == 0 {
+		t.Fatal("Expected response to be cached")
+	}
+
+	resp, body := get(`"v1"`)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("Expected 304 for a matching ETag, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected the 304 to come from the cache, got X-Cache-Status %q", resp.Header.Get("X-Cache-Status"))
+	}
+	if resp.Header.Get("ETag") != `"v1"` {
+		t.Errorf("Expected the ETag on the 304, got %q", resp.Header.Get("ETag"))
+	}
+	if body != "" {
+		t.Errorf("Expected no body on a 304, got %q", body)
+	}
+
+	resp, body = get(`"v0"`)
+	if resp.StatusCode != http.StatusOK || body != `{"version":1}` {
+		t.Errorf("Expected the full cached response for a stale ETag, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Cache-Status") != "HIT" {
+		t.Errorf("Expected a cache hit, got X-Cache-Status %q", resp.Header.Get("X-Cache-Status"))
+	}
+}
@@ -0,0 +1,49 @@
+package selectcache
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTTLCache_TTLJitterSpreadsExpiry(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.TTLJitter = 0.1
+	cache := NewTTLCache(config, NewCacheMetrics(true))
+	defer cache.Close()
+
+	ttl := 15 * time.Minute
+	minTTL := time.Duration(float64(ttl) * 0.9)
+	maxTTL := time.Duration(float64(ttl) * 1.1)
+
+	distinct := make(map[time.Duration]struct{})
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		cache.Set(key, []byte("v"), http.Header{}, ttl)
+		entry, _ := cache.peek(key)
+
+		lifetime := entry.ExpiresAt.Sub(entry.StoreTime)
+		if lifetime < minTTL-time.Second || lifetime > maxTTL+time.Second {
+			t.Fatalf("Expiry %v outside jitter window [%v, %v]", lifetime, minTTL, maxTTL)
+		}
+		distinct[lifetime.Truncate(time.Second)] = struct{}{}
+	}
+
+	if len(distinct) < 10 {
+		t.Errorf("Expected expiries to be spread out, got only %d distinct values", len(distinct))
+	}
+}
+
+func TestTTLCache_NoJitterByDefault(t *testing.T) {
+	cache := NewTTLCache(DefaultCacheConfig(), NewCacheMetrics(true))
+	defer cache.Close()
+
+	ttl := 15 * time.Minute
+	cache.Set("key", []byte("v"), http.Header{}, ttl)
+	entry, _ := cache.peek("key")
+
+	if lifetime := entry.ExpiresAt.Sub(entry.StoreTime); lifetime < ttl-time.Second || lifetime > ttl+time.Second {
+		t.Errorf("Expected expiry of %v without jitter, got %v", ttl, lifetime)
+	}
+}
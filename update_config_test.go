@@ -0,0 +1,73 @@
+package selectcache
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestCachingListener_UpdateConfigEvicts verifies that lowering MaxEntries
+// through UpdateConfig evicts down to the new limit and enforces it for
+// later stores
+func TestCachingListener_UpdateConfigEvicts(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	cache := cachingListener.GetCache()
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte("data"), http.Header{}, time.Hour)
+	}
+
+	newConfig := DefaultCacheConfig()
+	newConfig.MaxEntries = 4
+	if err := cachingListener.UpdateConfig(newConfig); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+
+	if cache.Size() != 4 {
+		t.Errorf("Expected eviction down to 4 entries, got %d", cache.Size())
+	}
+	if evictions := cachingListener.GetStats().CacheStats.Evictions; evictions != 6 {
+		t.Errorf("Expected 6 evictions, got %d", evictions)
+	}
+
+	cache.Set("key-new", []byte("data"), http.Header{}, time.Hour)
+	if cache.Size() != 4 {
+		t.Errorf("Expected the new limit to hold for later stores, got %d entries", cache.Size())
+	}
+
+	cachingListener.ResetMetrics()
+	stats := cachingListener.GetStats().CacheStats
+	if stats.Evictions != 0 || stats.EntryCount != 4 {
+		t.Errorf("Expected counters reset with the entry gauge kept, got %d evictions and %d entries", stats.Evictions, stats.EntryCount)
+	}
+}
+
+// TestCachingListener_UpdateConfigInvalid verifies that an invalid config is
+// rejected without changing the cache's limits
+func TestCachingListener_UpdateConfigInvalid(t *testing.T) {
+	baseListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	cachingListener := NewCachingListener(baseListener, DefaultCacheConfig())
+	defer cachingListener.Close()
+
+	cache := cachingListener.GetCache()
+	cache.Set("key", []byte("data"), http.Header{}, time.Hour)
+
+	invalid := DefaultCacheConfig()
+	invalid.MaxEntries = 0
+	if err := cachingListener.UpdateConfig(invalid); err == nil {
+		t.Fatal("Expected an invalid config to be rejected")
+	}
+	if cache.Size() != 1 {
+		t.Errorf("Expected the cache to be untouched, got %d entries", cache.Size())
+	}
+}
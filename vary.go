@@ -0,0 +1,58 @@
+package selectcache
+
+import (
+	"net/http"
+	"strings"
+)
+
+// keyHeaders are request headers that always distinguish cache variants
+var keyHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization"}
+
+// parseVary returns the canonical request header names listed in a response's
+// Vary header. any reports Vary: *, which no later request can match.
+func parseVary(headers http.Header) (names []string, any bool) {
+	for _, value := range headers.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+				continue
+			case "*":
+				return nil, true
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names, false
+}
+
+// varyFor returns the Vary header names learned for a resource
+func (m *Middleware) varyFor(resource string) []string {
+	m.variantsMu.Lock()
+	defer m.variantsMu.Unlock()
+	return m.vary[resource]
+}
+
+// recordVary remembers the Vary header names of a resource's latest response
+// and reports whether they differ from those previously recorded
+func (m *Middleware) recordVary(resource string, names []string) bool {
+	m.variantsMu.Lock()
+	defer m.variantsMu.Unlock()
+
+	previous := m.vary[resource]
+	if len(names) == 0 {
+		delete(m.vary, resource)
+	} else {
+		m.vary[resource] = names
+	}
+
+	if len(previous) != len(names) {
+		return true
+	}
+	for i := range names {
+		if previous[i] != names[i] {
+			return true
+		}
+	}
+	return false
+}
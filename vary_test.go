@@ -0,0 +1,101 @@
+package selectcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_AcceptNegotiation(t *testing.T) {
+	middleware := New(DefaultConfig())
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Vary", "Accept")
+		if r.Header.Get("Accept") == "application/xml" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<item/>`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/item", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Each representation is stored and served separately
+	for i := 0; i < 2; i++ {
+		if rec := serve("application/json"); rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != `{}` {
+			t.Errorf("JSON client got %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+		if rec := serve("application/xml"); rec.Header().Get("Content-Type") != "application/xml" || rec.Body.String() != `<item/>` {
+			t.Errorf("XML client got %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected one origin request per representation, got %d", calls)
+	}
+}
+
+func TestMiddleware_VaryOnCustomHeader(t *testing.T) {
+	middleware := New(DefaultConfig())
+
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "Accept-Encoding, x-api-version")
+		w.Write([]byte(`{"version":"` + r.Header.Get("X-Api-Version") + `"}`))
+	}))
+
+	serve := func(version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api", nil)
+		req.Header.Set("X-Api-Version", version)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	serve("1")
+	if rec := serve("2"); rec.Header().Get("X-Cache-Status") == "HIT" {
+		t.Fatal("Expected a different X-Api-Version not to be served the cached variant")
+	}
+	if rec := serve("1"); rec.Header().Get("X-Cache-Status") != "HIT" || rec.Body.String() != `{"version":"1"}` {
+		t.Errorf("Expected version 1 variant from cache, got %q %q", rec.Header().Get("X-Cache-Status"), rec.Body.String())
+	}
+	if rec := serve("2"); rec.Header().Get("X-Cache-Status") != "HIT" || rec.Body.String() != `{"version":"2"}` {
+		t.Errorf("Expected version 2 variant from cache, got %q %q", rec.Header().Get("X-Cache-Status"), rec.Body.String())
+	}
+
+	// Deleting the URL removes every variant and the learned Vary headers
+	middleware.Delete("/api")
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected all variants deleted, got %d entries", items)
+	}
+	if vary := middleware.varyFor("/api"); len(vary) != 0 {
+		t.Errorf("Expected Vary headers forgotten after delete, got %v", vary)
+	}
+}
+
+func TestMiddleware_VaryStarNotCached(t *testing.T) {
+	middleware := New(DefaultConfig())
+
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "*")
+		w.Write([]byte(`{}`))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+	if items, _, _ := middleware.Stats(); items != 0 {
+		t.Errorf("Expected Vary: * response not to be cached, got %d entries", items)
+	}
+}
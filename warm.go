@@ -0,0 +1,121 @@
+package selectcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// WarmError reports a URL that could not be warmed
+type WarmError struct {
+	URL string
+	Err error
+}
+
+// Error implements the error interface
+func (e *WarmError) Error() string {
+	return fmt.Sprintf("warm %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *WarmError) Unwrap() error {
+	return e.Err
+}
+
+// Warm pre-populates the cache by fetching each URL with a GET request and
+// storing the result exactly as a real request would. URLs must be absolute.
+// Requests run on a pool of Config.WarmConcurrency workers and stop early when
+// ctx is cancelled. The returned error joins a *WarmError for every URL that
+// failed or was not cacheable; nil means every URL was warmed.
+func (m *Middleware) Warm(ctx context.Context, urls []string, client *http.Client) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	workers := m.warmConcurrency
+	if workers <= 0 {
+		workers = DefaultConfig().WarmConcurrency
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var errs []error
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range jobs {
+				if err := m.warmURL(ctx, url, client); err != nil {
+					mu.Lock()
+					errs = append(errs, &WarmError{URL: url, Err: err})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, url := range urls {
+		if ctx.Err() != nil {
+			// Report URLs that were never attempted
+			mu.Lock()
+			errs = append(errs, &WarmError{URL: url, Err: ctx.Err()})
+			mu.Unlock()
+			continue
+		}
+		select {
+		case jobs <- url:
+		case <-ctx.Done():
+			mu.Lock()
+			errs = append(errs, &WarmError{URL: url, Err: ctx.Err()})
+			mu.Unlock()
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// warmURL fetches a single URL and stores the response if it is cacheable
+func (m *Middleware) warmURL(ctx context.Context, url string, client *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Replay the response through a recorder so the normal caching rules apply
+	recorder := NewResponseRecorderWithLimit(&discardResponseWriter{header: make(http.Header)}, http.MethodGet, m.maxBodyBytes)
+	for k, v := range resp.Header {
+		recorder.Header()[k] = v
+	}
+	recorder.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(recorder, resp.Body); err != nil {
+		return err
+	}
+
+	if !m.shouldCache(recorder) {
+		return fmt.Errorf("response not cacheable (status %d)", resp.StatusCode)
+	}
+	m.storeResponseIfCacheable(m.createCacheKey(req), req, recorder)
+	return nil
+}
+
+// discardResponseWriter is an http.ResponseWriter that discards the body
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(statusCode int)  {}
@@ -0,0 +1,70 @@
+package selectcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareWarm(t *testing.T) {
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<h1>page</h1>"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+		}
+	})
+	server := httptest.NewServer(origin)
+	defer server.Close()
+
+	middleware := NewDefault()
+	err := middleware.Warm(context.Background(), []string{
+		server.URL + "/api/a",
+		server.URL + "/api/b?x=1",
+		server.URL + "/page",
+	}, server.Client())
+
+	var warmErr *WarmError
+	if !errors.As(err, &warmErr) || warmErr.URL != server.URL+"/page" {
+		t.Fatalf("Expected a single WarmError for the HTML page, got %v", err)
+	}
+
+	if itemCount, _, _ := middleware.Stats(); itemCount != 2 {
+		t.Fatalf("Expected 2 warmed entries, got %d", itemCount)
+	}
+
+	// Warmed entries are served as hits to real requests
+	calls := 0
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		origin.ServeHTTP(w, r)
+	}))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", server.URL+"/api/b?x=1", nil))
+	if resp.Header().Get("X-Cache-Status") != "HIT" || calls != 0 {
+		t.Errorf("Expected warmed entry to be served from cache")
+	}
+	if resp.Body.String() != `{"path": "/api/b"}` {
+		t.Errorf("Unexpected warmed body: %s", resp.Body.String())
+	}
+}
+
+func TestMiddlewareWarmHonorsCancellation(t *testing.T) {
+	middleware := NewDefault()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := middleware.Warm(ctx, []string{"http://example.invalid/a", "http://example.invalid/b"}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context cancellation error, got %v", err)
+	}
+	if itemCount, _, _ := middleware.Stats(); itemCount != 0 {
+		t.Errorf("Expected nothing to be warmed, got %d items", itemCount)
+	}
+}